@@ -0,0 +1,59 @@
+package lostinspace
+
+import (
+	"image"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Backend is what actually draws.
+// Meshes, textures and shader programs are made by it and Game draws through it,
+// so that the renderer can be swapped, or checked by tests without opengl.
+// See desktop.GLBackend and RecordingBackend.
+//
+// Objects are named by ids like opengl does. Id 0 names nothing.
+// Backend must be used from one goroutine.
+type Backend interface {
+	CreateBuffer() BufferID
+	// Replace contents of buffer with data, which is []float32, []uint16 or []uint32.
+	BufferData(buffer BufferID, data interface{})
+	// Replace part of buffer from offset, counted in elements, by data of the type the buffer has.
+	// Buffer keeps its size.
+	BufferSubData(buffer BufferID, offset int, data interface{})
+	DeleteBuffer(buffer BufferID)
+
+	CreateVertexArray() VertexArrayID
+	// Let vertex array read attribute from buffer, 3 floats per vertex.
+	// Buffer 0 disables the attribute.
+	VertexAttrib(vertexArray VertexArrayID, attrib uint32, buffer BufferID)
+	// Let vertex array read indices of triangles from buffer.
+	IndexBuffer(vertexArray VertexArrayID, buffer BufferID)
+	DeleteVertexArray(vertexArray VertexArrayID)
+
+	CreateTexture2D(img *image.RGBA) TextureID
+	// Every layer is width*height. Nil layer is left transparent.
+	CreateTexture2DArray(width, height int32, layers []*image.RGBA) TextureID
+	BindTexture(unit uint32, texture TextureID)
+	DeleteTexture(texture TextureID)
+
+	CreateProgram(vertexSource, fragmentSource string) (ProgramID, error)
+	UseProgram(program ProgramID)
+	UniformInt(program ProgramID, name string, value int32)
+	UniformMat4(program ProgramID, name string, value mgl32.Mat4)
+	DeleteProgram(program ProgramID)
+
+	// Change size of frames in pixels.
+	Resize(width, height int)
+	// Start a frame by clearing it.
+	BeginFrame()
+	// Draw count indices of vertex array as triangles,
+	// by the program in use with bound textures.
+	DrawElements(vertexArray VertexArrayID, count int32)
+	// Finish the frame and put it on the screen.
+	EndFrame()
+}
+
+type BufferID uint32
+type VertexArrayID uint32
+type TextureID uint32
+type ProgramID uint32
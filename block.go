@@ -3,6 +3,8 @@ package lostinspace
 type Block struct {
 	BlockType
 	FrontFace int
+	// Values of the properties of the block type, see BlockTypeDescriptor.Properties.
+	State BlockState
 
 	coord BlockCoord
 }
@@ -17,3 +19,7 @@ func NewBlock(blockCoord BlockCoord, blockType BlockType, face int) *Block {
 
 	return block
 }
+
+func (block *Block) Coord() BlockCoord {
+	return block.coord
+}
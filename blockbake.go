@@ -2,6 +2,7 @@ package lostinspace
 
 import (
 	"math"
+	"sort"
 
 	"github.com/go-gl/mathgl/mgl32"
 )
@@ -36,17 +37,17 @@ func NewBlockObject(storage BlockStorage, world *World, bodyType BodyType) *Bloc
 func (obj *BlockObject) Build() {
 }
 
-// Bake method calls opengl methods and box2d methods
+// Bake method calls backend methods and box2d methods
 // so this method must be called in main thread, not in goroutine.
-func (obj *BlockObject) Bake() {
-	obj.mesh.Bake()
+func (obj *BlockObject) Bake(backend Backend) {
+	obj.mesh.Bake(backend)
 	obj.mainBody.Bake()
 	for _, subBody := range obj.subBodies {
 		subBody.Bake()
 	}
 }
 
-// Destroy method removes opengl objects and box2d bodies
+// Destroy method removes backend objects and box2d bodies
 // so this method must be called in main thead, not in goroutine.
 func (obj *BlockObject) Destroy() {
 	obj.mesh.Destroy()
@@ -56,118 +57,371 @@ func (obj *BlockObject) Destroy() {
 	}
 }
 
-func BakeBlockStorageMesh(mesh *Mesh, storage BlockStorage, dic *BlockTypeDictionary) {
-	positions := make([]float32, 0)
-	//colors := make([]float32, 0)
-	coords := make([]float32, 0)
-	indices := make([]uint16, 0)
-
-	indexOffset := uint16(0)
+// Quads of void blocks aren't made, nor of non-fixed blocks which are drawn at their own bodies.
+// If greedy, neighbour blocks which look the same are merged into a rectangle,
+// whose texture repeats once for each block.
+func BakeBlockStorageMesh(mesh *Mesh, storage BlockStorage, dic *BlockTypeDictionary, greedy bool) {
+	looks := make(map[BlockCoord]interface{})
 	storage.ForEach(func(block *Block) {
-		if block.BlockType == BLOCK_TYPE_VOID {
-			positions = append(positions,
-				0, 0, 0,
-				0, 0, 0,
-				0, 0, 0,
-				0, 0, 0,
-			)
-			//colors = append(colors,
-			//	0, 0, 0,
-			//	0, 0, 0,
-			//	0, 0, 0,
-			//	0, 0, 0,
-			//)
-			coords = append(coords,
-				0, 0, 0,
-				0, 0, 0,
-				0, 0, 0,
-				0, 0, 0,
-			)
-		} else {
-			x := float32(block.coord.X)
-			y := float32(block.coord.Y)
-			descriptor := dic.Get(block.BlockType)
-			layer := float32(0)
-			if descriptor != nil {
-				layer = float32(descriptor.layerIndex)
-			}
-
-			positions = append(positions,
-				-0.5+x, 0.5+y, 0,
-				-0.5+x, -0.5+y, 0,
-				0.5+x, -0.5+y, 0,
-				0.5+x, 0.5+y, 0,
-			)
-			//colors = append(colors,
-			//	1, 1, 1,
-			//	1, 1, 1,
-			//	1, 1, 1,
-			//	1, 1, 1,
-			//)
-			coordVecs := []Vec2{
-				{-0.5, -0.5},
-				{-0.5, 0.5},
-				{0.5, 0.5},
-				{0.5, -0.5},
-			}
-			for _, coordVec := range coordVecs {
-				rotatedVec := mgl32.Rotate2D(float32(block.FrontFace) * math.Pi / 2.0).Mul2x1(mgl32.Vec2{float32(coordVec.X), float32(coordVec.Y)})
-				rotatedVec[0] += 0.5
-				rotatedVec[1] += 0.5
-
-				coords = append(coords,
-					rotatedVec[0], rotatedVec[1], layer,
-				)
-			}
-			/*
-				coords = append(coords,
-					0, 0, layer,
-					0, 1, layer,
-					1, 1, layer,
-					1, 0, layer,
-				)
-			*/
+		if block.BlockType != BLOCK_TYPE_VOID && !movesAlone(block, dic) {
+			looks[block.coord] = blockLookOf(block, dic)
 		}
-
-		indices = append(indices,
-			0+indexOffset, 1+indexOffset, 2+indexOffset,
-			0+indexOffset, 2+indexOffset, 3+indexOffset,
-		)
-
-		indexOffset += 4
 	})
+	rects := mergeBlockRects(looks, greedy)
+
+	positions := make([]float32, len(rects)*4*3)
+	texCoords := make([]float32, len(rects)*4*3)
+	indices := make([]uint32, 0, len(rects)*6)
+	for i, rect := range rects {
+		writeBlockQuad(positions[i*12:], texCoords[i*12:], rect)
+		indices = appendQuadIndices(indices, uint32(i*4))
+	}
 
 	mesh.Positions = positions
 	mesh.Colors = nil
-	mesh.TexCoords = coords
+	mesh.TexCoords = texCoords
 	mesh.Indices = indices
 }
 
-func BakeBlockStorageBody(body *Body, storage BlockStorage, dic *BlockTypeDictionary) {
+// Whether the block is non-fixed, so that it has a body of its own instead of being part of the storage.
+// See blockMover.
+func movesAlone(block *Block, dic *BlockTypeDictionary) bool {
+	des := dic.Get(block.BlockType)
+	return des != nil && !des.Fixed
+}
+
+// What makes a quad of a block look different from others.
+type blockLook struct {
+	layer     int
+	frontFace int
+}
+
+func blockLookOf(block *Block, dic *BlockTypeDictionary) blockLook {
+	layer := 0
+	if descriptor := dic.Get(block.BlockType); descriptor != nil {
+		layer = descriptor.layer(block.State)
+	}
+	return blockLook{layer, block.FrontFace}
+}
+
+// Write 4 vertices of the quad of rect, whose key is blockLook, to the start of positions and texCoords.
+// Rect without any block makes a degenerate quad.
+func writeBlockQuad(positions, texCoords []float32, rect blockRect) {
+	if rect.width == 0 || rect.height == 0 {
+		for i := 0; i < 12; i++ {
+			positions[i] = 0
+			texCoords[i] = 0
+		}
+		return
+	}
+
+	look := rect.key.(blockLook)
+	x, y := float32(rect.coord.X), float32(rect.coord.Y)
+	rotate := mgl32.Rotate2D(float32(look.frontFace) * math.Pi / 2.0)
+	for i, corner := range rect.corners() {
+		positions[i*3+0] = float32(corner.X) + x
+		positions[i*3+1] = float32(corner.Y) + y
+		positions[i*3+2] = 0
+
+		// Texture v goes down.
+		texCoord := rotate.Mul2x1(mgl32.Vec2{float32(corner.X), -float32(corner.Y)})
+		texCoords[i*3+0] = texCoord[0] + 0.5
+		texCoords[i*3+1] = texCoord[1] + 0.5
+		texCoords[i*3+2] = float32(look.layer)
+	}
+}
+
+// Two triangles of the quad from the vertex at offset.
+func appendQuadIndices(indices []uint32, offset uint32) []uint32 {
+	return append(indices,
+		0+offset, 1+offset, 2+offset,
+		0+offset, 2+offset, 3+offset,
+	)
+}
+
+// Blocks whose collision fills the whole cell are merged into rectangles if merge is true,
+// as long as they have the same density, friction and restitution.
+// Other blocks have a fixture of their own, but non-fixed ones which are left out,
+// as they get bodies of their own only when a chunk or BlockEntity is baked.
+func BakeBlockStorageBody(body *Body, storage BlockStorage, dic *BlockTypeDictionary, merge bool) {
+	newBlockCollision(body, dic, merge).bake(storage)
+}
+
+// Fixtures of a block storage, which can be changed block by block.
+type blockCollision struct {
+	body  *Body
+	dic   *BlockTypeDictionary
+	merge bool
+
+	// Fixture covering each block and the rectangle it covers.
+	cells map[BlockCoord]blockFixture
+	// Bodies of non-fixed blocks, added to the world by bakeBodies.
+	movers map[BlockCoord]*blockMover
+}
+
+type blockFixture struct {
+	fixture *Fixture
+	rect    blockRect
+}
+
+func newBlockCollision(body *Body, dic *BlockTypeDictionary, merge bool) *blockCollision {
+	collision := &blockCollision{
+		body:   body,
+		dic:    dic,
+		merge:  merge,
+		cells:  make(map[BlockCoord]blockFixture),
+		movers: make(map[BlockCoord]*blockMover),
+	}
+
+	return collision
+}
+
+// Replace every fixture of the body by ones of the blocks, see BakeBlockStorageBody.
+func (collision *blockCollision) bake(storage BlockStorage) {
+	collision.body.Clear()
+	collision.cells = make(map[BlockCoord]blockFixture)
+	collision.destroyMovers()
+
+	materials := make(map[BlockCoord]interface{})
 	storage.ForEach(func(block *Block) {
-		if block.BlockType == "" {
+		if des := collision.dic.Get(block.BlockType); des != nil && des.Fixed && fillsCell(des.collisionVertices(block.State)) {
+			materials[block.coord] = blockMaterial{des.Density, des.Friction, des.Restitution}
 			return
 		}
+		collision.addBlock(block)
+	})
 
-		des := dic.Get(block.BlockType)
+	for _, rect := range mergeBlockRects(materials, collision.merge) {
+		collision.addRect(rect)
+	}
+}
 
-		vertices := make([]Vec2, len(des.CollisionVertices))
-		for i, vertex := range des.CollisionVertices {
-			rotatedVertex := mgl32.Rotate2D(float32(block.FrontFace) * math.Pi / 2.0).Mul2x1(mgl32.Vec2{float32(vertex.X), float32(vertex.Y)})
+// Change fixtures for the block at coord, which is changed in the storage.
+// A merged rectangle around it is split, the others are left as they are.
+// Bodies have to be baked again by bakeBodies to add the new fixtures to the world.
+func (collision *blockCollision) update(storage BlockStorage, coord BlockCoord) {
+	if mover, exist := collision.movers[coord]; exist {
+		// Same block in another state keeps sliding on its joint.
+		block := storage.At(coord)
+		if block != nil && block.BlockType == mover.block.BlockType && block.FrontFace == mover.block.FrontFace {
+			mover.setState(block.State)
+			return
+		}
+		mover.destroy()
+		delete(collision.movers, coord)
+	}
 
-			vertices[i] = Vec2{
-				float64(rotatedVertex[0]) + float64(block.coord.X),
-				float64(rotatedVertex[1]) + float64(block.coord.Y),
-			}
+	if old, exist := collision.cells[coord]; exist {
+		collision.body.DestroyFixture(old.fixture)
+		for _, cell := range old.rect.blocks() {
+			delete(collision.cells, cell)
 		}
+		for _, rest := range old.rect.without(coord) {
+			collision.addRect(rest)
+		}
+	}
 
-		/*
-			if !block.Fixed {
-				singleBody := body.world.CreateBody(true)
-				singleBody.AddPolygonFixture(des.Density, des.Friction, des.Restitution, vertices)
-			}
-		*/
+	if block := storage.At(coord); block != nil {
+		collision.addBlock(block)
+	}
+}
+
+func (collision *blockCollision) addBlock(block *Block) {
+	if block.BlockType == BLOCK_TYPE_VOID {
+		return
+	}
+
+	des := collision.dic.Get(block.BlockType)
+	if des == nil {
+		return
+	}
+	if !des.Fixed {
+		collision.movers[block.coord] = newBlockMover(collision.body.world, block, des)
+		return
+	}
+	collisionVertices := des.collisionVertices(block.State)
+	if fillsCell(collisionVertices) {
+		collision.addRect(blockRect{block.coord, 1, 1, blockMaterial{des.Density, des.Friction, des.Restitution}})
+		return
+	}
+
+	offset := Vec2{float64(block.coord.X), float64(block.coord.Y)}
+	vertices := rotateBlockVertices(collisionVertices, block.FrontFace, offset)
+	fixture := collision.body.AddPolygonFixture(des.Density, des.Friction, des.Restitution, vertices)
+	collision.cells[block.coord] = blockFixture{fixture, blockRect{block.coord, 1, 1, nil}}
+}
+
+// Vertices turned by front face, then moved by offset.
+func rotateBlockVertices(vertices []Vec2, frontFace int, offset Vec2) []Vec2 {
+	rotate := mgl32.Rotate2D(float32(frontFace) * math.Pi / 2.0)
+
+	rotated := make([]Vec2, len(vertices))
+	for i, vertex := range vertices {
+		rotatedVertex := rotate.Mul2x1(mgl32.Vec2{float32(vertex.X), float32(vertex.Y)})
+		rotated[i] = Vec2{
+			float64(rotatedVertex[0]) + offset.X,
+			float64(rotatedVertex[1]) + offset.Y,
+		}
+	}
+
+	return rotated
+}
+
+// Add the body and the bodies of non-fixed blocks to the world, with fixtures added since the last call.
+// Non-fixed blocks start where they are in the storage, on joints to the body.
+// It has to be called in main thread.
+func (collision *blockCollision) bakeBodies() {
+	collision.body.Bake()
+
+	coords := make([]BlockCoord, 0, len(collision.movers))
+	for coord := range collision.movers {
+		coords = append(coords, coord)
+	}
+	sort.Slice(coords, func(i, j int) bool {
+		return blockIndex(coords[i]) < blockIndex(coords[j])
+	})
+	for _, coord := range coords {
+		collision.movers[coord].bake(collision.body)
+	}
+}
+
+func (collision *blockCollision) destroyMovers() {
+	for coord, mover := range collision.movers {
+		mover.destroy()
+		delete(collision.movers, coord)
+	}
+}
+
+// Remove the body and the bodies of non-fixed blocks from the world.
+func (collision *blockCollision) destroy() {
+	collision.destroyMovers()
+	collision.body.Destroy()
+}
+
+// Add a fixture of the rectangle, whose key is blockMaterial.
+func (collision *blockCollision) addRect(rect blockRect) {
+	material := rect.key.(blockMaterial)
+	corners := rect.corners()
+	for i := range corners {
+		corners[i].X += float64(rect.coord.X)
+		corners[i].Y += float64(rect.coord.Y)
+	}
+
+	fixture := collision.body.AddPolygonFixture(material.density, material.friction, material.restitution, corners[:])
+	for _, cell := range rect.blocks() {
+		collision.cells[cell] = blockFixture{fixture, rect}
+	}
+}
+
+type blockMaterial struct {
+	density, friction, restitution float64
+}
+
+// Rectangle of blocks from coord, which is the bottom left one.
+type blockRect struct {
+	coord         BlockCoord
+	width, height int
+	key           interface{}
+}
+
+// Corners of the rectangle from the center of the first block, counter clockwise from the top left.
+func (rect blockRect) corners() [4]Vec2 {
+	w, h := float64(rect.width), float64(rect.height)
+	return [4]Vec2{
+		{-0.5, h - 0.5},
+		{-0.5, -0.5},
+		{w - 0.5, -0.5},
+		{w - 0.5, h - 0.5},
+	}
+}
+
+// Coords of every block in the rectangle.
+func (rect blockRect) blocks() []BlockCoord {
+	coords := make([]BlockCoord, 0, rect.width*rect.height)
+	for j := 0; j < rect.height; j++ {
+		for i := 0; i < rect.width; i++ {
+			coords = append(coords, BlockCoord{rect.coord.X + uint8(i), rect.coord.Y + uint8(j)})
+		}
+	}
+
+	return coords
+}
 
-		body.AddPolygonFixture(des.Density, des.Friction, des.Restitution, vertices)
+// Rectangles covering the rectangle but the block at coord, which must be in it.
+// Rows below and above the block, then the blocks left and right of it in its row.
+func (rect blockRect) without(coord BlockCoord) []blockRect {
+	x, y := int(rect.coord.X), int(rect.coord.Y)
+	cx, cy := int(coord.X), int(coord.Y)
+
+	rects := make([]blockRect, 0, 4)
+	add := func(x, y, width, height int) {
+		if width > 0 && height > 0 {
+			rects = append(rects, blockRect{BlockCoord{uint8(x), uint8(y)}, width, height, rect.key})
+		}
+	}
+	add(x, y, rect.width, cy-y)
+	add(x, cy+1, rect.width, y+rect.height-1-cy)
+	add(x, cy, cx-x, 1)
+	add(cx+1, cy, x+rect.width-1-cx, 1)
+
+	return rects
+}
+
+// Cover the blocks by rectangles of blocks with equal keys, a rectangle for each block if not merge.
+// Rectangles are made greedily, wide first, from the bottom row.
+func mergeBlockRects(keys map[BlockCoord]interface{}, merge bool) []blockRect {
+	coords := make([]BlockCoord, 0, len(keys))
+	for coord := range keys {
+		coords = append(coords, coord)
+	}
+	sort.Slice(coords, func(i, j int) bool {
+		if coords[i].Y != coords[j].Y {
+			return coords[i].Y < coords[j].Y
+		}
+		return coords[i].X < coords[j].X
 	})
+
+	merged := make(map[BlockCoord]bool)
+	// Whether the block at (x, y) isn't merged yet and has the key.
+	mergeable := func(x, y int, key interface{}) bool {
+		if x > math.MaxUint8 || y > math.MaxUint8 {
+			return false
+		}
+		coord := BlockCoord{uint8(x), uint8(y)}
+		other, exist := keys[coord]
+		return exist && other == key && !merged[coord]
+	}
+
+	rects := make([]blockRect, 0)
+	for _, coord := range coords {
+		if merged[coord] {
+			continue
+		}
+		key := keys[coord]
+		x, y := int(coord.X), int(coord.Y)
+
+		width, height := 1, 1
+		if merge {
+			for mergeable(x+width, y, key) {
+				width++
+			}
+		rows:
+			for {
+				for i := 0; i < width; i++ {
+					if !mergeable(x+i, y+height, key) {
+						break rows
+					}
+				}
+				height++
+			}
+		}
+		rect := blockRect{coord, width, height, key}
+		for _, block := range rect.blocks() {
+			merged[block] = true
+		}
+
+		rects = append(rects, rect)
+	}
+
+	return rects
 }
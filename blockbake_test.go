@@ -0,0 +1,143 @@
+package lostinspace_test
+
+import (
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+func TestBakeBlockStorageMesh(t *testing.T) {
+	dic := testBlockTypeDic()
+
+	cases := []struct {
+		name   string
+		blocks func(chunk *lostinspace.Chunk)
+		// Vertices without and with greedy merge.
+		vertices, greedyVertices int
+	}{
+		{"empty", func(chunk *lostinspace.Chunk) {}, 0, 0},
+		{"single", func(chunk *lostinspace.Chunk) {
+			chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: 3, Y: 7}, "stone", 0))
+		}, 4, 4},
+		{"full", func(chunk *lostinspace.Chunk) {
+			for y := uint8(0); y < lostinspace.CHUNK_HEIGHT; y++ {
+				for x := uint8(0); x < lostinspace.CHUNK_WIDTH; x++ {
+					chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: x, Y: y}, "stone", 0))
+				}
+			}
+		}, 1024, 4},
+		{"floor with a gap", func(chunk *lostinspace.Chunk) {
+			for x := uint8(0); x < lostinspace.CHUNK_WIDTH; x++ {
+				if x != 5 {
+					chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: x, Y: 0}, "stone", 0))
+				}
+			}
+		}, 60, 8},
+		{"different types and faces", func(chunk *lostinspace.Chunk) {
+			chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: 0, Y: 0}, "stone", 0))
+			chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: 1, Y: 0}, "test1", 0))
+			chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: 2, Y: 0}, "test1", 1))
+			chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: 3, Y: 0}, "test1", 1))
+		}, 16, 12},
+		{"checkerboard", func(chunk *lostinspace.Chunk) {
+			for y := uint8(0); y < lostinspace.CHUNK_HEIGHT; y++ {
+				for x := uint8(0); x < lostinspace.CHUNK_WIDTH; x++ {
+					if (x+y)%2 == 0 {
+						chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: x, Y: y}, "stone", 0))
+					}
+				}
+			}
+		}, 512, 512},
+	}
+
+	for _, c := range cases {
+		chunk := lostinspace.NewChunk(lostinspace.ChunkCoord{})
+		c.blocks(chunk)
+
+		for _, greedy := range []bool{false, true} {
+			expected := c.vertices
+			if greedy {
+				expected = c.greedyVertices
+			}
+
+			mesh := lostinspace.NewMesh(nil, nil, nil, nil)
+			lostinspace.BakeBlockStorageMesh(mesh, chunk, dic, greedy)
+			if vertices := len(mesh.Positions) / 3; vertices != expected {
+				t.Errorf("%s, greedy %v: %d vertices, expected %d\n", c.name, greedy, vertices, expected)
+			}
+			if len(mesh.TexCoords) != len(mesh.Positions) || len(mesh.Indices) != expected/4*6 {
+				t.Errorf("%s, greedy %v: %d texture coordinates and %d indices for %d vertices\n",
+					c.name, greedy, len(mesh.TexCoords)/3, len(mesh.Indices), expected)
+			}
+		}
+	}
+}
+
+// Chunk full of stone but a column of test1, whose density differs, and a door.
+func testCollisionChunk() *lostinspace.Chunk {
+	chunk := lostinspace.NewChunk(lostinspace.ChunkCoord{})
+	for y := uint8(0); y < lostinspace.CHUNK_HEIGHT; y++ {
+		for x := uint8(0); x < lostinspace.CHUNK_WIDTH; x++ {
+			blockType := lostinspace.BlockType("stone")
+			if x == 8 {
+				blockType = "test1"
+			}
+			chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: x, Y: y}, blockType, 0))
+		}
+	}
+	chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: 3, Y: 3}, "door0", 0))
+
+	return chunk
+}
+
+func TestBakeBlockStorageBody(t *testing.T) {
+	square := []lostinspace.Vec2{{X: -0.5, Y: 0.5}, {X: -0.5, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: 0.5, Y: 0.5}}
+	thin := []lostinspace.Vec2{{X: -0.5, Y: 0.25}, {X: -0.5, Y: -0.25}, {X: 0.5, Y: -0.25}, {X: 0.5, Y: 0.25}}
+	dic := lostinspace.NewBlockTypeDictionary([]*lostinspace.BlockTypeDescriptor{
+		{BlockType: "stone", Density: 0.5, Friction: 0.2, Restitution: 0.01, CollisionVertices: square, Fixed: true},
+		{BlockType: "test1", Density: 1, Friction: 0.2, Restitution: 0.01, CollisionVertices: square, Fixed: true},
+		{BlockType: "door0", Density: 1, Friction: 0.2, Restitution: 0.01, CollisionVertices: thin},
+	})
+
+	world := lostinspace.NewWorld()
+	cases := []struct {
+		merge    bool
+		fixtures int
+	}{
+		// Stone below, left, right and above the door, test1 column and stone right of it.
+		// Door isn't fixed, it gets a body of its own.
+		{true, 6},
+		{false, 255},
+	}
+	for _, c := range cases {
+		body := world.CreateBody(lostinspace.STATIC)
+		lostinspace.BakeBlockStorageBody(body, testCollisionChunk(), dic, c.merge)
+		if count := body.GetFixtureCount(); count != c.fixtures {
+			t.Errorf("merge %v: %d fixtures, expected %d\n", c.merge, count, c.fixtures)
+		}
+		body.Bake()
+		body.Destroy()
+	}
+}
+
+func BenchmarkBakeBlockStorageBody(b *testing.B) {
+	dic := testBlockTypeDic()
+	world := lostinspace.NewWorld()
+	chunk := testCollisionChunk()
+
+	for _, merge := range []bool{false, true} {
+		name := "PerBlock"
+		if merge {
+			name = "Merged"
+		}
+		b.Run(name, func(b *testing.B) {
+			fixtures := 0
+			for i := 0; i < b.N; i++ {
+				body := world.CreateBody(lostinspace.STATIC)
+				lostinspace.BakeBlockStorageBody(body, chunk, dic, merge)
+				fixtures = body.GetFixtureCount()
+			}
+			b.ReportMetric(float64(fixtures), "fixtures")
+		})
+	}
+}
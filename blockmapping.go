@@ -0,0 +1,108 @@
+package lostinspace
+
+import "sync"
+
+// BlockMapping gives ids to block types for one universe.
+// Unlike ids of a dictionary, they never change once given,
+// so sector files can refer to block types by id. See sectorfile.go.
+//
+// It can be used from any goroutine.
+type BlockMapping struct {
+	mu sync.Mutex
+
+	// Block type of each id, void first.
+	types []BlockType
+	ids   map[BlockType]BlockID
+	// Whether ids are given since it's made or saved.
+	modified bool
+}
+
+// Mapping where the i-th type has id i.
+// Types has to start with BLOCK_TYPE_VOID, it's added if it doesn't.
+func NewBlockMapping(types []BlockType) *BlockMapping {
+	if len(types) == 0 || types[0] != BLOCK_TYPE_VOID {
+		types = append([]BlockType{BLOCK_TYPE_VOID}, types...)
+	}
+
+	mapping := &BlockMapping{
+		types: make([]BlockType, 0, len(types)),
+		ids:   make(map[BlockType]BlockID),
+	}
+	for _, blockType := range types {
+		if _, exist := mapping.ids[blockType]; exist {
+			continue
+		}
+		mapping.ids[blockType] = BlockID(len(mapping.types))
+		mapping.types = append(mapping.types, blockType)
+	}
+
+	return mapping
+}
+
+// Id of given block type, which is given now if it has none yet.
+func (mapping *BlockMapping) ID(blockType BlockType) BlockID {
+	mapping.mu.Lock()
+	defer mapping.mu.Unlock()
+
+	id, exist := mapping.ids[blockType]
+	if !exist {
+		id = BlockID(len(mapping.types))
+		mapping.ids[blockType] = id
+		mapping.types = append(mapping.types, blockType)
+		mapping.modified = true
+	}
+
+	return id
+}
+
+// Block type of given id and whether the id is given.
+func (mapping *BlockMapping) Type(id BlockID) (BlockType, bool) {
+	mapping.mu.Lock()
+	defer mapping.mu.Unlock()
+
+	if int(id) >= len(mapping.types) {
+		return BLOCK_TYPE_VOID, false
+	}
+	return mapping.types[id], true
+}
+
+// Block type of every id, indexed by id.
+func (mapping *BlockMapping) Types() []BlockType {
+	mapping.mu.Lock()
+	defer mapping.mu.Unlock()
+
+	return append([]BlockType(nil), mapping.types...)
+}
+
+// Id in dic of each id of the mapping, indexed by id of the mapping.
+// Types dic doesn't have, like removed ones, are BLOCK_ID_MISSING.
+func (mapping *BlockMapping) Remap(dic *BlockTypeDictionary) []BlockID {
+	types := mapping.Types()
+
+	ids := make([]BlockID, len(types))
+	for i, blockType := range types {
+		ids[i], _ = dic.ID(blockType)
+	}
+
+	return ids
+}
+
+// Types if ids are given since the last call, nil otherwise.
+func (mapping *BlockMapping) takeModified() []BlockType {
+	mapping.mu.Lock()
+	defer mapping.mu.Unlock()
+
+	if !mapping.modified {
+		return nil
+	}
+	mapping.modified = false
+
+	return append([]BlockType(nil), mapping.types...)
+}
+
+// Mark the mapping as modified again, like when saving it failed.
+func (mapping *BlockMapping) markModified() {
+	mapping.mu.Lock()
+	mapping.modified = true
+	mapping.mu.Unlock()
+}
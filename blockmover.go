@@ -0,0 +1,103 @@
+package lostinspace
+
+import "math"
+
+// Body of a non-fixed block, like a door, which slides on a prismatic joint
+// to the body of the chunk or entity the block is in. See BlockJoint.
+type blockMover struct {
+	// Where the block is in its storage and its state.
+	block Block
+	des   *BlockTypeDescriptor
+
+	body *Body
+	// Made when the body is baked.
+	joint *Joint
+}
+
+func newBlockMover(world *World, block *Block, des *BlockTypeDescriptor) *blockMover {
+	mover := &blockMover{
+		block: *block,
+		des:   des,
+		body:  world.CreateBody(DYNAMIC),
+	}
+	mover.addFixture()
+
+	return mover
+}
+
+func (mover *blockMover) addFixture() {
+	des := mover.des
+	vertices := rotateBlockVertices(des.collisionVertices(mover.block.State), mover.block.FrontFace, Vec2{})
+	mover.body.AddPolygonFixture(des.Density, des.Friction, des.Restitution, vertices)
+}
+
+// Joint of the block type, which keeps the block where it is if the type has none.
+func (mover *blockMover) jointConfig() BlockJoint {
+	if mover.des.Joint == nil {
+		return BlockJoint{Axis: Vec2{1, 0}}
+	}
+	return *mover.des.Joint
+}
+
+// Change state of the block, whose collision may differ.
+// It's applied to the world when the mover is baked.
+func (mover *blockMover) setState(state BlockState) {
+	old := mover.des.collisionVertices(mover.block.State)
+	mover.block.State = state
+	if !sameVertices(old, mover.des.collisionVertices(state)) {
+		mover.body.Clear()
+		mover.addFixture()
+	}
+}
+
+// Add the body to the world next to where the block is in base, and join them.
+// Afterwards only new fixtures and the motor of the state are applied,
+// unless the joint is destroyed, like by a body or break force, which joins them again.
+func (mover *blockMover) bake(base *Body) {
+	config := mover.jointConfig()
+	if mover.joint == nil || mover.joint.Destroyed() {
+		local := Vec2{float64(mover.block.coord.X), float64(mover.block.coord.Y)}
+		x, y := base.GetPosition()
+		angle := base.GetAngle()
+		cos, sin := math.Cos(angle), math.Sin(angle)
+		mover.body.SetPosition(x+local.X*cos-local.Y*sin, y+local.X*sin+local.Y*cos)
+		mover.body.SetAngle(angle)
+		mover.body.Bake()
+
+		mover.joint = base.world.CreatePrismaticJoint(PrismaticJointDef{
+			JointDef:      JointDef{BodyA: base, BodyB: mover.body, AnchorA: local},
+			Axis:          rotateBlockVertices([]Vec2{config.Axis}, mover.block.FrontFace, Vec2{})[0],
+			EnableLimit:   true,
+			Lower:         config.Lower,
+			Upper:         config.Upper,
+			EnableMotor:   config.MaxMotorForce > 0,
+			MaxMotorForce: config.MaxMotorForce,
+		})
+	}
+	mover.body.Bake()
+
+	// To Upper while the block is open.
+	speed := -config.MotorSpeed
+	if mover.des.Bool(mover.block.State, "open") {
+		speed = config.MotorSpeed
+	}
+	mover.joint.SetMotorSpeed(speed)
+}
+
+// Remove the body from the world, along with the joint.
+func (mover *blockMover) destroy() {
+	mover.body.Destroy()
+	mover.joint = nil
+}
+
+func sameVertices(a, b []Vec2) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
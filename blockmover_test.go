@@ -0,0 +1,87 @@
+package lostinspace
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// Door between two stones of an entity slides open and back, and goes away with its block.
+func TestBlockMover(t *testing.T) {
+	square := []Vec2{{-0.5, 0.5}, {-0.5, -0.5}, {0.5, -0.5}, {0.5, 0.5}}
+	thin := []Vec2{{-0.5, 0.25}, {-0.5, -0.25}, {0.5, -0.25}, {0.5, 0.25}}
+	dic := NewBlockTypeDictionary([]*BlockTypeDescriptor{
+		{BlockType: "stone", Density: 1, CollisionVertices: square, Fixed: true},
+		{BlockType: "door", Density: 1, CollisionVertices: thin,
+			Joint:      &BlockJoint{Axis: Vec2{1, 0}, Lower: 0, Upper: 0.9, MotorSpeed: 2, MaxMotorForce: 50},
+			Properties: []BlockProperty{{Name: "open", Kind: BLOCK_PROPERTY_BOOL}}},
+	})
+	door := dic.Get("door")
+
+	world := NewWorld()
+	entity := NewBlockEntity(world)
+	entity.Set(NewBlock(BlockCoord{0, 0}, "stone", 0))
+	entity.Set(NewBlock(BlockCoord{0, 1}, "door", 1))
+	entity.Set(NewBlock(BlockCoord{0, 2}, "stone", 0))
+	entity.SetPosition(10, 20)
+	entity.Bake(world, dic, NewRecordingBackend())
+
+	mover := entity.collision.movers[BlockCoord{0, 1}]
+	if len(entity.collision.movers) != 1 || mover == nil || mover.joint == nil {
+		t.Fatalf("Movers: %v\n", entity.collision.movers)
+	}
+	if count := entity.Body.GetFixtureCount(); count != 2 {
+		t.Errorf("%d fixtures of the entity, the door is one of them\n", count)
+	}
+	if x, y := mover.body.GetPosition(); x != 10 || y != 21 {
+		t.Errorf("Door starts at %v, %v\n", x, y)
+	}
+	if count := world.b2world.GetBodyCount(); count != 2 {
+		t.Errorf("%d bodies in the world\n", count)
+	}
+
+	// Door facing 1 slides up along its length.
+	translation := mover.joint.GetTranslation
+	setOpen := func(open bool) {
+		block := entity.At(BlockCoord{0, 1})
+		block.State, _ = door.WithBool(block.State, "open", open)
+		entity.collision.update(entity, block.coord)
+		entity.collision.bakeBodies()
+		for i := 0; i < 120; i++ {
+			world.Update(time.Second / 60)
+		}
+	}
+	setOpen(true)
+	if math.Abs(translation()-0.9) > 0.05 {
+		t.Errorf("Opened door is at %v\n", translation())
+	}
+	if _, y := mover.body.GetPosition(); y < 21.5 {
+		t.Errorf("Opened door at %v didn't go up\n", y)
+	}
+	setOpen(false)
+	if math.Abs(translation()) > 0.05 {
+		t.Errorf("Closed door is at %v\n", translation())
+	}
+
+	// Door whose joint is destroyed, like by break force, is joined again.
+	broken := mover.joint
+	broken.Destroy()
+	setOpen(true)
+	if mover.joint == broken || mover.joint.Destroyed() || math.Abs(mover.joint.GetTranslation()-0.9) > 0.05 {
+		t.Errorf("Door after its joint is destroyed: %+v\n", mover.joint)
+	}
+
+	// Broken door takes its body and joint with it.
+	entity.Set(NewBlock(BlockCoord{0, 1}, BLOCK_TYPE_VOID, 0))
+	entity.collision.update(entity, BlockCoord{0, 1})
+	entity.collision.bakeBodies()
+	if len(entity.collision.movers) != 0 || world.b2world.GetBodyCount() != 1 || world.b2world.GetJointCount() != 0 {
+		t.Errorf("%d movers, %d bodies and %d joints after the door is broken\n",
+			len(entity.collision.movers), world.b2world.GetBodyCount(), world.b2world.GetJointCount())
+	}
+
+	entity.Destroy()
+	if count := world.b2world.GetBodyCount(); count != 0 {
+		t.Errorf("%d bodies after the entity is destroyed\n", count)
+	}
+}
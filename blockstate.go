@@ -0,0 +1,258 @@
+package lostinspace
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+)
+
+// BlockState packs the value of every property of a block type into bits,
+// in the order the properties are declared. See BlockProperty.
+// Zero state has the first value of every property.
+type BlockState uint32
+
+type BlockPropertyKind string
+
+const (
+	// false or true.
+	BLOCK_PROPERTY_BOOL BlockPropertyKind = "bool"
+	// Integer from Min to Max.
+	BLOCK_PROPERTY_INT BlockPropertyKind = "int"
+	// One of Values.
+	BLOCK_PROPERTY_ENUM BlockPropertyKind = "enum"
+)
+
+var (
+	ErrUnknownBlockProperty = errors.New("unknown block property")
+	ErrInvalidBlockProperty = errors.New("invalid block property value")
+)
+
+// A property blocks of a type have, like whether a door is open.
+//
+//	{"name": "open", "type": "bool"}
+//	{"name": "damage", "type": "int", "min": 0, "max": 3}
+//	{"name": "color", "type": "enum", "values": ["white", "red"]}
+type BlockProperty struct {
+	Name string            `json:"name"`
+	Kind BlockPropertyKind `json:"type"`
+	// Range of int property, inclusive.
+	Min int `json:"min"`
+	Max int `json:"max"`
+	// Values of enum property.
+	Values []string `json:"values"`
+}
+
+// Look and shape of a block type in the states which have every property value of When.
+// The first variant a state has is used.
+//
+//	{"when": {"open": "true"}, "texture": "door_open.png", "collisionVertices": [...]}
+type BlockVariant struct {
+	// Property values as they are written, like "true", "3" or "red".
+	When map[string]string `json:"when"`
+	// Those of the block type are used if they are empty.
+	TexturePath       string `json:"texture"`
+	CollisionVertices []Vec2 `json:"collisionVertices"`
+
+	layerIndex int
+}
+
+// Number of values the property can have.
+func (prop *BlockProperty) count() int {
+	switch prop.Kind {
+	case BLOCK_PROPERTY_BOOL:
+		return 2
+	case BLOCK_PROPERTY_INT:
+		return prop.Max - prop.Min + 1
+	case BLOCK_PROPERTY_ENUM:
+		return len(prop.Values)
+	}
+	return 0
+}
+
+// Bits a state needs for the property.
+func (prop *BlockProperty) bits() uint {
+	if prop.count() <= 1 {
+		return 0
+	}
+	return uint(bits.Len(uint(prop.count() - 1)))
+}
+
+// Value as it's written in json, of the packed value.
+func (prop *BlockProperty) format(packed int) string {
+	switch prop.Kind {
+	case BLOCK_PROPERTY_BOOL:
+		return strconv.FormatBool(packed != 0)
+	case BLOCK_PROPERTY_INT:
+		return strconv.Itoa(packed + prop.Min)
+	case BLOCK_PROPERTY_ENUM:
+		if packed < len(prop.Values) {
+			return prop.Values[packed]
+		}
+	}
+	return ""
+}
+
+// Packed value of the value as it's written in json.
+func (prop *BlockProperty) parse(value string) (int, error) {
+	switch prop.Kind {
+	case BLOCK_PROPERTY_BOOL:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q is not a bool", ErrInvalidBlockProperty, value)
+		}
+		if b {
+			return 1, nil
+		}
+		return 0, nil
+	case BLOCK_PROPERTY_INT:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q is not an int", ErrInvalidBlockProperty, value)
+		}
+		return prop.pack(i)
+	case BLOCK_PROPERTY_ENUM:
+		for i, v := range prop.Values {
+			if v == value {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: %q of %s", ErrInvalidBlockProperty, value, prop.Name)
+}
+
+// Packed value of int property.
+func (prop *BlockProperty) pack(value int) (int, error) {
+	if value < prop.Min || value > prop.Max {
+		return 0, fmt.Errorf("%w: %d of %s is not from %d to %d", ErrInvalidBlockProperty, value, prop.Name, prop.Min, prop.Max)
+	}
+	return value - prop.Min, nil
+}
+
+// Property of the name and where its bits start in a state.
+func (desc *BlockTypeDescriptor) property(name string) (*BlockProperty, uint, error) {
+	offset := uint(0)
+	for i := range desc.Properties {
+		prop := &desc.Properties[i]
+		if prop.Name == name {
+			return prop, offset, nil
+		}
+		offset += prop.bits()
+	}
+	return nil, 0, fmt.Errorf("%w: %s of %s", ErrUnknownBlockProperty, name, desc.BlockType)
+}
+
+// Packed value of the property in the state, 0 if there is no such property.
+func (desc *BlockTypeDescriptor) packed(state BlockState, name string) int {
+	prop, offset, err := desc.property(name)
+	if err != nil {
+		return 0
+	}
+	return int(state>>offset) & (1<<prop.bits() - 1)
+}
+
+// State with the packed value of the property replaced.
+func (desc *BlockTypeDescriptor) withPacked(state BlockState, name string, kind BlockPropertyKind, value func(prop *BlockProperty) (int, error)) (BlockState, error) {
+	prop, offset, err := desc.property(name)
+	if err != nil {
+		return state, err
+	}
+	if prop.Kind != kind {
+		return state, fmt.Errorf("%w: %s is %s, not %s", ErrInvalidBlockProperty, name, prop.Kind, kind)
+	}
+	packed, err := value(prop)
+	if err != nil {
+		return state, err
+	}
+
+	mask := BlockState(1<<prop.bits()-1) << offset
+	return state&^mask | BlockState(packed)<<offset, nil
+}
+
+// Value of bool property in the state, false if there is no such property.
+func (desc *BlockTypeDescriptor) Bool(state BlockState, name string) bool {
+	return desc.packed(state, name) != 0
+}
+
+// Value of int property in the state, 0 if there is no such property.
+func (desc *BlockTypeDescriptor) Int(state BlockState, name string) int {
+	prop, _, err := desc.property(name)
+	if err != nil {
+		return 0
+	}
+	return desc.packed(state, name) + prop.Min
+}
+
+// Value of enum property in the state, empty if there is no such property.
+func (desc *BlockTypeDescriptor) Enum(state BlockState, name string) string {
+	prop, _, err := desc.property(name)
+	if err != nil || prop.Kind != BLOCK_PROPERTY_ENUM {
+		return ""
+	}
+	return prop.format(desc.packed(state, name))
+}
+
+func (desc *BlockTypeDescriptor) WithBool(state BlockState, name string, value bool) (BlockState, error) {
+	return desc.withPacked(state, name, BLOCK_PROPERTY_BOOL, func(prop *BlockProperty) (int, error) {
+		if value {
+			return 1, nil
+		}
+		return 0, nil
+	})
+}
+
+func (desc *BlockTypeDescriptor) WithInt(state BlockState, name string, value int) (BlockState, error) {
+	return desc.withPacked(state, name, BLOCK_PROPERTY_INT, func(prop *BlockProperty) (int, error) {
+		return prop.pack(value)
+	})
+}
+
+func (desc *BlockTypeDescriptor) WithEnum(state BlockState, name string, value string) (BlockState, error) {
+	return desc.withPacked(state, name, BLOCK_PROPERTY_ENUM, func(prop *BlockProperty) (int, error) {
+		return prop.parse(value)
+	})
+}
+
+// Whether every property has a value it can have and there are no bits beyond them.
+func (desc *BlockTypeDescriptor) ValidState(state BlockState) bool {
+	for _, prop := range desc.Properties {
+		size := prop.bits()
+		if int(state&(1<<size-1)) >= prop.count() {
+			return false
+		}
+		state >>= size
+	}
+	return state == 0
+}
+
+// Variant the state has, nil if none.
+func (desc *BlockTypeDescriptor) variant(state BlockState) *BlockVariant {
+variants:
+	for i := range desc.Variants {
+		variant := &desc.Variants[i]
+		for name, value := range variant.When {
+			prop, _, err := desc.property(name)
+			if err != nil || prop.format(desc.packed(state, name)) != value {
+				continue variants
+			}
+		}
+		return variant
+	}
+	return nil
+}
+
+// Texture array layer of the block type in the state.
+func (desc *BlockTypeDescriptor) layer(state BlockState) int {
+	if variant := desc.variant(state); variant != nil {
+		return variant.layerIndex
+	}
+	return desc.layerIndex
+}
+
+// Collision polygon of the block type in the state, before it's rotated by front face.
+func (desc *BlockTypeDescriptor) collisionVertices(state BlockState) []Vec2 {
+	if variant := desc.variant(state); variant != nil && len(variant.CollisionVertices) > 0 {
+		return variant.CollisionVertices
+	}
+	return desc.CollisionVertices
+}
@@ -0,0 +1,112 @@
+package lostinspace_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+func testStateBlockType() *lostinspace.BlockTypeDescriptor {
+	square := []lostinspace.Vec2{{X: -0.5, Y: 0.5}, {X: -0.5, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: 0.5, Y: 0.5}}
+	thin := []lostinspace.Vec2{{X: -0.5, Y: 0.5}, {X: -0.5, Y: 0.25}, {X: 0.5, Y: 0.25}, {X: 0.5, Y: 0.5}}
+	return &lostinspace.BlockTypeDescriptor{
+		BlockType: "hatch", Density: 1, Friction: 0.2, Restitution: 0.01, CollisionVertices: square, Fixed: true,
+		Properties: []lostinspace.BlockProperty{
+			{Name: "open", Kind: lostinspace.BLOCK_PROPERTY_BOOL},
+			{Name: "damage", Kind: lostinspace.BLOCK_PROPERTY_INT, Min: 1, Max: 3},
+			{Name: "color", Kind: lostinspace.BLOCK_PROPERTY_ENUM, Values: []string{"white", "red", "blue"}},
+		},
+		Variants: []lostinspace.BlockVariant{
+			{When: map[string]string{"open": "true"}, CollisionVertices: thin},
+			{When: map[string]string{"color": "red"}, TexturePath: "red.png"},
+		},
+	}
+}
+
+func TestBlockState(t *testing.T) {
+	des := testStateBlockType()
+
+	var state lostinspace.BlockState
+	if des.Bool(state, "open") || des.Int(state, "damage") != 1 || des.Enum(state, "color") != "white" {
+		t.Errorf("Zero state: %v %v %v\n", des.Bool(state, "open"), des.Int(state, "damage"), des.Enum(state, "color"))
+	}
+
+	state, err := des.WithBool(state, "open", true)
+	if err == nil {
+		state, err = des.WithInt(state, "damage", 3)
+	}
+	if err == nil {
+		state, err = des.WithEnum(state, "color", "blue")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !des.Bool(state, "open") || des.Int(state, "damage") != 3 || des.Enum(state, "color") != "blue" {
+		t.Errorf("State %b: %v %v %v\n", state, des.Bool(state, "open"), des.Int(state, "damage"), des.Enum(state, "color"))
+	}
+	// 1 bit for open, 2 bits for damage and color each.
+	if state != 0b10101 || !des.ValidState(state) {
+		t.Errorf("State %b, valid %v\n", state, des.ValidState(state))
+	}
+
+	if _, err := des.WithInt(state, "damage", 4); !errors.Is(err, lostinspace.ErrInvalidBlockProperty) {
+		t.Errorf("Damage out of range: %v\n", err)
+	}
+	if _, err := des.WithEnum(state, "color", "green"); !errors.Is(err, lostinspace.ErrInvalidBlockProperty) {
+		t.Errorf("Unknown color: %v\n", err)
+	}
+	if _, err := des.WithBool(state, "damage", true); !errors.Is(err, lostinspace.ErrInvalidBlockProperty) {
+		t.Errorf("Damage as bool: %v\n", err)
+	}
+	if _, err := des.WithBool(state, "powered", true); !errors.Is(err, lostinspace.ErrUnknownBlockProperty) {
+		t.Errorf("Unknown property: %v\n", err)
+	}
+	for _, invalid := range []lostinspace.BlockState{0b11000, 0b100000} {
+		if des.ValidState(invalid) {
+			t.Errorf("State %b is valid\n", invalid)
+		}
+	}
+}
+
+func TestBlockStateVariants(t *testing.T) {
+	des := testStateBlockType()
+	dic := lostinspace.NewBlockTypeDictionary([]*lostinspace.BlockTypeDescriptor{des})
+
+	closed := lostinspace.BlockState(0)
+	open, _ := des.WithBool(closed, "open", true)
+	red, _ := des.WithEnum(closed, "color", "red")
+
+	layer := func(state lostinspace.BlockState) float32 {
+		chunk := lostinspace.NewChunk(lostinspace.ChunkCoord{})
+		block := lostinspace.NewBlock(lostinspace.BlockCoord{}, "hatch", 0)
+		block.State = state
+		chunk.Set(block)
+
+		mesh := lostinspace.NewMesh(nil, nil, nil, nil)
+		lostinspace.BakeBlockStorageMesh(mesh, chunk, dic, true)
+		return mesh.TexCoords[2]
+	}
+	if layer(closed) != layer(open) || layer(red) == layer(closed) {
+		t.Errorf("Layers of closed, open and red: %v %v %v\n", layer(closed), layer(open), layer(red))
+	}
+
+	// Open blocks aren't merged, as they don't fill the cell.
+	world := lostinspace.NewWorld()
+	for _, c := range []struct {
+		state    lostinspace.BlockState
+		fixtures int
+	}{{closed, 1}, {open, 4}} {
+		chunk := lostinspace.NewChunk(lostinspace.ChunkCoord{})
+		for x := uint8(0); x < 4; x++ {
+			block := lostinspace.NewBlock(lostinspace.BlockCoord{X: x}, "hatch", 0)
+			block.State = c.state
+			chunk.Set(block)
+		}
+		body := world.CreateBody(lostinspace.STATIC)
+		lostinspace.BakeBlockStorageBody(body, chunk, dic, true)
+		if count := body.GetFixtureCount(); count != c.fixtures {
+			t.Errorf("State %b: %d fixtures, expected %d\n", c.state, count, c.fixtures)
+		}
+	}
+}
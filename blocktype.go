@@ -2,67 +2,268 @@ package lostinspace
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
 	"os"
 )
 
 // An block type to represents empty space. or void, whatever.
 const BLOCK_TYPE_VOID BlockType = ""
 
+// Block type a dictionary shows for types it doesn't have,
+// like ones removed since a universe was saved. Blocks keep their own type.
+const BLOCK_TYPE_MISSING BlockType = "missing"
+
+// Ids of a dictionary, which every dictionary has.
+const (
+	BLOCK_ID_VOID BlockID = iota
+	BLOCK_ID_MISSING
+)
+
 // BlockType represents type of block.
 // Identical for each block types.
 type BlockType string
 
+// Compact number of a block type.
+// A dictionary gives ids to its types when it's made, so they may differ every run.
+// Universes keep their own ids, see BlockMapping.
+type BlockID uint16
+
 // Struct to store all informations about all block types
 // such as physics properties, texture file, texture array indices etc.
 type BlockTypeDictionary struct {
-	data     map[BlockType]*BlockTypeDescriptor
+	data map[BlockType]*BlockTypeDescriptor
+	// Ordered by id, the missing type first.
+	// Id of a type is its index plus 1, as 0 is void.
+	descriptors []*BlockTypeDescriptor
+	// Block type and variant, which may be nil, of each texture array layer.
+	layers []blockLayer
+	// Created on first use, so that a dictionary doesn't need a backend.
 	arrayTex *Texture2DArray
 }
 
 // Struct to store informations about block type.
+// It's read from a json file by LoadBlockTypes, see cmd/game/blocks.
 type BlockTypeDescriptor struct {
 	// BlockType is id to distinguish block type.
 	// It has to be unique.
-	BlockType
+	BlockType `json:"id"`
 
 	// Name will be shown in game.
-	Name string
+	Name string `json:"name"`
 
-	Density     float64
-	Friction    float64
-	Restitution float64
-	// Vertices which represent collision polygon.
+	Density     float64 `json:"density"`
+	Friction    float64 `json:"friction"`
+	Restitution float64 `json:"restitution"`
+	// Vertices which represent collision polygon, counter clockwise.
 	//
-	// (x, y, x, y, x, y, ...)
-	CollisionVertices []Vec2
+	// [{"x": x, "y": y}, {"x": x, "y": y}, ...]
+	CollisionVertices []Vec2 `json:"collisionVertices"`
 	// Fixed property represents whether a block can move or can't.
 	// Non-fixed block will be create as seperated body from blockcontainer
 	// and will have a joint (prismatic joint for example) to stick together.
-	Fixed bool
+	Fixed bool `json:"fixed"`
+	// How non-fixed blocks slide, see blockMover. They don't move if it's nil.
+	Joint *BlockJoint `json:"joint"`
+
+	// Image file of the texture, opened when the texture array is made.
+	// Relative path in a json file is from the directory of the file.
+	TexturePath string `json:"texture"`
+	// Opened texture, used instead of TexturePath if it's not nil.
+	TextureFile *os.File `json:"-"`
+
+	// Free words for gameplay, like "door".
+	Tags []string `json:"tags"`
 
-	TextureFile *os.File
+	// Properties which make BlockState of the block type, see blockstate.go.
+	Properties []BlockProperty `json:"properties"`
+	// Different textures and collisions for some states.
+	Variants []BlockVariant `json:"variants"`
+	// Kind of tile entity blocks of the type get when they are placed, see RegisterTileEntity.
+	TileEntity string `json:"tileEntity"`
 
+	// Texture made in code, used before TextureFile.
+	textureImage *image.RGBA
+
+	id         BlockID
 	layerIndex int
 }
 
+// Prismatic joint between a non-fixed block and the body of its chunk or entity.
+// The block slides along Axis, which turns with the front face, from Lower to Upper.
+// A motor drives it to Upper while the block's bool property "open" is true, to Lower otherwise.
+//
+//	{"axis": {"x": 1, "y": 0}, "lower": 0, "upper": 0.9, "motorSpeed": 2, "maxMotorForce": 50}
+type BlockJoint struct {
+	Axis  Vec2    `json:"axis"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	// No motor if MaxMotorForce is zero.
+	MotorSpeed    float64 `json:"motorSpeed"`
+	MaxMotorForce float64 `json:"maxMotorForce"`
+}
+
+type blockLayer struct {
+	descriptor *BlockTypeDescriptor
+	variant    *BlockVariant
+}
+
+// Ids are given in the order of descriptors, after BLOCK_ID_MISSING.
+// Layers are given in the same order, each block type followed by its variants with texture.
 func NewBlockTypeDictionary(descriptors []*BlockTypeDescriptor) *BlockTypeDictionary {
 	dic := new(BlockTypeDictionary)
 	dic.data = make(map[BlockType]*BlockTypeDescriptor)
 
-	imgFiles := make([]*os.File, len(descriptors))
-	for i, descriptor := range descriptors {
+	dic.descriptors = append([]*BlockTypeDescriptor{missingBlockType()}, descriptors...)
+	for i, descriptor := range dic.descriptors {
 		dic.data[descriptor.BlockType] = descriptor
-		imgFiles[i] = descriptor.TextureFile
-		descriptor.layerIndex = i
+		descriptor.id = BlockID(i + 1)
+
+		descriptor.layerIndex = len(dic.layers)
+		dic.layers = append(dic.layers, blockLayer{descriptor, nil})
+		for j := range descriptor.Variants {
+			variant := &descriptor.Variants[j]
+			variant.layerIndex = descriptor.layerIndex
+			if variant.TexturePath != "" {
+				variant.layerIndex = len(dic.layers)
+				dic.layers = append(dic.layers, blockLayer{descriptor, variant})
+			}
+		}
 	}
-	dic.arrayTex = NewTexture2DArray(16, 16, imgFiles)
 
 	return dic
 }
 
+// Magenta and black checkers, which can't be mistaken for a real block.
+func missingBlockType() *BlockTypeDescriptor {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.RGBA{0, 0, 0, 255}
+			if (x/8+y/8)%2 == 0 {
+				c = color.RGBA{255, 0, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	return &BlockTypeDescriptor{
+		BlockType:         BLOCK_TYPE_MISSING,
+		Name:              "Missing block",
+		Density:           1,
+		Friction:          0.2,
+		Restitution:       0.01,
+		CollisionVertices: []Vec2{{-0.5, 0.5}, {-0.5, -0.5}, {0.5, -0.5}, {0.5, 0.5}},
+		Fixed:             true,
+		textureImage:      img,
+	}
+}
+
+// Texture array of every block type and variant, indexed by layer.
+// It's created by backend at the first call.
+func (dic *BlockTypeDictionary) ArrayTexture(backend Backend) *Texture2DArray {
+	if dic.arrayTex == nil {
+		layers := make([]*image.RGBA, len(dic.layers))
+		for i, layer := range dic.layers {
+			img, err := layer.texture()
+			if err != nil {
+				log.Printf("Texture of block type %q is left transparent: %v\n", layer.descriptor.BlockType, err)
+			}
+			layers[i] = img
+		}
+		dic.arrayTex = newTexture2DArray(backend, 16, 16, layers)
+	}
+
+	return dic.arrayTex
+}
+
+// Image of the texture, nil if there is none.
+func (layer blockLayer) texture() (*image.RGBA, error) {
+	desc := layer.descriptor
+	switch {
+	case layer.variant != nil:
+		return openRGBA(layer.variant.TexturePath)
+	case desc.textureImage != nil:
+		return desc.textureImage, nil
+	case desc.TextureFile != nil:
+		return decodeRGBA(desc.TextureFile)
+	case desc.TexturePath != "":
+		return openRGBA(desc.TexturePath)
+	}
+
+	return nil, nil
+}
+
+func openRGBA(path string) (*image.RGBA, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return decodeRGBA(file)
+}
+
 // Get descriptor of given block type.
+// Nil for void, the missing type for types the dictionary doesn't have.
 func (dic *BlockTypeDictionary) Get(blockType BlockType) *BlockTypeDescriptor {
-	return dic.data[blockType]
+	if blockType == BLOCK_TYPE_VOID {
+		return nil
+	}
+	if descriptor, exist := dic.data[blockType]; exist {
+		return descriptor
+	}
+	return dic.descriptors[0]
+}
+
+// Id of given block type and whether the dictionary has it.
+// Types it doesn't have are BLOCK_ID_MISSING.
+func (dic *BlockTypeDictionary) ID(blockType BlockType) (BlockID, bool) {
+	if blockType == BLOCK_TYPE_VOID {
+		return BLOCK_ID_VOID, true
+	}
+	if descriptor, exist := dic.data[blockType]; exist {
+		return descriptor.id, true
+	}
+	return BLOCK_ID_MISSING, false
+}
+
+// Descriptor of given id, nil for void and the missing type for unknown ids.
+func (dic *BlockTypeDictionary) ByID(id BlockID) *BlockTypeDescriptor {
+	if id == BLOCK_ID_VOID {
+		return nil
+	}
+	if int(id) > len(dic.descriptors) {
+		return dic.descriptors[0]
+	}
+	return dic.descriptors[id-1]
+}
+
+func (desc *BlockTypeDescriptor) HasTag(tag string) bool {
+	for _, t := range desc.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the collision polygon is the square of the whole block, whichever face is front.
+func fillsCell(vertices []Vec2) bool {
+	if len(vertices) != 4 {
+		return false
+	}
+
+	corners := make(map[Vec2]bool)
+	for _, vertex := range vertices {
+		if math.Abs(vertex.X) != 0.5 || math.Abs(vertex.Y) != 0.5 {
+			return false
+		}
+		corners[vertex] = true
+	}
+	return len(corners) == 4
 }
 
 func (desc *BlockTypeDescriptor) String() string {
@@ -0,0 +1,225 @@
+package lostinspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rlj1202/box2d"
+)
+
+// Error of a block type file, which tells where it is.
+type BlockTypeError struct {
+	Path string
+	// Json key of the field, empty if it's about the whole file.
+	Field string
+	Err   error
+}
+
+func (err *BlockTypeError) Error() string {
+	if err.Field == "" {
+		return fmt.Sprintf("%s: %v", err.Path, err.Err)
+	}
+	return fmt.Sprintf("%s: %q: %v", err.Path, err.Field, err.Err)
+}
+
+func (err *BlockTypeError) Unwrap() error {
+	return err.Err
+}
+
+// Read every *.json file in dir, each of which defines a block type.
+// Layers of the texture array are in order of file names.
+// It's an error if there is none, as the dir is likely wrong.
+func LoadBlockTypes(dir string) (*BlockTypeDictionary, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no block type files in %s", dir)
+	}
+	sort.Strings(paths)
+
+	descriptors := make([]*BlockTypeDescriptor, 0, len(paths))
+	defined := make(map[BlockType]string)
+	for _, path := range paths {
+		descriptor, err := loadBlockTypeFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if other, exist := defined[descriptor.BlockType]; exist {
+			return nil, &BlockTypeError{path, "id", fmt.Errorf("%q is already defined by %s", descriptor.BlockType, other)}
+		}
+		defined[descriptor.BlockType] = path
+
+		descriptors = append(descriptors, descriptor)
+	}
+
+	return NewBlockTypeDictionary(descriptors), nil
+}
+
+func loadBlockTypeFile(path string) (*BlockTypeDescriptor, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	descriptor, err := ReadBlockType(file)
+	if err != nil {
+		if typeErr, ok := err.(*BlockTypeError); ok {
+			typeErr.Path = path
+			return nil, typeErr
+		}
+		return nil, &BlockTypeError{Path: path, Err: err}
+	}
+
+	if !filepath.IsAbs(descriptor.TexturePath) {
+		descriptor.TexturePath = filepath.Join(filepath.Dir(path), descriptor.TexturePath)
+	}
+	if _, err := os.Stat(descriptor.TexturePath); err != nil {
+		return nil, &BlockTypeError{path, "texture", err}
+	}
+	for i := range descriptor.Variants {
+		variant := &descriptor.Variants[i]
+		if variant.TexturePath == "" {
+			continue
+		}
+		if !filepath.IsAbs(variant.TexturePath) {
+			variant.TexturePath = filepath.Join(filepath.Dir(path), variant.TexturePath)
+		}
+		if _, err := os.Stat(variant.TexturePath); err != nil {
+			return nil, &BlockTypeError{path, "variants", err}
+		}
+	}
+
+	return descriptor, nil
+}
+
+// Decode a block type from json and validate it.
+// Unknown keys are errors, so that misspelled ones aren't ignored.
+func ReadBlockType(r io.Reader) (*BlockTypeDescriptor, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	descriptor := new(BlockTypeDescriptor)
+	if err := decoder.Decode(descriptor); err != nil {
+		return nil, err
+	}
+	if err := descriptor.Validate(); err != nil {
+		return nil, err
+	}
+
+	return descriptor, nil
+}
+
+// Check whether the block type can be used.
+// Error is *BlockTypeError without path.
+func (desc *BlockTypeDescriptor) Validate() error {
+	invalid := func(field, format string, args ...interface{}) error {
+		return &BlockTypeError{Field: field, Err: fmt.Errorf(format, args...)}
+	}
+
+	switch {
+	case desc.BlockType == BLOCK_TYPE_VOID:
+		return invalid("id", "must not be empty")
+	case desc.BlockType == BLOCK_TYPE_MISSING:
+		return invalid("id", "%q is reserved", BLOCK_TYPE_MISSING)
+	case desc.Name == "":
+		return invalid("name", "must not be empty")
+	case !(desc.Density > 0):
+		return invalid("density", "must be positive, not %v", desc.Density)
+	case !(desc.Friction >= 0):
+		return invalid("friction", "must not be negative, not %v", desc.Friction)
+	case !(desc.Restitution >= 0 && desc.Restitution <= 1):
+		return invalid("restitution", "must be from 0 to 1, not %v", desc.Restitution)
+	case desc.TexturePath == "" && desc.TextureFile == nil:
+		return invalid("texture", "must not be empty")
+	case desc.TileEntity != "" && tileEntityKinds[desc.TileEntity] == nil:
+		return invalid("tileEntity", "%q is not registered", desc.TileEntity)
+	}
+
+	if err := validateCollisionVertices(desc.CollisionVertices); err != nil {
+		return invalid("collisionVertices", "%v", err)
+	}
+
+	if joint := desc.Joint; joint != nil {
+		switch {
+		case joint.Axis == (Vec2{}):
+			return invalid("joint", "axis must not be zero")
+		case !(joint.Upper >= joint.Lower):
+			return invalid("joint", "upper %v is less than lower %v", joint.Upper, joint.Lower)
+		case !(joint.MaxMotorForce >= 0):
+			return invalid("joint", "max motor force must not be negative, not %v", joint.MaxMotorForce)
+		}
+	}
+
+	stateBits := uint(0)
+	names := make(map[string]bool)
+	for i, prop := range desc.Properties {
+		switch {
+		case prop.Name == "":
+			return invalid("properties", "property %d has no name", i)
+		case names[prop.Name]:
+			return invalid("properties", "%s is declared twice", prop.Name)
+		case prop.Kind == BLOCK_PROPERTY_INT && prop.Max < prop.Min:
+			return invalid("properties", "max of %s is less than min", prop.Name)
+		case prop.Kind == BLOCK_PROPERTY_ENUM && len(prop.Values) == 0:
+			return invalid("properties", "%s has no values", prop.Name)
+		case prop.Kind != BLOCK_PROPERTY_BOOL && prop.Kind != BLOCK_PROPERTY_INT && prop.Kind != BLOCK_PROPERTY_ENUM:
+			return invalid("properties", "type of %s must be bool, int or enum, not %q", prop.Name, prop.Kind)
+		}
+		names[prop.Name] = true
+		stateBits += prop.bits()
+	}
+	if stateBits > 32 {
+		return invalid("properties", "need %d bits, more than 32", stateBits)
+	}
+
+	for i, variant := range desc.Variants {
+		for name, value := range variant.When {
+			prop, _, err := desc.property(name)
+			if err != nil {
+				return invalid("variants", "variant %d: %v", i, err)
+			}
+			packed, err := prop.parse(value)
+			if err != nil {
+				return invalid("variants", "variant %d: %v", i, err)
+			}
+			if prop.format(packed) != value {
+				return invalid("variants", "variant %d: write %q as %q", i, value, prop.format(packed))
+			}
+		}
+		if len(variant.CollisionVertices) > 0 {
+			if err := validateCollisionVertices(variant.CollisionVertices); err != nil {
+				return invalid("variants", "variant %d: %v", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateCollisionVertices(vertices []Vec2) error {
+	if len(vertices) < 3 || len(vertices) > box2d.B2_maxPolygonVertices {
+		return fmt.Errorf("must have 3 to %d vertices, not %d", box2d.B2_maxPolygonVertices, len(vertices))
+	}
+	for i, vertex := range vertices {
+		if math.Abs(vertex.X) > 0.5 || math.Abs(vertex.Y) > 0.5 {
+			return fmt.Errorf("vertex %d %v is out of the block, from -0.5 to 0.5", i, vertex)
+		}
+
+		// Every corner turns left.
+		next, after := vertices[(i+1)%len(vertices)], vertices[(i+2)%len(vertices)]
+		cross := (next.X-vertex.X)*(after.Y-next.Y) - (next.Y-vertex.Y)*(after.X-next.X)
+		if cross <= 0 {
+			return fmt.Errorf("must be a convex polygon in counter clockwise order, vertex %d is not", (i+1)%len(vertices))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package lostinspace_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+func TestLoadBlockTypes(t *testing.T) {
+	chdirAssets(t)
+
+	dic := assetBlockTypeDic(t)
+	for _, blockType := range []lostinspace.BlockType{"stone", "test1", "test2", "door0"} {
+		descriptor := dic.Get(blockType)
+		if descriptor == nil {
+			t.Errorf("%q is not loaded\n", blockType)
+			continue
+		}
+		if _, err := os.Stat(descriptor.TexturePath); err != nil {
+			t.Errorf("%q: %v\n", blockType, err)
+		}
+	}
+	if door := dic.Get("door0"); door == nil || door.Fixed || !door.HasTag("door") || len(door.CollisionVertices) != 4 {
+		t.Errorf("Door: %v\n", door)
+	}
+}
+
+func TestReadBlockTypeErrors(t *testing.T) {
+	const valid = `{
+		"id": "stone", "name": "Stone",
+		"density": 0.5, "friction": 0.2, "restitution": 0.01,
+		"collisionVertices": [{"x": -0.5, "y": 0.5}, {"x": -0.5, "y": -0.5}, {"x": 0.5, "y": -0.5}, {"x": 0.5, "y": 0.5}],
+		"fixed": true, "texture": "stone.png",
+		"properties": [{"name": "damage", "type": "int", "min": 0, "max": 3}],
+		"variants": [{"when": {"damage": "3"}, "texture": "cracked.png"}]
+	}`
+	if _, err := lostinspace.ReadBlockType(strings.NewReader(valid)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		old, new string
+		// Field the error is about, empty if it's not validation.
+		field string
+	}{
+		{`"id": "stone"`, `"id": ""`, "id"},
+		{`"density": 0.5`, `"density": 0`, "density"},
+		{`"friction": 0.2`, `"friction": -1`, "friction"},
+		{`"restitution": 0.01`, `"restitution": 1.5`, "restitution"},
+		{`"texture": "stone.png"`, `"texture": ""`, "texture"},
+		// Clockwise
+		{`{"x": -0.5, "y": 0.5}, {"x": -0.5, "y": -0.5}, {"x": 0.5, "y": -0.5}, {"x": 0.5, "y": 0.5}`,
+			`{"x": 0.5, "y": 0.5}, {"x": 0.5, "y": -0.5}, {"x": -0.5, "y": -0.5}, {"x": -0.5, "y": 0.5}`, "collisionVertices"},
+		{`{"x": -0.5, "y": 0.5}, {"x": -0.5, "y": -0.5}`, `{"x": -0.5, "y": 1}, {"x": -0.5, "y": -0.5}`, "collisionVertices"},
+		{`"type": "int"`, `"type": "float"`, "properties"},
+		{`"max": 3`, `"max": -1`, "properties"},
+		{`"max": 3`, `"max": 5000000000`, "properties"},
+		{`"damage": "3"`, `"damage": "4"`, "variants"},
+		{`"damage": "3"`, `"color": "red"`, "variants"},
+		{`"damage": "3"`, `"damage": "03"`, "variants"},
+		{`"fixed": true`, `"fixed": false, "joint": {"axis": {"x": 0, "y": 0}, "upper": 1}`, "joint"},
+		{`"fixed": true`, `"fixed": false, "joint": {"axis": {"x": 1, "y": 0}, "lower": 1}`, "joint"},
+		{`"fixed": true`, `"fixd": true`, ""},
+		{`"density": 0.5`, `"density": "heavy"`, ""},
+	}
+	for _, c := range cases {
+		_, err := lostinspace.ReadBlockType(strings.NewReader(strings.Replace(valid, c.old, c.new, 1)))
+		var typeErr *lostinspace.BlockTypeError
+		switch {
+		case err == nil:
+			t.Errorf("%s: no error\n", c.new)
+		case c.field != "" && (!errors.As(err, &typeErr) || typeErr.Field != c.field):
+			t.Errorf("%s: %v, expected an error about %q\n", c.new, err, c.field)
+		}
+	}
+}
+
+func TestLoadBlockTypesDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "stone.png"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	block := `{"id": "stone", "name": "Stone", "density": 1,
+		"collisionVertices": [{"x": 0, "y": 0.5}, {"x": -0.5, "y": -0.5}, {"x": 0.5, "y": -0.5}],
+		"texture": "stone.png"}`
+	for _, name := range []string{"a.json", "b.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(block), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := lostinspace.LoadBlockTypes(dir)
+	var typeErr *lostinspace.BlockTypeError
+	if !errors.As(err, &typeErr) || typeErr.Path != filepath.Join(dir, "b.json") || typeErr.Field != "id" {
+		t.Errorf("%v, expected b.json to be a duplicate\n", err)
+	}
+}
+
+func TestLoadBlockTypesEmpty(t *testing.T) {
+	for _, dir := range []string{t.TempDir(), filepath.Join(t.TempDir(), "missing")} {
+		if dic, err := lostinspace.LoadBlockTypes(dir); err == nil {
+			t.Errorf("%s: %v, expected an error\n", dir, dic)
+		}
+	}
+}
+
+func TestBlockTypeDictionaryIDs(t *testing.T) {
+	dic := lostinspace.NewBlockTypeDictionary([]*lostinspace.BlockTypeDescriptor{{BlockType: "stone"}, {BlockType: "door0"}})
+
+	if id, known := dic.ID(lostinspace.BLOCK_TYPE_VOID); id != lostinspace.BLOCK_ID_VOID || !known {
+		t.Errorf("Void: %d %v\n", id, known)
+	}
+	for _, blockType := range []lostinspace.BlockType{"stone", "door0", lostinspace.BLOCK_TYPE_MISSING} {
+		id, known := dic.ID(blockType)
+		if !known || dic.ByID(id).BlockType != blockType {
+			t.Errorf("%q: %d %v\n", blockType, id, known)
+		}
+	}
+	if id, known := dic.ID("removed"); id != lostinspace.BLOCK_ID_MISSING || known {
+		t.Errorf("Unknown type: %d %v\n", id, known)
+	}
+	if des := dic.ByID(100); des.BlockType != lostinspace.BLOCK_TYPE_MISSING {
+		t.Errorf("Unknown id: %v\n", des)
+	}
+
+	// Unknown blocks collide as the missing block does.
+	chunk := lostinspace.NewChunk(lostinspace.ChunkCoord{})
+	chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: 1, Y: 2}, "removed", 0))
+	body := lostinspace.NewWorld().CreateBody(lostinspace.STATIC)
+	lostinspace.BakeBlockStorageBody(body, chunk, dic, true)
+	if count := body.GetFixtureCount(); count != 1 {
+		t.Errorf("%d fixtures of an unknown block\n", count)
+	}
+}
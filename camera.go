@@ -16,6 +16,8 @@ type Camera struct {
 	hZoomHeight float64
 
 	target *Body
+	// Target is followed at its position interpolated by alpha, see Loop.Alpha.
+	alpha float64
 
 	projectionMat mgl32.Mat4
 	cameraMat     mgl32.Mat4
@@ -40,6 +42,10 @@ func (camera *Camera) SetTarget(body *Body) {
 	camera.target = body
 }
 
+func (camera *Camera) SetAlpha(alpha float64) {
+	camera.alpha = alpha
+}
+
 func (camera *Camera) GetSize() (width float64, height float64) {
 	width = camera.hwidth * 2
 	height = camera.hheight * 2
@@ -54,7 +60,7 @@ func (camera *Camera) GetCameraMat() mgl32.Mat4 {
 	if camera.target == nil {
 		return mgl32.Ident4()
 	}
-	x, y := camera.target.GetPosition()
+	x, y := camera.target.GetInterpolatedPosition(camera.alpha)
 	return mgl32.Translate3D(float32(-x), float32(-y), 0)
 }
 
@@ -71,7 +77,7 @@ func (camera *Camera) GetAABB() *AABB {
 	if camera.target == nil {
 		return nil
 	}
-	x, y := camera.target.GetPosition()
+	x, y := camera.target.GetInterpolatedPosition(camera.alpha)
 
 	return &AABB{
 		Center:  Vec2{x, y},
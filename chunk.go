@@ -1,85 +1,323 @@
 package lostinspace
 
+import "sync"
+
+// Chunk stores its blocks as a palette of distinct block states
+// and a packed array of palette indices, one per block.
+// A chunk of a single block state, like empty space, needs no index array at all.
+//
+// Blocks can be read and changed from any goroutine.
+// Collision belongs to the simulation.
 type Chunk struct {
-	Blocks [CHUNK_WIDTH * CHUNK_HEIGHT]*Block
+	// Guards palette, refs, indices, revisions, edits and tile entities.
+	mu sync.RWMutex
+
+	palette []blockState
+	// Number of blocks which refer to each palette entry.
+	// Entries without any reference are reused first.
+	refs    []int
+	indices packedArray
 
 	coord ChunkCoord
+	// Coord of the sector the chunk is put into, see Sector.Set.
+	sectorCoord WorldSectorCoord
+	// Incremented on every change of blocks.
+	revision uint64
+	// Incremented on every change of blocks or data of tile entities.
+	// The chunk differs from what is generated or saved if it's not savedRevision.
+	dataRevision  uint64
+	savedRevision uint64
+	// Blocks changed since editsFrom revision, one for each revision.
+	// Only the last CHUNK_EDITS_KEPT are kept.
+	edits     []BlockCoord
+	editsFrom uint64
+
+	tileEntities map[BlockCoord]tileEntry
+
+	collision *blockCollision
+}
 
-	mesh *Mesh
-	body *Body
+// Edits kept by a chunk for ChangesSince.
+const CHUNK_EDITS_KEPT = 64
 
-	aabb *AABB
+// blockState is a block regardless of where it is.
+type blockState struct {
+	BlockType
+	FrontFace int
+	State     BlockState
+}
+
+func (state blockState) block(coord BlockCoord) *Block {
+	block := NewBlock(coord, state.BlockType, state.FrontFace)
+	block.State = state.State
+
+	return block
 }
 
 func NewChunk(coord ChunkCoord) *Chunk {
 	chunk := new(Chunk)
 	chunk.coord = coord
-	for y := 0; y < CHUNK_HEIGHT; y++ {
-		for x := 0; x < CHUNK_WIDTH; x++ {
-			chunk.Set(NewBlock(BlockCoord{uint8(x), uint8(y)}, BLOCK_TYPE_VOID, 0))
-		}
-	}
+	chunk.palette = []blockState{{BLOCK_TYPE_VOID, 0, 0}}
+	chunk.refs = []int{CHUNK_WIDTH * CHUNK_HEIGHT}
+	chunk.indices = newPackedArray(CHUNK_WIDTH * CHUNK_HEIGHT)
 
 	return chunk
 }
 
+func (chunk *Chunk) Coord() ChunkCoord {
+	return chunk.coord
+}
+
+// Set block at the block coord it has and mark the chunk as modified.
+// The block is copied, changing it afterwards doesn't affect the chunk.
+// Tile entity of the block is dropped, without hooks, if the block type changes.
 func (chunk *Chunk) Set(block *Block) {
+	chunk.update(block.coord, func(old *Block) (*Block, error) { return block, nil }, nil)
+}
+
+// What Chunk.update did.
+type blockSwap struct {
+	// Block which was there and the one which is put.
+	old, new *Block
+	changed  bool
+	// Tile entities taken from the old block and given to the new one,
+	// if the block type is changed.
+	removed, placed *tileEntry
+}
+
+// Set the block update makes of the block at coord, as Set does, unless update fails.
+// Update is called with the lock held, so nothing can change the block in between.
+// If the block type changes, the block gets the tile entity newTile makes, if newTile isn't nil.
+func (chunk *Chunk) update(coord BlockCoord, update func(old *Block) (*Block, error), newTile func(BlockType) *tileEntry) (blockSwap, error) {
+	if !coord.Valid() {
+		return blockSwap{}, nil
+	}
+
+	chunk.mu.Lock()
+	defer chunk.mu.Unlock()
+
+	result := blockSwap{old: chunk.palette[chunk.indices.Get(blockIndex(coord))].block(coord)}
+	block, err := update(result.old)
+	if err != nil {
+		return result, err
+	}
+	block.coord = coord
+	result.new = block
+
+	if !chunk.set(block) {
+		return result, nil
+	}
+	result.changed = true
+	chunk.logEdit(block.coord)
+
+	if result.old.BlockType != block.BlockType {
+		if entry, exist := chunk.tileEntities[block.coord]; exist {
+			result.removed = &entry
+			delete(chunk.tileEntities, block.coord)
+		}
+		if newTile != nil {
+			result.placed = newTile(block.BlockType)
+		}
+		if result.placed != nil {
+			chunk.setTileEntity(block.coord, *result.placed)
+		}
+	}
+
+	return result, nil
+}
+
+// Count a change of the block at coord.
+// The chunk has to be locked.
+func (chunk *Chunk) logEdit(coord BlockCoord) {
+	chunk.revision++
+	chunk.dataRevision++
+	chunk.edits = append(chunk.edits, coord)
+	if len(chunk.edits) > CHUNK_EDITS_KEPT {
+		chunk.edits = append(chunk.edits[:0], chunk.edits[1:]...)
+		chunk.editsFrom++
+	}
+}
+
+// The chunk has to be locked, or not shared yet.
+func (chunk *Chunk) setTileEntity(coord BlockCoord, entry tileEntry) {
+	if chunk.tileEntities == nil {
+		chunk.tileEntities = make(map[BlockCoord]tileEntry)
+	}
+	chunk.tileEntities[coord] = entry
+}
+
+// Set block without marking the chunk as modified.
+// Used when the chunk is filled by generator or loader,
+// before the chunk is shared with other goroutines.
+// Return whether the chunk is changed.
+func (chunk *Chunk) set(block *Block) bool {
 	if !block.coord.Valid() {
-		return
+		return false
 	}
 
-	chunk.Blocks[blockIndex(block.coord)] = block
+	i := blockIndex(block.coord)
+	state := blockState{block.BlockType, block.FrontFace, block.State}
+
+	old := chunk.indices.Get(i)
+	if chunk.palette[old] == state {
+		return false
+	}
+
+	index := chunk.paletteIndex(state)
+	switch {
+	case index >= 0:
+	case chunk.refs[old] == 1:
+		// The old state is going to disappear, take over its entry.
+		chunk.palette[old] = state
+		return true
+	default:
+		index = chunk.allocPaletteIndex(state)
+	}
+
+	chunk.refs[old]--
+	chunk.refs[index]++
+	chunk.indices.Set(i, index)
+
+	return true
+}
+
+// Whether the chunk was changed since it was generated, loaded or saved.
+func (chunk *Chunk) Modified() bool {
+	chunk.mu.RLock()
+	defer chunk.mu.RUnlock()
+
+	return chunk.dataRevision != chunk.savedRevision
+}
+
+// Number of changes made to the chunk since it was generated or loaded.
+// Anything made from the blocks, like a mesh, is out of date if the revision differs.
+func (chunk *Chunk) Revision() uint64 {
+	chunk.mu.RLock()
+	defer chunk.mu.RUnlock()
+
+	return chunk.revision
+}
+
+// Blocks changed since revision, in the order they are changed.
+// False if the chunk doesn't know, like when it's too old or the chunk is replaced as a whole.
+func (chunk *Chunk) ChangesSince(revision uint64) ([]BlockCoord, bool) {
+	chunk.mu.RLock()
+	defer chunk.mu.RUnlock()
+
+	if revision < chunk.editsFrom || revision > chunk.revision {
+		return nil, false
+	}
+	return append([]BlockCoord(nil), chunk.edits[revision-chunk.editsFrom:]...), true
+}
+
+// Mark the chunk as modified as a whole, like when it's replaced.
+func (chunk *Chunk) markModified() {
+	chunk.mu.Lock()
+	chunk.revision++
+	chunk.dataRevision++
+	chunk.edits = nil
+	chunk.editsFrom = chunk.revision
+	chunk.mu.Unlock()
+}
+
+// Mark the chunk as saved up to revision, see dataRevision.
+// Changes made while it was being saved keep it modified.
+// Revisions older than the saved one are ignored.
+func (chunk *Chunk) markSaved(revision uint64) {
+	chunk.mu.Lock()
+	if revision > chunk.savedRevision {
+		chunk.savedRevision = revision
+	}
+	chunk.mu.Unlock()
 }
 
+// Get block at given coord.
+// Returned block is a copy, use Set to change the chunk.
 func (chunk *Chunk) At(coord BlockCoord) *Block {
 	if !coord.Valid() {
 		return nil
 	}
 
-	return chunk.Blocks[blockIndex(coord)]
+	chunk.mu.RLock()
+	state := chunk.palette[chunk.indices.Get(blockIndex(coord))]
+	chunk.mu.RUnlock()
+
+	return state.block(coord)
 }
 
+// Call f for every block in row major order.
+// Blocks are copies as in At, taken all at once before f is called.
+// So f may change the chunk.
 func (chunk *Chunk) ForEach(f func(*Block)) {
-	for _, block := range chunk.Blocks {
-		f(block)
+	chunk.mu.RLock()
+	palette := append([]blockState(nil), chunk.palette...)
+	indices := chunk.indices.clone()
+	chunk.mu.RUnlock()
+
+	for i := 0; i < CHUNK_WIDTH*CHUNK_HEIGHT; i++ {
+		state := palette[indices.Get(i)]
+		coord := BlockCoord{uint8(i % CHUNK_WIDTH), uint8(i / CHUNK_WIDTH)}
+
+		f(state.block(coord))
 	}
 }
 
-// Deallocate vao, vbo, ebo and b2body.
-func (chunk *Chunk) Destroy() {
-	chunk.mesh.Destroy()
-	chunk.body.Destroy()
-	chunk.mesh = nil
-	chunk.body = nil
+// Return -1 if state isn't in the palette.
+func (chunk *Chunk) paletteIndex(state blockState) int {
+	for i, entry := range chunk.palette {
+		if entry == state && chunk.refs[i] > 0 {
+			return i
+		}
+	}
+
+	return -1
 }
 
-func (chunk *Chunk) GetAABB() *AABB {
-	if chunk.aabb == nil {
-		x, y := float64(chunk.coord.X*16+8), float64(chunk.coord.Y*16+8)
-		chunk.aabb = &AABB{
-			Center:  Vec2{x, y},
-			HWidth:  8,
-			HHeight: 8,
+func (chunk *Chunk) allocPaletteIndex(state blockState) int {
+	for i, refs := range chunk.refs {
+		if refs == 0 {
+			chunk.palette[i] = state
+			return i
 		}
 	}
 
-	return chunk.aabb
+	chunk.palette = append(chunk.palette, state)
+	chunk.refs = append(chunk.refs, 0)
+
+	return len(chunk.palette) - 1
 }
 
-func (chunk *Chunk) Bake(world *World, dic *BlockTypeDictionary, coord WorldChunkCoord) {
-	if chunk.mesh == nil {
-		chunk.mesh = NewMesh(nil, nil, nil, nil)
+// Remove b2body from the world.
+// Chunk which is not baked is left as it is.
+func (chunk *Chunk) Destroy() {
+	if chunk.collision != nil {
+		chunk.collision.destroy()
 	}
-	BakeBlockStorageMesh(chunk.mesh, chunk, dic)
+	chunk.collision = nil
+}
 
-	if chunk.body == nil {
-		chunk.body = world.CreateBody(STATIC)
-		chunk.body.SetPosition(
-			float64(coord.X*CHUNK_WIDTH),
-			float64(coord.Y*CHUNK_HEIGHT),
-		)
-	}
-	BakeBlockStorageBody(chunk.body, chunk, dic)
+// Chunk coord in the world, which is valid after the chunk is put into a sector.
+func (chunk *Chunk) WorldCoord() WorldChunkCoord {
+	chunk.mu.RLock()
+	defer chunk.mu.RUnlock()
+
+	return CombineWorldChunkCoord(chunk.sectorCoord, chunk.coord)
+}
+
+// Bounds of the chunk in the world, see WorldChunkCoord.AABB.
+func (chunk *Chunk) GetAABB() *AABB {
+	return chunk.WorldCoord().AABB()
+}
+
+// Static body placed at the chunk, without any fixture.
+func newChunkBody(world *World, coord WorldChunkCoord) *Body {
+	body := world.CreateBody(STATIC)
+	body.SetPosition(
+		float64(coord.X*CHUNK_WIDTH),
+		float64(coord.Y*CHUNK_HEIGHT),
+	)
+
+	return body
 }
 
 func blockIndex(coord BlockCoord) int {
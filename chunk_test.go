@@ -0,0 +1,210 @@
+package lostinspace
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func TestChunkPalette(t *testing.T) {
+	chunk := NewChunk(ChunkCoord{0, 0})
+
+	// Every block distinct, then everything back to void.
+	for i := 0; i < CHUNK_WIDTH*CHUNK_HEIGHT; i++ {
+		coord := BlockCoord{uint8(i % CHUNK_WIDTH), uint8(i / CHUNK_WIDTH)}
+		chunk.Set(NewBlock(coord, BlockType(fmt.Sprintf("type_%d", i)), i%4))
+	}
+	if len(chunk.palette) != CHUNK_WIDTH*CHUNK_HEIGHT || chunk.indices.bits != 8 {
+		t.Errorf("Palette: %d entries, %d bits\n", len(chunk.palette), chunk.indices.bits)
+	}
+	chunk.ForEach(func(block *Block) {
+		i := blockIndex(block.coord)
+		if block.BlockType != BlockType(fmt.Sprintf("type_%d", i)) || block.FrontFace != i%4 {
+			t.Errorf("%v: %v\n", block.coord, block)
+		}
+	})
+
+	for i := 0; i < CHUNK_WIDTH*CHUNK_HEIGHT; i++ {
+		coord := BlockCoord{uint8(i % CHUNK_WIDTH), uint8(i / CHUNK_WIDTH)}
+		chunk.Set(NewBlock(coord, BLOCK_TYPE_VOID, 0))
+	}
+	chunk.ForEach(func(block *Block) {
+		if block.BlockType != BLOCK_TYPE_VOID {
+			t.Errorf("%v: %v\n", block.coord, block)
+		}
+	})
+
+	// Free entries are reused, the palette doesn't grow.
+	for i := 0; i < 1000; i++ {
+		chunk.Set(NewBlock(BlockCoord{3, 3}, BlockType(fmt.Sprintf("churn_%d", i)), 0))
+	}
+	if len(chunk.palette) > CHUNK_WIDTH*CHUNK_HEIGHT {
+		t.Errorf("Palette grew to %d entries\n", len(chunk.palette))
+	}
+	if block := chunk.At(BlockCoord{3, 3}); block.BlockType != "churn_999" {
+		t.Errorf("%v\n", block)
+	}
+}
+
+// Sector layout used before chunks had palettes,
+// every block is a separate object.
+func generateLegacySector(seed *Seed, sectorCoord WorldSectorCoord) *legacySector {
+	sector := new(legacySector)
+	for i := range sector.Chunks {
+		chunkCoord := ChunkCoord{uint8(i % SECTOR_WIDTH), uint8(i / SECTOR_WIDTH)}
+		chunk := new(legacyChunk)
+		for j := range chunk.Blocks {
+			blockCoord := BlockCoord{uint8(j % CHUNK_WIDTH), uint8(j / CHUNK_WIDTH)}
+			worldCoord := CombineWorldBlockCoord(sectorCoord, chunkCoord, blockCoord)
+			chunk.Blocks[j] = &legacyBlock{BlockType: string(generateBlockType(seed, worldCoord))}
+		}
+		sector.Chunks[i] = chunk
+	}
+
+	return sector
+}
+
+// Compare generation time, resident memory and file size
+// of palette based chunks against the legacy layout.
+func BenchmarkSectorLayout(b *testing.B) {
+	seed := NewSeed(2)
+	// Sector with asteroids in it, most sectors are empty space.
+	sectorCoord := WorldSectorCoord{0, 0}
+
+	b.Run("palette", func(b *testing.B) {
+		var sector *Sector
+		for i := 0; i < b.N; i++ {
+			sector = GenerateSector(seed, sectorCoord)
+		}
+		b.StopTimer()
+
+		reportHeap(b, func() interface{} { return GenerateSector(seed, sectorCoord) })
+
+		var buf bytes.Buffer
+		if err := EncodeSector(&buf, sector, seed.Number, NewBlockMapping(nil)); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportMetric(float64(buf.Len()), "file-B")
+	})
+
+	b.Run("legacy", func(b *testing.B) {
+		var sector *legacySector
+		for i := 0; i < b.N; i++ {
+			sector = generateLegacySector(seed, sectorCoord)
+		}
+		b.StopTimer()
+
+		reportHeap(b, func() interface{} { return generateLegacySector(seed, sectorCoord) })
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(sector); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportMetric(float64(buf.Len()), "file-B")
+	})
+}
+
+// Report heap bytes and objects which a generated sector keeps alive.
+func reportHeap(b *testing.B, generate func() interface{}) {
+	const count = 4
+	sectors := make([]interface{}, count)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := range sectors {
+		sectors[i] = generate()
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(sectors)
+
+	b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/count, "heap-B/sector")
+	b.ReportMetric(float64(int64(after.HeapObjects)-int64(before.HeapObjects))/count, "objects/sector")
+}
+
+func TestChunkChangesSince(t *testing.T) {
+	chunk := NewChunk(ChunkCoord{0, 0})
+	chunk.Set(NewBlock(BlockCoord{1, 2}, "stone", 0))
+	chunk.Set(NewBlock(BlockCoord{1, 2}, "stone", 0)) // not a change
+	chunk.Set(NewBlock(BlockCoord{3, 4}, "stone", 0))
+
+	changes, ok := chunk.ChangesSince(1)
+	if !ok || len(changes) != 1 || changes[0] != (BlockCoord{3, 4}) {
+		t.Errorf("Changes since 1: %v, %v\n", changes, ok)
+	}
+
+	for i := 0; i < CHUNK_EDITS_KEPT; i++ {
+		chunk.Set(NewBlock(BlockCoord{5, 5}, "stone", i%4))
+	}
+	if _, ok := chunk.ChangesSince(1); ok {
+		t.Errorf("Changes since 1 are known after %d edits\n", CHUNK_EDITS_KEPT)
+	}
+	if changes, ok := chunk.ChangesSince(2); !ok || len(changes) != CHUNK_EDITS_KEPT {
+		t.Errorf("Changes since 2: %d, %v\n", len(changes), ok)
+	}
+
+	revision := chunk.Revision()
+	chunk.markModified()
+	if _, ok := chunk.ChangesSince(revision); ok {
+		t.Errorf("Changes are known after the chunk is replaced\n")
+	}
+	if changes, ok := chunk.ChangesSince(chunk.Revision()); !ok || len(changes) != 0 {
+		t.Errorf("Changes since now: %v, %v\n", changes, ok)
+	}
+}
+
+func TestChunkCollisionUpdate(t *testing.T) {
+	square := []Vec2{{-0.5, 0.5}, {-0.5, -0.5}, {0.5, -0.5}, {0.5, 0.5}}
+	dic := NewBlockTypeDictionary([]*BlockTypeDescriptor{
+		{BlockType: "stone", Density: 0.5, Friction: 0.2, Restitution: 0.01, CollisionVertices: square, Fixed: true},
+	})
+
+	chunk := NewChunk(ChunkCoord{0, 0})
+	chunk.ForEach(func(block *Block) {
+		block.BlockType = "stone"
+		chunk.Set(block)
+	})
+	world := NewWorld()
+	collision := newBlockCollision(newChunkBody(world, WorldChunkCoord{0, 0}), dic, true)
+	collision.bake(chunk)
+	collision.body.Bake()
+	whole := collision.body.fixtures[0]
+
+	fixtures := func() int {
+		count := 0
+		for fix := collision.body.b2body.GetFixtureList(); fix != nil; fix = fix.GetNext() {
+			count++
+		}
+		return count
+	}
+
+	// A hole in the middle splits the chunk into 4 rectangles.
+	chunk.Set(NewBlock(BlockCoord{5, 7}, BLOCK_TYPE_VOID, 0))
+	collision.update(chunk, BlockCoord{5, 7})
+	collision.body.Bake()
+	if count := fixtures(); count != 4 || collision.body.GetFixtureCount() != 4 || whole.b2fixture != nil {
+		t.Errorf("%d fixtures after a block is broken\n", count)
+	}
+	if _, exist := collision.cells[BlockCoord{5, 7}]; exist || len(collision.cells) != 255 {
+		t.Errorf("%d blocks are covered\n", len(collision.cells))
+	}
+
+	// Filling the hole adds a fixture and leaves the others.
+	others := append([]*Fixture(nil), collision.body.fixtures...)
+	chunk.Set(NewBlock(BlockCoord{5, 7}, "stone", 0))
+	collision.update(chunk, BlockCoord{5, 7})
+	collision.body.Bake()
+	if count := fixtures(); count != 5 || len(collision.cells) != 256 {
+		t.Errorf("%d fixtures after the block is placed back\n", count)
+	}
+	for _, fixture := range others {
+		if fixture.b2fixture == nil {
+			t.Errorf("Fixture %v is destroyed\n", fixture)
+		}
+	}
+
+	collision.body.Destroy()
+}
@@ -0,0 +1,233 @@
+package lostinspace
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Chunks are drawn in groups of CHUNK_GROUP_WIDTH*CHUNK_GROUP_HEIGHT chunks.
+// Meshes of chunks in a group are merged into one, so that a group takes one draw call.
+const (
+	CHUNK_GROUP_WIDTH  = 4
+	CHUNK_GROUP_HEIGHT = 4
+)
+
+// Free quads a group mesh has at least for edits, so that it doesn't have to be baked again.
+const CHUNK_GROUP_SPARE_QUADS = 16
+
+type chunkGroupCoord struct {
+	X, Y int64
+}
+
+func chunkGroupCoordOf(coord WorldChunkCoord) chunkGroupCoord {
+	return chunkGroupCoord{
+		X: int64(math.Floor(float64(coord.X) / CHUNK_GROUP_WIDTH)),
+		Y: int64(math.Floor(float64(coord.Y) / CHUNK_GROUP_HEIGHT)),
+	}
+}
+
+// First chunk of the group.
+func (coord chunkGroupCoord) origin() WorldChunkCoord {
+	return WorldChunkCoord{coord.X * CHUNK_GROUP_WIDTH, coord.Y * CHUNK_GROUP_HEIGHT}
+}
+
+// Coord of a block from the first block of the group.
+func (coord chunkGroupCoord) blockCoord(chunkCoord WorldChunkCoord, blockCoord BlockCoord) BlockCoord {
+	origin := coord.origin()
+	return BlockCoord{
+		uint8((chunkCoord.X-origin.X)*CHUNK_WIDTH) + blockCoord.X,
+		uint8((chunkCoord.Y-origin.Y)*CHUNK_HEIGHT) + blockCoord.Y,
+	}
+}
+
+// Bounds of every chunk in the group.
+func (coord chunkGroupCoord) aabb() *AABB {
+	first := coord.origin().AABB()
+	return &AABB{
+		Center: Vec2{
+			first.Center.X + first.HWidth*(CHUNK_GROUP_WIDTH-1),
+			first.Center.Y + first.HHeight*(CHUNK_GROUP_HEIGHT-1),
+		},
+		HWidth:  first.HWidth * CHUNK_GROUP_WIDTH,
+		HHeight: first.HHeight * CHUNK_GROUP_HEIGHT,
+	}
+}
+
+type chunkGroup struct {
+	coord chunkGroupCoord
+	mesh  *Mesh
+	// Chunks and their revisions the mesh is made from.
+	chunks map[WorldChunkCoord]chunkRevision
+	// Model matrix of the group, which doesn't change.
+	translate mgl32.Mat4
+
+	// Rectangle drawn by each quad of the mesh. Quad without any block is free.
+	slots []blockRect
+	free  []int
+	// Slot of the quad covering each block, in block coords of the group.
+	cells map[BlockCoord]int
+}
+
+type chunkRevision struct {
+	chunk    *Chunk
+	revision uint64
+}
+
+func newChunkGroup(coord chunkGroupCoord) *chunkGroup {
+	origin := coord.origin()
+	group := &chunkGroup{
+		coord: coord,
+		mesh:  NewMesh(nil, nil, nil, nil),
+		translate: mgl32.Translate3D(
+			float32(origin.X*CHUNK_WIDTH),
+			float32(origin.Y*CHUNK_HEIGHT),
+			0,
+		),
+	}
+
+	return group
+}
+
+// Whether the mesh is made from the chunks at their current revisions.
+func (group *chunkGroup) upToDate(chunks map[WorldChunkCoord]*Chunk) bool {
+	if len(chunks) != len(group.chunks) {
+		return false
+	}
+	for coord, chunk := range chunks {
+		baked, exist := group.chunks[coord]
+		if !exist || baked.chunk != chunk || baked.revision != chunk.Revision() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Whether the mesh is made from the same chunks, though some of them may be changed since.
+func (group *chunkGroup) sameChunks(chunks map[WorldChunkCoord]*Chunk) bool {
+	if len(chunks) != len(group.chunks) {
+		return false
+	}
+	for coord, chunk := range chunks {
+		if group.chunks[coord].chunk != chunk {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Make the mesh of the chunks, which must be in the group, from scratch.
+// Blocks which look the same are merged even across chunks.
+func (group *chunkGroup) bake(chunks map[WorldChunkCoord]*Chunk, dic *BlockTypeDictionary, backend Backend) {
+	group.chunks = make(map[WorldChunkCoord]chunkRevision, len(chunks))
+	looks := make(map[BlockCoord]interface{})
+	for coord, chunk := range chunks {
+		group.chunks[coord] = chunkRevision{chunk, chunk.Revision()}
+		chunk.ForEach(func(block *Block) {
+			if block.BlockType != BLOCK_TYPE_VOID && !movesAlone(block, dic) {
+				looks[group.coord.blockCoord(coord, block.coord)] = blockLookOf(block, dic)
+			}
+		})
+	}
+	rects := mergeBlockRects(looks, true)
+
+	group.slots = append(rects, make([]blockRect, len(rects)/4+CHUNK_GROUP_SPARE_QUADS)...)
+	group.free = group.free[:0]
+	group.cells = make(map[BlockCoord]int)
+
+	positions := make([]float32, len(group.slots)*4*3)
+	coords := make([]float32, len(group.slots)*4*3)
+	indices := make([]uint32, 0, len(group.slots)*6)
+	for slot, rect := range group.slots {
+		writeBlockQuad(positions[slot*12:], coords[slot*12:], rect)
+		indices = appendQuadIndices(indices, uint32(slot*4))
+
+		if rect.width == 0 {
+			group.free = append(group.free, slot)
+		}
+		for _, cell := range rect.blocks() {
+			group.cells[cell] = slot
+		}
+	}
+
+	group.mesh.Positions = positions
+	group.mesh.TexCoords = coords
+	group.mesh.Indices = indices
+	group.mesh.Bake(backend)
+}
+
+// Apply blocks changed since the mesh is baked to quads of the mesh in place,
+// which must be made from the same chunks.
+// Return false if it can't, then the group has to be baked again.
+func (group *chunkGroup) update(chunks map[WorldChunkCoord]*Chunk, dic *BlockTypeDictionary) bool {
+	touched := make(map[int]bool)
+	for coord, chunk := range chunks {
+		baked := group.chunks[coord]
+		changes, ok := chunk.ChangesSince(baked.revision)
+		if !ok {
+			return false
+		}
+
+		for _, blockCoord := range changes {
+			if !group.updateBlock(group.coord.blockCoord(coord, blockCoord), chunk.At(blockCoord), dic, touched) {
+				return false
+			}
+		}
+		group.chunks[coord] = chunkRevision{chunk, baked.revision + uint64(len(changes))}
+	}
+
+	for slot := range touched {
+		writeBlockQuad(group.mesh.Positions[slot*12:], group.mesh.TexCoords[slot*12:], group.slots[slot])
+		group.mesh.BakeVertices(slot*4, 4)
+	}
+
+	return true
+}
+
+// Split the quad covering the block at cell, then add a quad of the block.
+// Slots changed are marked as touched.
+func (group *chunkGroup) updateBlock(cell BlockCoord, block *Block, dic *BlockTypeDictionary, touched map[int]bool) bool {
+	if slot, exist := group.cells[cell]; exist {
+		rect := group.slots[slot]
+		for _, covered := range rect.blocks() {
+			delete(group.cells, covered)
+		}
+		group.slots[slot] = blockRect{}
+		group.free = append(group.free, slot)
+		touched[slot] = true
+
+		for _, rest := range rect.without(cell) {
+			if !group.place(rest, touched) {
+				return false
+			}
+		}
+	}
+
+	if block.BlockType == BLOCK_TYPE_VOID || movesAlone(block, dic) {
+		return true
+	}
+	return group.place(blockRect{cell, 1, 1, blockLookOf(block, dic)}, touched)
+}
+
+// Put rect into a free slot.
+func (group *chunkGroup) place(rect blockRect, touched map[int]bool) bool {
+	if len(group.free) == 0 {
+		return false
+	}
+
+	slot := group.free[len(group.free)-1]
+	group.free = group.free[:len(group.free)-1]
+	group.slots[slot] = rect
+	for _, cell := range rect.blocks() {
+		group.cells[cell] = slot
+	}
+	touched[slot] = true
+
+	return true
+}
+
+func (group *chunkGroup) destroy() {
+	group.mesh.Destroy()
+}
@@ -0,0 +1,72 @@
+package lostinspace
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Quads updated block by block cover what the chunks have.
+func TestChunkGroupUpdate(t *testing.T) {
+	dic := NewBlockTypeDictionary([]*BlockTypeDescriptor{
+		{BlockType: "stone", Fixed: true}, {BlockType: "test1", Fixed: true},
+	})
+	types := []BlockType{BLOCK_TYPE_VOID, "stone", "test1"}
+
+	chunks := map[WorldChunkCoord]*Chunk{
+		{0, 0}: NewChunk(ChunkCoord{0, 0}),
+		{1, 0}: NewChunk(ChunkCoord{1, 0}),
+	}
+	for _, chunk := range chunks {
+		chunk.ForEach(func(block *Block) {
+			block.BlockType = "stone"
+			chunk.Set(block)
+		})
+	}
+
+	backend := NewRecordingBackend()
+	group := newChunkGroup(chunkGroupCoord{0, 0})
+	group.bake(chunks, dic, backend)
+	if len(group.slots)-len(group.free) != 1 {
+		t.Errorf("%d quads for chunks of stone\n", len(group.slots)-len(group.free))
+	}
+
+	random := rand.New(rand.NewSource(1))
+	updated := 0
+	for i := 0; i < 200; i++ {
+		coord := WorldChunkCoord{int64(random.Intn(2)), 0}
+		blockCoord := BlockCoord{uint8(random.Intn(CHUNK_WIDTH)), uint8(random.Intn(CHUNK_HEIGHT))}
+		chunks[coord].Set(NewBlock(blockCoord, types[random.Intn(len(types))], random.Intn(4)))
+
+		backend.Reset()
+		if group.update(chunks, dic) {
+			updated++
+			if made := backend.Filter("CreateBuffer", "CreateVertexArray", "BufferData"); len(made) != 0 {
+				t.Errorf("%v while updated in place\n", made)
+			}
+		} else {
+			group.bake(chunks, dic, backend)
+		}
+		if !group.upToDate(chunks) {
+			t.Errorf("Group is not up to date after edit %d\n", i)
+		}
+
+		for coord, chunk := range chunks {
+			chunk.ForEach(func(block *Block) {
+				cell := group.coord.blockCoord(coord, block.coord)
+				slot, exist := group.cells[cell]
+				if block.BlockType == BLOCK_TYPE_VOID {
+					if exist {
+						t.Errorf("Void block %v is covered by %v\n", cell, group.slots[slot])
+					}
+					return
+				}
+				if !exist || group.slots[slot].key != blockLookOf(block, dic) {
+					t.Errorf("Block %v is not covered by a quad which looks like it\n", cell)
+				}
+			})
+		}
+	}
+	if updated == 0 {
+		t.Errorf("Group is never updated in place\n")
+	}
+}
@@ -1,26 +1,103 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"image"
+	"image/png"
+	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"time"
 
 	"github.com/go-gl/glfw/v3.2/glfw"
 	"github.com/rlj1202/LostInSpace"
+	"github.com/rlj1202/LostInSpace/desktop"
 )
 
 func main() {
+	universePath := flag.String("universe", "universe", "directory of the universe to play, created if it doesn't exist")
+	name := flag.String("name", "", "name of a new universe, defaults to the directory name")
+	seed := flag.Int64("seed", 0, "seed of a new universe, random if 0")
+	config := lostinspace.DefaultSimulationConfig()
+	flag.Float64Var(&config.Streaming.LoadRadius, "load-radius", config.Streaming.LoadRadius, "chunks closer than this many blocks are loaded")
+	flag.Float64Var(&config.Streaming.UnloadRadius, "unload-radius", config.Streaming.UnloadRadius, "chunks farther than this many blocks are unloaded")
+	flag.Float64Var(&config.Streaming.DrawRadius, "draw-radius", config.Streaming.DrawRadius, "chunks closer than this many blocks are drawn")
+	recordPath := flag.String("record", "", "record input into this file and copy the universe into FILE.universe, which must not exist, streaming synchronously so that it can be replayed")
+	replayPath := flag.String("replay", "", "replay a recording without window on a copy of the universe it started from, then exit")
+	screenshotPath := flag.String("screenshot", "", "draw the universe without window into this png file, then exit")
+	blocksPath := flag.String("blocks", "blocks", "directory of block type files")
+	flag.Parse()
+
+	dic, err := lostinspace.LoadBlockTypes(*blocksPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *replayPath != "" {
+		if err := replay(*replayPath, dic, config); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	universe, err := openUniverse(*universePath, *name, *seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Universe %q at %s, seed %d\n", universe.Manifest.Name, universe.Path(), universe.Manifest.Seed)
+
+	if *screenshotPath != "" {
+		if err := screenshot(*screenshotPath, universe, dic, config); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	const step = time.Second / 60
+	// Copy of the universe the recording starts from.
+	snapshot := *recordPath + ".universe"
+	if *recordPath != "" {
+		config.SyncStreaming = true
+
+		// Never replaced, it may be a universe of its own.
+		if _, err := os.Stat(snapshot); err == nil {
+			log.Fatalf("%s already exists, remove it or record into another file\n", snapshot)
+		}
+	}
+
 	runtime.LockOSThread()
 
 	defer glfw.Terminate()
 
 	icons := icons()
 
-	window := lostinspace.NewWindow(800, 600, "LostInSpace", icons, true)
-	game := lostinspace.NewGame(window, blockTypeDic())
+	window := desktop.NewWindow(800, 600, "LostInSpace", icons, true)
+	sim := lostinspace.NewSimulation(universe, dic, config)
+	width, height := window.GetSize()
+	game := lostinspace.NewGame(sim, desktop.NewGLBackend(width, height), width, height)
+
+	if *recordPath != "" {
+		file, err := os.Create(*recordPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+
+		recorder, err := lostinspace.NewRecorder(file, sim, step, snapshot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				log.Printf("Failed to record: %v\n", err)
+			}
+		}()
+	}
 
+	loop := lostinspace.NewLoop(step, 5)
 	curTime := time.Now()
 	for !window.ShouldClose() {
 		glfw.PollEvents()
@@ -34,22 +111,105 @@ func main() {
 
 		curTime = newTime
 
-		for frameTime > 0 {
-			deltaTime := time.Duration(1.0 / 60.0 * float32(time.Second))
-			if frameTime < deltaTime {
-				deltaTime = frameTime
-			}
+		loop.Advance(frameTime, game.Update)
+
+		game.Render(loop.Alpha())
+
+		window.Update()
+	}
+	game.Destroy()
+}
+
+// Open universe at path or create new one if there is none.
+func openUniverse(path, name string, seed int64) (*lostinspace.Universe, error) {
+	universe, err := lostinspace.OpenUniverse(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return universe, err
+	}
+
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return lostinspace.CreateUniverse(path, name, seed)
+}
 
-			game.Update(deltaTime)
+// Replay recording on a copy of the universe it started from,
+// or on a new one if the recording has none, so that the copy is left as it was.
+func replay(recordingPath string, dic *lostinspace.BlockTypeDictionary, config lostinspace.SimulationConfig) error {
+	file, err := os.Open(recordingPath)
+	if err != nil {
+		return err
+	}
+	recording, err := lostinspace.ReadRecording(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
 
-			frameTime -= deltaTime
+	dir, err := os.MkdirTemp("", "replay")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	universePath := filepath.Join(dir, "universe")
+	if recording.Universe != "" {
+		snapshot := filepath.Join(filepath.Dir(recordingPath), recording.Universe)
+		if err := lostinspace.CopyUniverse(snapshot, universePath); err != nil {
+			return err
 		}
+	}
+	universe, err := openUniverse(universePath, recording.Name, recording.Seed)
+	if err != nil {
+		return err
+	}
 
-		game.Render()
+	config.SyncStreaming = true
+	sim := lostinspace.NewSimulation(universe, dic, config)
+	defer sim.Close()
 
-		window.Update()
+	replayer, err := lostinspace.NewReplayer(recording, sim)
+	if err != nil {
+		return err
 	}
-	game.Destroy()
+	replayer.Run()
+
+	x, y := sim.Player().GetPosition()
+	log.Printf("Replayed %d events in %d steps, player at (%v, %v)\n", len(recording.Events), sim.Tick(), x, y)
+
+	return nil
+}
+
+// Draw the universe around the player by cpu and save it as png.
+func screenshot(path string, universe *lostinspace.Universe, dic *lostinspace.BlockTypeDictionary, config lostinspace.SimulationConfig) error {
+	const width, height = 800, 600
+
+	config.SyncStreaming = true
+	sim := lostinspace.NewSimulation(universe, dic, config)
+	backend := lostinspace.NewSoftwareBackend(width, height)
+	game := lostinspace.NewGame(sim, backend, width, height)
+	defer game.Destroy()
+
+	game.Update(time.Second / 60)
+	// Chunk meshes are made a few per frame, draw until every chunk is drawn.
+	for drawCalls := -1; game.FrameStats().DrawCalls != drawCalls; {
+		drawCalls = game.FrameStats().DrawCalls
+		game.Render(0)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, backend.Image()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
 
 func icons() []image.Image {
@@ -86,92 +246,3 @@ func icons() []image.Image {
 
 	return []image.Image{icon16, icon32, icon64}
 }
-
-func blockTypeDic() *lostinspace.BlockTypeDictionary {
-	stoneTypeTexFile, err := os.Open("stonetile_1.png")
-	if err != nil {
-		panic(err)
-	}
-	stoneTypeDescriptor := lostinspace.BlockTypeDescriptor{
-		BlockType:   "stone",
-		Name:        "Regular old fancy stone",
-		Density:     0.5,
-		Friction:    0.2,
-		Restitution: 0.01,
-		CollisionVertices: []lostinspace.Vec2{
-			{-0.5, 0.5},
-			{-0.5, -0.5},
-			{0.5, -0.5},
-			{0.5, 0.5},
-		},
-		Fixed:       true,
-		TextureFile: stoneTypeTexFile,
-	}
-	test1TypeTexFile, err := os.Open("testtile_1.png")
-	if err != nil {
-		panic(err)
-	}
-	test1TypeDescriptor := lostinspace.BlockTypeDescriptor{
-		BlockType:   "test1",
-		Name:        "Test tile 1",
-		Density:     1.0,
-		Friction:    0.2,
-		Restitution: 0.01,
-		CollisionVertices: []lostinspace.Vec2{
-			{-0.5, 0.5},
-			{-0.5, -0.5},
-			{0.5, -0.5},
-			{0.5, 0.5},
-		},
-		Fixed:       true,
-		TextureFile: test1TypeTexFile,
-	}
-	test2TypeTexFile, err := os.Open("testtile_2.png")
-	if err != nil {
-		panic(err)
-	}
-	test2TypeDescriptor := lostinspace.BlockTypeDescriptor{
-		BlockType:   "test2",
-		Name:        "Test tile 2",
-		Density:     1.0,
-		Friction:    0.2,
-		Restitution: 0.01,
-		CollisionVertices: []lostinspace.Vec2{
-			{-0.5, 0.5},
-			{-0.5, -0.5},
-			{0.5, -0.5},
-			{0.5, 0.5},
-		},
-		Fixed:       true,
-		TextureFile: test2TypeTexFile,
-	}
-	doorTypeTexFile, err := os.Open("door_0.png")
-	if err != nil {
-		panic(err)
-	}
-	doorTypeDescriptor := lostinspace.BlockTypeDescriptor{
-		BlockType:   "door0",
-		Name:        "Test Door",
-		Density:     1.0,
-		Friction:    0.2,
-		Restitution: 0.01,
-		CollisionVertices: []lostinspace.Vec2{
-			{-0.5, 0.25},
-			{-0.5, -0.25},
-			{0.5, -0.25},
-			{0.5, 0.25},
-		},
-		Fixed:       false,
-		TextureFile: doorTypeTexFile,
-	}
-
-	dic := lostinspace.NewBlockTypeDictionary(
-		[]*lostinspace.BlockTypeDescriptor{
-			&stoneTypeDescriptor,
-			&test1TypeDescriptor,
-			&test2TypeDescriptor,
-			&doorTypeDescriptor,
-		})
-
-	return dic
-}
@@ -53,6 +53,16 @@ func CombineWorldBlockCoord(sectorCoord WorldSectorCoord, chunkCoord ChunkCoord,
 	}
 }
 
+// Bounds of the chunk in the world.
+// Blocks are centered at their coords, so a chunk starts half a block before its first block.
+func (coord WorldChunkCoord) AABB() *AABB {
+	return &AABB{
+		Center:  Vec2{float64(coord.X*CHUNK_WIDTH) + (CHUNK_WIDTH-1)/2.0, float64(coord.Y*CHUNK_HEIGHT) + (CHUNK_HEIGHT-1)/2.0},
+		HWidth:  CHUNK_WIDTH / 2.0,
+		HHeight: CHUNK_HEIGHT / 2.0,
+	}
+}
+
 func (coord *WorldChunkCoord) Parse() (WorldSectorCoord, ChunkCoord) {
 	sectorCoord := WorldSectorCoord{
 		X: int64(math.Floor(float64(coord.X) / float64(SECTOR_WIDTH))),
@@ -64,3 +64,22 @@ func TestWorldCoord(t *testing.T) {
 	a, b := test.Parse()
 	t.Log(a, b)
 }
+
+func TestChunkAABB(t *testing.T) {
+	sector := lostinspace.NewSector(lostinspace.WorldSectorCoord{X: -1, Y: 2})
+	chunk := lostinspace.NewChunk(lostinspace.ChunkCoord{X: 15, Y: 0})
+	sector.Set(chunk)
+
+	// Blocks -16 to -1 and 512 to 527.
+	aabb := chunk.GetAABB()
+	expected := lostinspace.AABB{Center: lostinspace.Vec2{X: -8.5, Y: 519.5}, HWidth: 8, HHeight: 8}
+	if *aabb != expected {
+		t.Errorf("%+v, expected %+v\n", *aabb, expected)
+	}
+
+	block := &lostinspace.AABB{Center: lostinspace.Vec2{X: -1, Y: 512}, HWidth: 0.5, HHeight: 0.5}
+	next := &lostinspace.AABB{Center: lostinspace.Vec2{X: 0, Y: 512}, HWidth: 0.5, HHeight: 0.5}
+	if !aabb.Collide(block) || aabb.Collide(next) {
+		t.Errorf("Bounds don't match blocks\n")
+	}
+}
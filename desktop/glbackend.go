@@ -0,0 +1,293 @@
+// Package desktop runs the game in a window, drawing by opengl.
+// It needs cgo, unlike the game itself.
+package desktop
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-compatibility/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/rlj1202/LostInSpace"
+)
+
+// GLBackend draws by opengl 4.1, into a multisampled frame which is resolved to the screen.
+type GLBackend struct {
+	width  int
+	height int
+
+	msaaTex uint32
+	msaaFbo uint32
+
+	textureTargets map[lostinspace.TextureID]uint32
+	indexTypes     map[lostinspace.BufferID]uint32
+	indexBuffers   map[lostinspace.VertexArrayID]lostinspace.BufferID
+}
+
+// Opengl context must be current, see NewWindow.
+func NewGLBackend(width, height int) *GLBackend {
+	backend := &GLBackend{
+		textureTargets: make(map[lostinspace.TextureID]uint32),
+		indexTypes:     make(map[lostinspace.BufferID]uint32),
+		indexBuffers:   make(map[lostinspace.VertexArrayID]lostinspace.BufferID),
+	}
+
+	gl.ClearColor(0, 0, 0.1, 1)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.Enable(gl.LINE_SMOOTH)
+	gl.Enable(gl.MULTISAMPLE)
+	gl.Hint(gl.LINE_SMOOTH_HINT, gl.NICEST)
+
+	gl.GenTextures(1, &backend.msaaTex)
+	gl.GenFramebuffers(1, &backend.msaaFbo)
+	backend.Resize(width, height)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, backend.msaaFbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D_MULTISAMPLE, backend.msaaTex, 0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	return backend
+}
+
+func (backend *GLBackend) CreateBuffer() lostinspace.BufferID {
+	var buffer uint32
+	gl.GenBuffers(1, &buffer)
+
+	return lostinspace.BufferID(buffer)
+}
+
+func (backend *GLBackend) BufferData(buffer lostinspace.BufferID, data interface{}) {
+	var size int
+	switch data := data.(type) {
+	case []float32:
+		size = len(data) * 4
+	case []uint16:
+		size = len(data) * 2
+		backend.indexTypes[buffer] = gl.UNSIGNED_SHORT
+	case []uint32:
+		size = len(data) * 4
+		backend.indexTypes[buffer] = gl.UNSIGNED_INT
+	default:
+		panic(fmt.Errorf("Unsupported buffer data %T.", data))
+	}
+
+	ptr := gl.Ptr(nil)
+	if size > 0 {
+		ptr = gl.Ptr(data)
+	}
+	// Any target is fine to upload, array buffer doesn't touch the bound vertex array.
+	gl.BindBuffer(gl.ARRAY_BUFFER, uint32(buffer))
+	gl.BufferData(gl.ARRAY_BUFFER, size, ptr, gl.DYNAMIC_DRAW)
+}
+
+func (backend *GLBackend) BufferSubData(buffer lostinspace.BufferID, offset int, data interface{}) {
+	var size, elementSize int
+	switch data := data.(type) {
+	case []float32:
+		size, elementSize = len(data)*4, 4
+	case []uint16:
+		size, elementSize = len(data)*2, 2
+	case []uint32:
+		size, elementSize = len(data)*4, 4
+	default:
+		panic(fmt.Errorf("Unsupported buffer data %T.", data))
+	}
+	if size == 0 {
+		return
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, uint32(buffer))
+	gl.BufferSubData(gl.ARRAY_BUFFER, offset*elementSize, size, gl.Ptr(data))
+}
+
+func (backend *GLBackend) DeleteBuffer(buffer lostinspace.BufferID) {
+	id := uint32(buffer)
+	gl.DeleteBuffers(1, &id)
+	delete(backend.indexTypes, buffer)
+}
+
+func (backend *GLBackend) CreateVertexArray() lostinspace.VertexArrayID {
+	var vertexArray uint32
+	gl.GenVertexArrays(1, &vertexArray)
+
+	return lostinspace.VertexArrayID(vertexArray)
+}
+
+func (backend *GLBackend) VertexAttrib(vertexArray lostinspace.VertexArrayID, attrib uint32, buffer lostinspace.BufferID) {
+	gl.BindVertexArray(uint32(vertexArray))
+	if buffer == 0 {
+		gl.DisableVertexAttribArray(attrib)
+		return
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, uint32(buffer))
+	gl.EnableVertexAttribArray(attrib)
+	gl.VertexAttribPointer(attrib, 3, gl.FLOAT, false, 3*4, gl.PtrOffset(0))
+}
+
+func (backend *GLBackend) IndexBuffer(vertexArray lostinspace.VertexArrayID, buffer lostinspace.BufferID) {
+	gl.BindVertexArray(uint32(vertexArray))
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, uint32(buffer))
+	backend.indexBuffers[vertexArray] = buffer
+}
+
+func (backend *GLBackend) DeleteVertexArray(vertexArray lostinspace.VertexArrayID) {
+	id := uint32(vertexArray)
+	gl.DeleteVertexArrays(1, &id)
+	delete(backend.indexBuffers, vertexArray)
+}
+
+func (backend *GLBackend) CreateTexture2D(img *image.RGBA) lostinspace.TextureID {
+	var tex uint32
+	gl.GenTextures(1, &tex)
+	gl.BindTexture(gl.TEXTURE_2D, tex)
+
+	size := img.Rect.Size()
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(size.X), int32(size.Y), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+
+	backend.textureTargets[lostinspace.TextureID(tex)] = gl.TEXTURE_2D
+
+	return lostinspace.TextureID(tex)
+}
+
+func (backend *GLBackend) CreateTexture2DArray(width, height int32, layers []*image.RGBA) lostinspace.TextureID {
+	var tex uint32
+	gl.GenTextures(1, &tex)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, tex)
+
+	gl.TexStorage3D(gl.TEXTURE_2D_ARRAY, 1, gl.RGBA8, width, height, int32(len(layers)))
+	for i, layer := range layers {
+		if layer == nil {
+			continue
+		}
+		size := layer.Rect.Size()
+		gl.TexSubImage3D(gl.TEXTURE_2D_ARRAY, 0, 0, 0, int32(i), int32(size.X), int32(size.Y), 1, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(layer.Pix))
+	}
+
+	// Repeat for quads of blocks merged into one.
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+
+	backend.textureTargets[lostinspace.TextureID(tex)] = gl.TEXTURE_2D_ARRAY
+
+	return lostinspace.TextureID(tex)
+}
+
+func (backend *GLBackend) BindTexture(unit uint32, texture lostinspace.TextureID) {
+	gl.ActiveTexture(gl.TEXTURE0 + unit)
+	gl.BindTexture(backend.textureTargets[texture], uint32(texture))
+}
+
+func (backend *GLBackend) DeleteTexture(texture lostinspace.TextureID) {
+	id := uint32(texture)
+	gl.DeleteTextures(1, &id)
+	delete(backend.textureTargets, texture)
+}
+
+func (backend *GLBackend) CreateProgram(vertexSource, fragmentSource string) (lostinspace.ProgramID, error) {
+	vertexShader, err := compileShader(vertexSource, gl.VERTEX_SHADER)
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteShader(vertexShader)
+	fragmentShader, err := compileShader(fragmentSource, gl.FRAGMENT_SHADER)
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteShader(fragmentShader)
+
+	program := gl.CreateProgram()
+	gl.AttachShader(program, vertexShader)
+	gl.AttachShader(program, fragmentShader)
+	gl.LinkProgram(program)
+
+	return lostinspace.ProgramID(program), nil
+}
+
+func compileShader(rawSource string, shaderType uint32) (uint32, error) {
+	rawSource += "\x00"
+	shader := gl.CreateShader(shaderType)
+
+	source, free := gl.Strs(rawSource)
+	gl.ShaderSource(shader, 1, source, nil)
+	free()
+	gl.CompileShader(shader)
+
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
+
+		return 0, fmt.Errorf("Failed to compile shader %v: %v\n", source, log)
+	}
+
+	return shader, nil
+}
+
+func (backend *GLBackend) UseProgram(program lostinspace.ProgramID) {
+	gl.UseProgram(uint32(program))
+}
+
+func (backend *GLBackend) UniformInt(program lostinspace.ProgramID, name string, value int32) {
+	loc := gl.GetUniformLocation(uint32(program), gl.Str(name+"\x00"))
+	gl.ProgramUniform1i(uint32(program), loc, value)
+}
+
+func (backend *GLBackend) UniformMat4(program lostinspace.ProgramID, name string, value mgl32.Mat4) {
+	loc := gl.GetUniformLocation(uint32(program), gl.Str(name+"\x00"))
+	gl.ProgramUniformMatrix4fv(uint32(program), loc, 1, false, &(value[0]))
+}
+
+func (backend *GLBackend) DeleteProgram(program lostinspace.ProgramID) {
+	gl.DeleteProgram(uint32(program))
+}
+
+func (backend *GLBackend) Resize(width, height int) {
+	backend.width = width
+	backend.height = height
+
+	gl.BindTexture(gl.TEXTURE_2D_MULTISAMPLE, backend.msaaTex)
+	gl.TexImage2DMultisample(gl.TEXTURE_2D_MULTISAMPLE, 4, gl.RGBA8, int32(width), int32(height), false)
+
+	gl.Viewport(0, 0, int32(width), int32(height))
+}
+
+// Frame is drawn to the fbo for multisampling.
+func (backend *GLBackend) BeginFrame() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, backend.msaaFbo)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+}
+
+func (backend *GLBackend) DrawElements(vertexArray lostinspace.VertexArrayID, count int32) {
+	indexType, exist := backend.indexTypes[backend.indexBuffers[vertexArray]]
+	if !exist {
+		return
+	}
+
+	gl.BindVertexArray(uint32(vertexArray))
+	gl.DrawElements(gl.TRIANGLES, count, indexType, gl.PtrOffset(0))
+}
+
+// Resolve the multisampled frame to the screen.
+func (backend *GLBackend) EndFrame() {
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, backend.msaaFbo)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
+	gl.BlitFramebuffer(
+		0, 0, int32(backend.width), int32(backend.height),
+		0, 0, int32(backend.width), int32(backend.height), gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
@@ -1,4 +1,4 @@
-package lostinspace
+package desktop
 
 import (
 	"image"
@@ -6,19 +6,20 @@ import (
 
 	"github.com/go-gl/gl/v4.1-compatibility/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/rlj1202/LostInSpace"
 )
 
 type Window struct {
 	nativeWindow *glfw.Window
 }
 
-func init() {
+// Glfw is initialized here rather than when the package is loaded,
+// so that the package works without any display.
+func NewWindow(width, height int, title string, icons []image.Image, resizable bool) *Window {
 	if err := glfw.Init(); err != nil {
 		panic(err)
 	}
-}
 
-func NewWindow(width, height int, title string, icons []image.Image, resizable bool) *Window {
 	if resizable {
 		glfw.WindowHint(glfw.Resizable, glfw.True)
 	} else {
@@ -51,13 +52,6 @@ func NewWindow(width, height int, title string, icons []image.Image, resizable b
 	version := gl.GoStr(gl.GetString(gl.VERSION))
 	log.Println("Opengl version", version)
 
-	gl.ClearColor(0, 0, 0.1, 1)
-	gl.Enable(gl.BLEND)
-	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
-	gl.Enable(gl.LINE_SMOOTH)
-	gl.Enable(gl.MULTISAMPLE)
-	gl.Hint(gl.LINE_SMOOTH_HINT, gl.NICEST)
-
 	newWindow := &Window{
 		nativeWindow: window,
 	}
@@ -86,47 +80,47 @@ func (window *Window) GetSize() (int, int) {
 }
 
 func keyInput(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
-	PushEvent(KeyboardEvent{
-		Key:      Key(key),
+	lostinspace.PushEvent(lostinspace.KeyboardEvent{
+		Key:      lostinspace.Key(key),
 		Scancode: scancode,
-		Action:   Action(action),
-		Mods:     ModifierKey(mods),
+		Action:   lostinspace.Action(action),
+		Mods:     lostinspace.ModifierKey(mods),
 	})
 }
 
 func mouseInput(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mod glfw.ModifierKey) {
 	xoff, yoff := w.GetCursorPos()
-	PushEvent(MouseEvent{
+	lostinspace.PushEvent(lostinspace.MouseEvent{
 		XPos:   xoff,
 		YPos:   yoff,
-		Button: MouseButton(button),
-		Action: Action(action),
-		Mod:    ModifierKey(mod),
+		Button: lostinspace.MouseButton(button),
+		Action: lostinspace.Action(action),
+		Mod:    lostinspace.ModifierKey(mod),
 	})
 }
 
 func scrollInput(w *glfw.Window, xoff, yoff float64) {
-	PushEvent(ScrollEvent{
+	lostinspace.PushEvent(lostinspace.ScrollEvent{
 		XOff: xoff,
 		YOff: yoff,
 	})
 }
 
 func cursorEnterInput(w *glfw.Window, entered bool) {
-	PushEvent(CursorEnterEvent{
+	lostinspace.PushEvent(lostinspace.CursorEnterEvent{
 		Entered: entered,
 	})
 }
 
 func cursorPosInput(w *glfw.Window, xpos, ypos float64) {
-	PushEvent(CursorPosEvent{
+	lostinspace.PushEvent(lostinspace.CursorPosEvent{
 		XPos: xpos,
 		YPos: ypos,
 	})
 }
 
 func sizeInput(w *glfw.Window, width, height int) {
-	PushEvent(WindowSizeEvent{
+	lostinspace.PushEvent(lostinspace.WindowSizeEvent{
 		Width:  width,
 		Height: height,
 	})
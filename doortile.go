@@ -0,0 +1,102 @@
+package lostinspace
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// Distance from the player to the center of a door which opens it.
+	DOOR_OPEN_DISTANCE = 2.5
+	// How long a door stays open after the player leaves.
+	DOOR_CLOSE_DELAY = 2 * time.Second
+)
+
+func init() {
+	RegisterTileEntity("door", func() TileEntity { return new(DoorTile) })
+}
+
+// DoorTile opens its door when the player comes near and closes it after DOOR_CLOSE_DELAY
+// once the player is gone. The block type needs bool property "open",
+// which makes the door slide by its joint, see BlockJoint.
+type DoorTile struct {
+	TileEntityHooks
+
+	mu sync.Mutex
+	// Time left until the door closes, while it's open and the player isn't near.
+	closeIn time.Duration
+}
+
+func (door *DoorTile) OnTick(ctx TileContext) {
+	if ctx.Simulation == nil {
+		return
+	}
+	block := ctx.Terrain.GetBlock(ctx.Coord)
+	if block == nil {
+		return
+	}
+	des := ctx.Simulation.dic.Get(block.BlockType)
+	if des == nil {
+		return
+	}
+
+	x, y := ctx.Simulation.player.GetPosition()
+	near := math.Hypot(x-float64(ctx.Coord.X), y-float64(ctx.Coord.Y)) <= DOOR_OPEN_DISTANCE
+	open := des.Bool(block.State, "open")
+
+	door.mu.Lock()
+	closeIn := door.closeIn
+	shouldOpen := open
+	switch {
+	case near:
+		door.closeIn = DOOR_CLOSE_DELAY
+		shouldOpen = true
+	case open:
+		door.closeIn -= ctx.DeltaTime
+		if door.closeIn <= 0 {
+			door.closeIn = 0
+			shouldOpen = false
+		}
+	}
+	countdown := door.closeIn != closeIn
+	door.mu.Unlock()
+
+	// Saved with the sector, so that the door closes in time after it's loaded again.
+	if countdown {
+		ctx.MarkModified()
+	}
+
+	if shouldOpen == open {
+		return
+	}
+	// Block types without the property stay as they are.
+	state, err := des.WithBool(block.State, "open", shouldOpen)
+	if err != nil {
+		return
+	}
+	ctx.Terrain.SetBlockState(ctx.Coord, state)
+}
+
+func (door *DoorTile) MarshalBinary() ([]byte, error) {
+	door.mu.Lock()
+	defer door.mu.Unlock()
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutVarint(buf, int64(door.closeIn/time.Millisecond))], nil
+}
+
+func (door *DoorTile) UnmarshalBinary(data []byte) error {
+	millis, n := binary.Varint(data)
+	if n <= 0 {
+		return errors.New("invalid door data")
+	}
+
+	door.mu.Lock()
+	door.closeIn = time.Duration(millis) * time.Millisecond
+	door.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package lostinspace_test
+
+import (
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+func TestDoorTile(t *testing.T) {
+	square := []lostinspace.Vec2{{X: -0.5, Y: 0.5}, {X: -0.5, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: 0.5, Y: 0.5}}
+	door := &lostinspace.BlockTypeDescriptor{
+		BlockType: "door", Density: 1, CollisionVertices: square, TileEntity: "door",
+		Joint:      &lostinspace.BlockJoint{Axis: lostinspace.Vec2{X: 1}, Upper: 0.9, MotorSpeed: 2, MaxMotorForce: 50},
+		Properties: []lostinspace.BlockProperty{{Name: "open", Kind: lostinspace.BLOCK_PROPERTY_BOOL}},
+	}
+	universe, err := lostinspace.CreateUniverse(t.TempDir(), "test", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := lostinspace.DefaultSimulationConfig()
+	config.SyncStreaming = true
+	sim := lostinspace.NewSimulation(universe, lostinspace.NewBlockTypeDictionary([]*lostinspace.BlockTypeDescriptor{door}), config)
+	defer sim.Close()
+	sim.Step(testStep)
+
+	coord := lostinspace.WorldBlockCoord{X: 8, Y: 3}
+	sim.Terrain().BreakBlock(coord)
+	if err := sim.Terrain().PlaceBlock(coord, "door", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sim.Terrain().TileEntity(coord).(*lostinspace.DoorTile); !ok {
+		t.Fatalf("Tile entity: %v\n", sim.Terrain().TileEntity(coord))
+	}
+	open := func() bool {
+		block := sim.Terrain().GetBlock(coord)
+		return door.Bool(block.State, "open")
+	}
+
+	sim.Player().SetPosition(20, 3)
+	sim.Step(testStep)
+	if open() {
+		t.Errorf("Door is open while the player is far\n")
+	}
+
+	sim.Player().SetPosition(10, 3)
+	sim.Step(testStep)
+	if !open() {
+		t.Errorf("Door is closed while the player is near\n")
+	}
+
+	// Closed a while after the player leaves.
+	sim.Player().SetPosition(20, 3)
+	steps := int(lostinspace.DOOR_CLOSE_DELAY / testStep)
+	sector := sim.Terrain().GetSector(lostinspace.WorldSectorCoord{X: 0, Y: 0})
+	for i := 0; i < steps-1; i++ {
+		// Countdown is saved as it goes.
+		if i == steps/2 {
+			if err := universe.SaveSector(sector); err != nil {
+				t.Fatal(err)
+			}
+			if err := universe.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sim.Step(testStep)
+		if i == steps/2 && !sector.Modified() {
+			t.Errorf("Door counting down doesn't modify its sector\n")
+		}
+	}
+	if !open() {
+		t.Errorf("Door is closed before the delay\n")
+	}
+	sim.Step(testStep)
+	sim.Step(testStep)
+	if open() {
+		t.Errorf("Door is open after the delay\n")
+	}
+}
@@ -1,10 +1,14 @@
 package lostinspace
 
+import "math"
+
 type BlockEntity struct {
 	blocks map[BlockCoord]*Block
 
 	*Mesh
 	*Body
+	// Fixtures of the body and bodies of non-fixed blocks, made when it's baked.
+	collision *blockCollision
 }
 
 func NewBlockEntity(world *World) *BlockEntity {
@@ -30,20 +34,64 @@ func (entity *BlockEntity) ForEach(f func(*Block)) {
 	}
 }
 
-func (entity *BlockEntity) Bake(world *World, dic *BlockTypeDictionary) {
-	BakeBlockStorageMesh(entity.Mesh, entity, dic)
-	entity.Mesh.Bake()
+// Bounds of blocks in the world, rotated and moved by the body.
+func (entity *BlockEntity) GetAABB() *AABB {
+	return entity.GetInterpolatedAABB(1)
+}
+
+// Bounds of blocks at the body interpolated by alpha, see Body.GetInterpolatedPosition.
+// Nil if there is no block.
+func (entity *BlockEntity) GetInterpolatedAABB(alpha float64) *AABB {
+	if len(entity.blocks) == 0 {
+		return nil
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for coord := range entity.blocks {
+		minX = math.Min(minX, float64(coord.X)-0.5)
+		minY = math.Min(minY, float64(coord.Y)-0.5)
+		maxX = math.Max(maxX, float64(coord.X)+0.5)
+		maxY = math.Max(maxY, float64(coord.Y)+0.5)
+	}
+
+	// Half size of the rotated rectangle, around its rotated center.
+	angle := entity.Body.GetInterpolatedAngle(alpha)
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	hw, hh := (maxX-minX)/2, (maxY-minY)/2
+	cx, cy := (minX+maxX)/2, (minY+maxY)/2
+	x, y := entity.Body.GetInterpolatedPosition(alpha)
 
-	entity.Body.Clear()
-	BakeBlockStorageBody(entity.Body, entity, dic)
-	entity.Body.Bake()
+	return &AABB{
+		Center:  Vec2{x + cx*cos - cy*sin, y + cx*sin + cy*cos},
+		HWidth:  hw*math.Abs(cos) + hh*math.Abs(sin),
+		HHeight: hw*math.Abs(sin) + hh*math.Abs(cos),
+	}
+}
+
+func (entity *BlockEntity) Bake(world *World, dic *BlockTypeDictionary, backend Backend) {
+	BakeBlockStorageMesh(entity.Mesh, entity, dic, true)
+	entity.Mesh.Bake(backend)
+
+	// Non-fixed blocks are joined where they are now, so place the entity before it's baked.
+	if entity.collision != nil {
+		entity.collision.destroyMovers()
+	}
+	entity.collision = newBlockCollision(entity.Body, dic, true)
+	entity.collision.bake(entity)
+	entity.collision.bakeBodies()
 }
 
 func (entity *BlockEntity) Destroy() {
 	entity.blocks = nil
 
 	entity.Mesh.Destroy()
-	entity.Body.Destroy()
+	if entity.collision != nil {
+		entity.collision.destroy()
+	} else {
+		entity.Body.Destroy()
+	}
 	entity.Mesh = nil
 	entity.Body = nil
+	entity.collision = nil
 }
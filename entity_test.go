@@ -0,0 +1,36 @@
+package lostinspace_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+func TestBlockEntityAABB(t *testing.T) {
+	world := lostinspace.NewWorld()
+	entity := lostinspace.NewBlockEntity(world)
+	if entity.GetAABB() != nil {
+		t.Errorf("Bounds of empty entity\n")
+	}
+
+	// 3x1 blocks, from -0.5 to 2.5.
+	for x := uint8(0); x < 3; x++ {
+		entity.Set(lostinspace.NewBlock(lostinspace.BlockCoord{X: x, Y: 0}, "stone", 0))
+	}
+	entity.Bake(world, testBlockTypeDic(), lostinspace.NewRecordingBackend())
+	entity.SetPosition(10, 20)
+
+	aabb := entity.GetAABB()
+	if aabb.Center != (lostinspace.Vec2{X: 11, Y: 20}) || aabb.HWidth != 1.5 || aabb.HHeight != 0.5 {
+		t.Errorf("Bounds: %+v\n", *aabb)
+	}
+
+	// Standing up after turned by 90 degrees.
+	entity.SetAngle(math.Pi / 2)
+	aabb = entity.GetAABB()
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if !near(aabb.Center.X, 10) || !near(aabb.Center.Y, 21) || !near(aabb.HWidth, 0.5) || !near(aabb.HHeight, 1.5) {
+		t.Errorf("Bounds after turned: %+v\n", *aabb)
+	}
+}
@@ -1,5 +1,7 @@
 package lostinspace
 
+import "sync"
+
 type Event interface {
 	Name() string
 }
@@ -8,6 +10,8 @@ type EventListener interface {
 	OnEvent(Event)
 }
 
+// Guards listeners and the queue, events can be pushed from any goroutine.
+var eventsMu sync.Mutex
 var eventlisteners []EventListener
 var events []Event
 
@@ -18,12 +22,17 @@ func init() {
 
 // Push event to queue.
 func PushEvent(event Event) {
+	eventsMu.Lock()
 	events = append(events, event)
+	eventsMu.Unlock()
 }
 
 // Pop event from queue.
 // Return nil if there is no more events remaining in queue.
 func popEvent() Event {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+
 	if len(events) == 0 {
 		return nil
 	}
@@ -33,13 +42,42 @@ func popEvent() Event {
 	return event
 }
 
+// Drop events remaining in queue.
+func clearEvents() {
+	eventsMu.Lock()
+	events = events[:0]
+	eventsMu.Unlock()
+}
+
 func RegisterEventListener(listener EventListener) {
+	eventsMu.Lock()
 	eventlisteners = append(eventlisteners, listener)
+	eventsMu.Unlock()
+}
+
+func UnregisterEventListener(listener EventListener) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+
+	// Copied, PollEvents may be going through the old slice.
+	eventlisteners = append([]EventListener(nil), eventlisteners...)
+	for i, registered := range eventlisteners {
+		if registered == listener {
+			eventlisteners = append(eventlisteners[:i], eventlisteners[i+1:]...)
+			return
+		}
+	}
 }
 
+// Pass queued events to listeners, including ones pushed by listeners meanwhile.
+// Listeners are called without the lock, so they can push events and register listeners.
 func PollEvents() {
 	for event := popEvent(); event != nil; event = popEvent() {
-		for _, listener := range eventlisteners {
+		eventsMu.Lock()
+		listeners := eventlisteners
+		eventsMu.Unlock()
+
+		for _, listener := range listeners {
 			listener.OnEvent(event)
 		}
 	}
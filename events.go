@@ -34,6 +34,23 @@ type WindowSizeEvent struct {
 	Height int
 }
 
+// Ask the simulation to put a block at world coord.
+// Edits go through the event queue so that they are recorded and replayed with the input.
+type EditBlockEvent struct {
+	Coord     WorldBlockCoord
+	BlockType BlockType
+	FrontFace int
+}
+
+// Block at world coord is changed, whoever changed it.
+// Old and New are copies, whose coords are in the chunk.
+// It isn't queued, see BlockListener.
+type BlockChangedEvent struct {
+	Coord WorldBlockCoord
+	Old   Block
+	New   Block
+}
+
 func (event KeyboardEvent) Name() string {
 	return "keyboardEvent"
 }
@@ -57,3 +74,7 @@ func (event CursorPosEvent) Name() string {
 func (event WindowSizeEvent) Name() string {
 	return "windowSizeEvent"
 }
+
+func (event EditBlockEvent) Name() string {
+	return "editBlockEvent"
+}
@@ -6,8 +6,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/go-gl/gl/v4.1-compatibility/gl"
-	"github.com/go-gl/glfw/v3.2/glfw"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
@@ -69,26 +67,26 @@ const (
 	`
 )
 
+// Game draws a simulation by a backend and edits it by mouse.
 type Game struct {
-	window  *Window
-	world   *World
-	terrain *Terrain
+	backend Backend
+	width   int
+	height  int
+	sim     *Simulation
 	dic     *BlockTypeDictionary
 	player  *Player
 	camera  *Camera
 
-	quit              chan bool
-	bakeChunkQueue    chan *Chunk
-	destroyChunkQueue chan *Chunk
-	chunksToDraw      map[WorldChunkCoord]*Chunk
+	// Merged meshes of chunks being drawn.
+	chunkGroups map[chunkGroupCoord]*chunkGroup
+	// Meshes of non-fixed blocks being drawn, each at its own body.
+	moverMeshes map[*blockMover]*moverMesh
 
+	renderer  *Renderer
 	shader    *ShaderProgram
 	entity    *BlockEntity
 	newEntity *BlockEntity
 
-	msaaTex uint32
-	msaaFbo uint32
-
 	bgTex   Texture
 	quad    *Mesh
 	bgRatio float32
@@ -96,7 +94,19 @@ type Game struct {
 	bgHv    float32
 }
 
-func NewGame(window *Window, dic *BlockTypeDictionary) *Game {
+// Quad of a non-fixed block around its body and how it looks.
+type moverMesh struct {
+	look blockLook
+	mesh *Mesh
+}
+
+// Number of chunk groups made, or merged again for loaded or unloaded chunks, per frame.
+// Adjusting it keeps the game going without hitches.
+const chunkGroupsPerFrame = 1
+
+// Frames are width*height pixels until a WindowSizeEvent comes.
+func NewGame(sim *Simulation, backend Backend, width, height int) *Game {
+	dic := sim.dic
 	for _, desc := range dic.data {
 		log.Printf("%s\n", desc)
 	}
@@ -105,57 +115,37 @@ func NewGame(window *Window, dic *BlockTypeDictionary) *Game {
 	if err != nil {
 		panic(err)
 	}
-	playerTex := NewTexture2D(playerTexFile)
+	playerTex := NewTexture2D(backend, playerTexFile)
 
 	game := new(Game)
-	game.window = window
-	game.world = NewWorld()
-	game.terrain = NewTerrain()
-	game.terrain.Seed = NewSeed(2)
+	game.backend = backend
+	game.width = width
+	game.height = height
+	game.sim = sim
 	game.dic = dic
-	game.dic.arrayTex.Bind(1)
-	game.player = NewPlayer(game.world, playerTex)
-	game.player.Mesh.Bake()
-	width, height := glfw.GetCurrentContext().GetSize()
+	game.player = NewPlayer(sim.Player(), playerTex)
+	game.player.Mesh.Bake(backend)
 	game.camera = NewCamera(20, 20*float64(height)/float64(width))
 	game.camera.SetTarget(game.player.Body)
-
-	game.bakeChunkQueue = make(chan *Chunk, 16*16)
-	game.destroyChunkQueue = make(chan *Chunk, 16*16)
-	game.quit = make(chan bool)
-	go chunkListGenerator(game, 9)
-	go sectorManager(game)
-
-	// start: for msaa
-	gl.GenTextures(1, &game.msaaTex)
-	gl.BindTexture(gl.TEXTURE_2D_MULTISAMPLE, game.msaaTex)
-	gl.TexImage2DMultisample(gl.TEXTURE_2D_MULTISAMPLE, 4, gl.RGBA8, int32(width), int32(height), false)
-
-	gl.GenFramebuffers(1, &game.msaaFbo)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, game.msaaFbo)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D_MULTISAMPLE, game.msaaTex, 0)
-	// end: for msaa
+	game.chunkGroups = make(map[chunkGroupCoord]*chunkGroup)
+	game.moverMeshes = make(map[*blockMover]*moverMesh)
+	game.renderer = NewRenderer(backend)
 
 	bgTexFile, err := os.Open("bg_starfield.png")
 	if err != nil {
 		panic(err)
 	}
-	game.bgTex = NewTexture2D(bgTexFile)
+	game.bgTex = NewTexture2D(backend, bgTexFile)
 	game.bakeBackgroundQuad()
 
-	game.shader = NewShaderProgram(vs, fs)
+	game.shader = NewShaderProgram(backend, vs, fs)
 	game.shader.UniformInt("tex2D", 0)
 	game.shader.UniformInt("tex2DArray", 1)
-	game.shader.UniformMat4("projection", game.camera.GetProjectionMat())
-	game.shader.UniformMat4("camera", mgl32.Ident4())
-	game.shader.UniformMat4("translate", mgl32.Ident4())
-	game.shader.UniformMat4("rotate", mgl32.Ident4())
-	game.shader.UniformMat4("texTranslate", mgl32.Ident4())
-	game.shader.Bind()
 
 	RegisterEventListener(game)
 
-	game.entity = NewBlockEntity(game.world)
+	world := sim.World()
+	game.entity = NewBlockEntity(world)
 
 	game.entity.Set(NewBlock(BlockCoord{0, 0}, "stone", 0))
 	game.entity.Set(NewBlock(BlockCoord{0, 1}, "stone", 0))
@@ -181,31 +171,30 @@ func NewGame(window *Window, dic *BlockTypeDictionary) *Game {
 	game.entity.Set(NewBlock(BlockCoord{2, 6}, "stone", 0))
 	game.entity.Set(NewBlock(BlockCoord{3, 6}, "stone", 0))
 
-	game.entity.Bake(game.world, dic)
 	game.entity.SetPosition(0, -20)
+	game.entity.Bake(world, dic, backend)
 
-	game.newEntity = NewBlockEntity(game.world)
+	game.newEntity = NewBlockEntity(world)
 	game.newEntity.Set(NewBlock(BlockCoord{0, 0}, "stone", 0))
 	game.newEntity.Set(NewBlock(BlockCoord{1, 1}, "stone", 0))
 	game.newEntity.Set(NewBlock(BlockCoord{2, 0}, "stone", 0))
-	game.newEntity.Bake(game.world, dic)
 	game.newEntity.SetPosition(0, -25)
+	game.newEntity.Bake(world, dic, backend)
 
-	game.world.CreatePrismaticJoint( // TODO It seems about to work but...
-		game.entity.Body, game.newEntity.Body,
-		Vec2{0, -7}, Vec2{0, 0}, Vec2{0, 1},
-		0,
-		false, -20, 20,
-		false, 0, 1,
-		false,
-	)
+	world.CreatePrismaticJoint(PrismaticJointDef{
+		JointDef:      JointDef{BodyA: game.entity.Body, BodyB: game.newEntity.Body, AnchorA: Vec2{0, -7}},
+		Axis:          Vec2{0, 1},
+		Lower:         -20,
+		Upper:         20,
+		MaxMotorForce: 1,
+	})
 
 	return game
 }
 
 func (game *Game) bakeBackgroundQuad() {
 	imageW, imageH := game.bgTex.GetSize()
-	frameW, frameH := glfw.GetCurrentContext().GetSize()
+	frameW, frameH := game.width, game.height
 	hu, hv := (float32(frameW)/float32(imageW))/2.0, (float32(frameH)/float32(imageH))/2.0
 	game.bgHu = hu
 	game.bgHv = hv
@@ -223,7 +212,7 @@ func (game *Game) bakeBackgroundQuad() {
 		hu, hv, 0,
 		hu, -hv, 0,
 	}
-	indices := []uint16{
+	indices := []uint32{
 		0, 1, 2,
 		0, 2, 3,
 	}
@@ -236,183 +225,252 @@ func (game *Game) bakeBackgroundQuad() {
 		game.quad.TexCoords = coords
 		game.quad.Indices = indices
 	}
-	game.quad.Bake()
-}
-
-// Generate chunk list for rendering.
-func chunkListGenerator(game *Game, radius int) {
-	for {
-		select {
-		case <-game.quit:
-			return
-		default:
-			posX, posY := game.camera.target.GetPosition()
-
-			chunks := make(map[WorldChunkCoord]*Chunk)
-			for y := 0; y < radius*2; y++ {
-				for x := 0; x < radius*2; x++ {
-					worldChunkCoord := WorldChunkCoord{
-						int64(posX)/CHUNK_WIDTH + int64(x-radius),
-						int64(posY)/CHUNK_HEIGHT + int64(y-radius),
-					}
-					chunk := game.terrain.GetChunk(worldChunkCoord)
-					if chunk == nil {
-						continue
-					}
-					chunks[worldChunkCoord] = chunk
-				}
-			}
-			game.chunksToDraw = chunks
-
-			time.Sleep(time.Second * 2)
-		}
-	}
+	game.quad.Bake(game.backend)
 }
 
 func (game *Game) Update(dt time.Duration) {
-	PollEvents()
-
-	keyA := GetKeyActionState(KEY_A)
-	keyD := GetKeyActionState(KEY_D)
-	keyW := GetKeyActionState(KEY_W)
-	keyS := GetKeyActionState(KEY_S)
-	if keyA == ACTION_PRESS || keyA == ACTION_REPEAT {
-		game.player.ApplyForceToCenter(Vec2{-40, 0})
-	}
-	if keyD == ACTION_PRESS || keyD == ACTION_REPEAT {
-		game.player.ApplyForceToCenter(Vec2{40, 0})
-	}
-	if keyW == ACTION_PRESS || keyW == ACTION_REPEAT {
-		game.player.ApplyForceToCenter(Vec2{0, 40})
-	}
-	if keyS == ACTION_PRESS || keyS == ACTION_REPEAT {
-		game.player.ApplyForceToCenter(Vec2{0, -40})
-	}
-
-	// 한 프레임 마다 처리할 청크의 수를 잘 조절하면
-	// 끊김없이 게임을 진행할 수 있음.
-	for i := 0; i < 5; i++ {
-		select {
-		case chunk := <-game.bakeChunkQueue:
-			chunk.mesh.Bake()
-			chunk.body.Bake()
-		case chunk := <-game.destroyChunkQueue:
-			chunk.Destroy()
-		default:
-		}
-	}
-
-	game.world.Update(dt)
+	game.sim.Step(dt)
 }
 
+// Release chunk groups and close the simulation.
+// Return after all sectors are written to the disk.
 func (game *Game) Destroy() {
-	close(game.quit)
+	UnregisterEventListener(game)
 
-	for _, sector := range game.terrain.Sectors {
-		SaveSector(sector)
+	for coord, group := range game.chunkGroups {
+		group.destroy()
+		delete(game.chunkGroups, coord)
+	}
+	for mover, made := range game.moverMeshes {
+		made.mesh.Destroy()
+		delete(game.moverMeshes, mover)
 	}
-}
-
-func (game *Game) Render() {
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
-	// render to the fbo for multisampling
-	gl.BindFramebuffer(gl.FRAMEBUFFER, game.msaaFbo)
+	game.sim.Close()
+}
 
-	game.shader.UniformMat4("rotate", mgl32.Ident4())
+// Draw the simulation, with bodies interpolated by alpha between the last two steps.
+func (game *Game) Render(alpha float64) {
+	game.camera.SetAlpha(alpha)
 
 	// render background
 	game.renderBackground()
 
-	// init uniforms
-	game.shader.UniformInt("texMode", 0)
-	game.shader.UniformInt("translateMode", 0)
-	game.shader.UniformMat4("projection", game.camera.GetProjectionMat())
-	game.shader.UniformMat4("camera", game.camera.GetCameraMat())
+	// uniforms of the world
+	projection := game.camera.GetProjectionMat()
+	camera := game.camera.GetCameraMat()
+	world := func(texMode int32, translate, rotate mgl32.Mat4) (map[string]int32, map[string]mgl32.Mat4) {
+		ints := map[string]int32{"texMode": texMode, "translateMode": 0}
+		mats := map[string]mgl32.Mat4{"projection": projection, "camera": camera, "translate": translate, "rotate": rotate}
+		return ints, mats
+	}
 
 	// render player
-	game.renderPlayer()
-
-	// render chunks
-	game.renderChunks()
+	x, y := game.player.GetInterpolatedPosition(alpha)
+	ints, mats := world(0, mgl32.Translate3D(float32(x), float32(y), 0), mgl32.Ident4())
+	game.renderer.Add(DrawCommand{
+		Layer:   1,
+		Shader:  game.shader,
+		Texture: game.player.Texture,
+		Mesh:    game.player.Mesh,
+		Ints:    ints,
+		Mats:    mats,
+	})
+
+	// render chunks, only which are on the screen
+	view := game.camera.GetAABB()
+	chunks := game.sim.Streamer().ChunksToDraw()
+	game.updateChunkGroups(chunks, view)
+	ints, _ = world(1, mgl32.Ident4(), mgl32.Ident4())
+	for coord, group := range game.chunkGroups {
+		if !visible(coord.aabb(), view) {
+			continue
+		}
+		_, mats := world(1, group.translate, mgl32.Ident4())
+		game.renderer.Add(DrawCommand{
+			Layer:       1,
+			Shader:      game.shader,
+			Texture:     game.dic.ArrayTexture(game.backend),
+			TextureUnit: 1,
+			Mesh:        group.mesh,
+			Ints:        ints,
+			Mats:        mats,
+		})
+	}
 
 	// render entities TODO
 	entities := []*BlockEntity{game.entity, game.newEntity}
 	for _, entity := range entities {
-		x, y := entity.Body.GetPosition()
-		angle := entity.Body.GetAngle()
-		game.shader.UniformMat4("translate", mgl32.Translate3D(
-			float32(x),
-			float32(y),
-			0,
-		))
-		game.shader.UniformMat4("rotate", mgl32.HomogRotate3DZ(float32(angle)))
-		entity.Mesh.Draw()
+		if !visible(entity.GetInterpolatedAABB(alpha), view) {
+			continue
+		}
+		x, y := entity.Body.GetInterpolatedPosition(alpha)
+		angle := entity.Body.GetInterpolatedAngle(alpha)
+		ints, mats := world(1, mgl32.Translate3D(float32(x), float32(y), 0), mgl32.HomogRotate3DZ(float32(angle)))
+		game.renderer.Add(DrawCommand{
+			Layer:       1,
+			Shader:      game.shader,
+			Texture:     game.dic.ArrayTexture(game.backend),
+			TextureUnit: 1,
+			Mesh:        entity.Mesh,
+			Ints:        ints,
+			Mats:        mats,
+		})
 	}
 
-	// completed rendering
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-
-	// resolve result to screen
-	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, game.msaaFbo)
-	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
-	width, height := glfw.GetCurrentContext().GetSize()
-	gl.BlitFramebuffer(
-		0, 0, int32(width), int32(height),
-		0, 0, int32(width), int32(height), gl.COLOR_BUFFER_BIT, gl.NEAREST)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-}
+	// render non-fixed blocks of chunks and entities, each at its own body
+	collisions := make([]*blockCollision, 0, len(chunks)+len(entities))
+	for _, chunk := range chunks {
+		if chunk.collision != nil {
+			collisions = append(collisions, chunk.collision)
+		}
+	}
+	for _, entity := range entities {
+		collisions = append(collisions, entity.collision)
+	}
+	drawn := make(map[*blockMover]bool)
+	for _, collision := range collisions {
+		for _, mover := range collision.movers {
+			// Not in the world yet.
+			if mover.joint == nil {
+				continue
+			}
+			x, y := mover.body.GetInterpolatedPosition(alpha)
+			if !visible(&AABB{Center: Vec2{x, y}, HWidth: 1, HHeight: 1}, view) {
+				continue
+			}
+			drawn[mover] = true
+
+			angle := mover.body.GetInterpolatedAngle(alpha)
+			ints, mats := world(1, mgl32.Translate3D(float32(x), float32(y), 0), mgl32.HomogRotate3DZ(float32(angle)))
+			game.renderer.Add(DrawCommand{
+				Layer:       1,
+				Shader:      game.shader,
+				Texture:     game.dic.ArrayTexture(game.backend),
+				TextureUnit: 1,
+				Mesh:        game.moverMesh(mover),
+				Ints:        ints,
+				Mats:        mats,
+			})
+		}
+	}
+	for mover, made := range game.moverMeshes {
+		if !drawn[mover] {
+			made.mesh.Destroy()
+			delete(game.moverMeshes, mover)
+		}
+	}
 
-func (game *Game) renderPlayer() {
-	game.player.Texture.Bind(0)
-	x, y := game.player.GetPosition()
-	game.shader.UniformMat4("translate", mgl32.Translate3D(
-		float32(x),
-		float32(y),
-		0,
-	))
-	game.player.Mesh.Draw()
+	game.renderer.Render()
 }
 
-func (game *Game) renderChunks() {
-	game.shader.UniformInt("texMode", 1)
-	for worldChunkCoord, chunk := range game.chunksToDraw {
-		game.shader.UniformMat4("translate", mgl32.Translate3D( // TODO 이 행렬을 캐쉬해두면 속도가 빨라질듯
-			float32(worldChunkCoord.X*CHUNK_WIDTH),
-			float32(worldChunkCoord.Y*CHUNK_HEIGHT),
-			0,
-		))
-		chunk.mesh.Draw()
+// Quad of the non-fixed block around its body, made again when the block looks different.
+func (game *Game) moverMesh(mover *blockMover) *Mesh {
+	look := blockLookOf(&mover.block, game.dic)
+	made, exist := game.moverMeshes[mover]
+	if exist && made.look == look {
+		return made.mesh
 	}
+	if !exist {
+		made = &moverMesh{mesh: NewMesh(nil, nil, nil, nil)}
+		game.moverMeshes[mover] = made
+	}
+	made.look = look
+
+	positions := make([]float32, 4*3)
+	texCoords := make([]float32, 4*3)
+	writeBlockQuad(positions, texCoords, blockRect{BlockCoord{}, 1, 1, look})
+	made.mesh.Positions = positions
+	made.mesh.TexCoords = texCoords
+	made.mesh.Indices = appendQuadIndices(nil, 0)
+	made.mesh.Bake(game.backend)
+
+	return made.mesh
 }
 
-func (game *Game) renderBackground() {
-	zoom := float32(game.camera.GetZoom())
-	game.shader.UniformMat4("projection", mgl32.Scale3D(1.0/zoom, 1.0/zoom, 1))
-	a, b := game.camera.target.GetPosition()
-	game.shader.UniformMat4("camera", mgl32.Translate3D(
-		float32(a)/1500.0/game.bgHu*zoom,
-		float32(-b)/1500.0/game.bgHu*zoom/game.bgRatio,
-		0,
-	))
-	game.shader.UniformMat4("translate", mgl32.Ident4())
-	game.shader.UniformInt("texMode", 0)
-	game.shader.UniformInt("translateMode", 1)
-	game.bgTex.Bind(0)
-	game.quad.Draw()
+// Draw calls, vertices and so on of the last frame.
+func (game *Game) FrameStats() FrameStats {
+	return game.renderer.Stats()
+}
+
+// Whether bounds are in view. Everything is visible without view or bounds.
+func visible(aabb, view *AABB) bool {
+	return aabb == nil || view == nil || aabb.Collide(view)
 }
 
-func (game *Game) unloadSector(worldSectorCoord WorldSectorCoord) {
-	log.Printf("unload sector: %s\n", worldSectorCoord)
+// Merge meshes of chunk groups in view which are new or changed,
+// and destroy groups which are not drawn anymore.
+// Groups out of view are left until they come into view.
+func (game *Game) updateChunkGroups(chunks map[WorldChunkCoord]*Chunk, view *AABB) {
+	grouped := make(map[chunkGroupCoord]map[WorldChunkCoord]*Chunk)
+	for coord, chunk := range chunks {
+		groupCoord := chunkGroupCoordOf(coord)
+		if grouped[groupCoord] == nil {
+			grouped[groupCoord] = make(map[WorldChunkCoord]*Chunk)
+		}
+		grouped[groupCoord][coord] = chunk
+	}
 
-	sector := game.terrain.GetSector(worldSectorCoord)
-	if sector != nil {
-		sector.Destroy()
-		game.terrain.DeleteSector(worldSectorCoord)
+	for coord, group := range game.chunkGroups {
+		if _, exist := grouped[coord]; !exist {
+			group.destroy()
+			delete(game.chunkGroups, coord)
+		}
 	}
+
+	made := 0
+	for coord, groupChunks := range grouped {
+		if !visible(coord.aabb(), view) {
+			continue
+		}
+		group, exist := game.chunkGroups[coord]
+		if exist && group.upToDate(groupChunks) {
+			continue
+		}
+		// Edits are shown right away, in place if possible.
+		if exist && group.sameChunks(groupChunks) {
+			if !group.update(groupChunks, game.dic) {
+				group.bake(groupChunks, game.dic, game.backend)
+			}
+			continue
+		}
+		// Loaded chunks can wait a few frames.
+		if made == chunkGroupsPerFrame {
+			continue
+		}
+		made++
+
+		if !exist {
+			group = newChunkGroup(coord)
+			game.chunkGroups[coord] = group
+		}
+		group.bake(groupChunks, game.dic, game.backend)
+	}
+}
+
+func (game *Game) renderBackground() {
+	zoom := float32(game.camera.GetZoom())
+	a, b := game.camera.target.GetInterpolatedPosition(game.camera.alpha)
+	game.renderer.Add(DrawCommand{
+		Layer:   0,
+		Shader:  game.shader,
+		Texture: game.bgTex,
+		Mesh:    game.quad,
+		Ints:    map[string]int32{"texMode": 0, "translateMode": 1},
+		Mats: map[string]mgl32.Mat4{
+			"projection": mgl32.Scale3D(1.0/zoom, 1.0/zoom, 1),
+			"camera": mgl32.Translate3D(
+				float32(a)/1500.0/game.bgHu*zoom,
+				float32(-b)/1500.0/game.bgHu*zoom/game.bgRatio,
+				0,
+			),
+			"translate": mgl32.Ident4(),
+			"rotate":    mgl32.Ident4(),
+		},
+	})
 }
 
-func (game Game) OnEvent(event Event) {
+func (game *Game) OnEvent(event Event) {
 	switch event.(type) {
 	case MouseEvent:
 		mouseEvent := event.(MouseEvent)
@@ -424,7 +482,7 @@ func (game Game) OnEvent(event Event) {
 		xpos := float32(mouseEvent.XPos)
 		ypos := float32(mouseEvent.YPos)
 
-		width, height := game.window.GetSize()
+		width, height := game.width, game.height
 		worldPos, err := mgl32.UnProject(
 			mgl32.Vec3{xpos, float32(height) - ypos, 0},
 			game.camera.GetCameraMat(),
@@ -438,26 +496,11 @@ func (game Game) OnEvent(event Event) {
 				int64(math.Floor(float64(worldPos.X()) + 0.5)),
 				int64(math.Floor(float64(worldPos.Y()) + 0.5)),
 			}
-			sectorCoord, chunkCoord, blockCoord := worldCoord.Parse()
-
-			worldChunkCoord := CombineWorldChunkCoord(sectorCoord, chunkCoord)
-			chunk := game.terrain.GetChunk(worldChunkCoord)
-			if chunk == nil {
-				break
-			}
 
 			if button == MOUSE_BUTTON_LEFT {
-				chunk.Set(NewBlock(blockCoord, "", 0))
-				chunk.body.Clear()
-				chunk.Bake(game.world, game.dic, worldChunkCoord)
-				chunk.mesh.Bake()
-				chunk.body.Bake()
+				PushEvent(EditBlockEvent{Coord: worldCoord, BlockType: BLOCK_TYPE_VOID})
 			} else if button == MOUSE_BUTTON_RIGHT {
-				chunk.Set(NewBlock(blockCoord, "stone", 0))
-				chunk.body.Clear()
-				chunk.Bake(game.world, game.dic, worldChunkCoord)
-				chunk.mesh.Bake()
-				chunk.body.Bake()
+				PushEvent(EditBlockEvent{Coord: worldCoord, BlockType: "stone"})
 			}
 		}
 	case ScrollEvent:
@@ -471,12 +514,10 @@ func (game Game) OnEvent(event Event) {
 		width, height := windowSizeEvent.Width, windowSizeEvent.Height
 		ratio := float64(height) / float64(width)
 
+		game.width, game.height = width, height
 		game.camera.SetSize(20, 20*ratio)
 		game.bakeBackgroundQuad()
 
-		gl.BindTexture(gl.TEXTURE_2D_MULTISAMPLE, game.msaaTex)
-		gl.TexImage2DMultisample(gl.TEXTURE_2D_MULTISAMPLE, 4, gl.RGBA8, int32(width), int32(height), false)
-
-		gl.Viewport(0, 0, int32(width), int32(height))
+		game.backend.Resize(width, height)
 	}
 }
@@ -0,0 +1,119 @@
+package lostinspace_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+// Game loads its images from the working directory.
+// Return the directory the test was in.
+func chdirAssets(t *testing.T) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("cmd/game"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return wd
+}
+
+func TestGameRender(t *testing.T) {
+	chdirAssets(t)
+
+	sim := newTestSimulation(t, t.TempDir())
+	backend := lostinspace.NewRecordingBackend()
+	game := lostinspace.NewGame(sim, backend, 800, 600)
+	defer game.Destroy()
+
+	game.Update(testStep)
+	backend.Reset()
+	game.Render(0)
+
+	// Meshes are made before the frame begins.
+	calls := backend.Filter("BeginFrame", "DrawElements", "EndFrame")
+	if calls[0].Name != "BeginFrame" || calls[len(calls)-1].Name != "EndFrame" {
+		t.Errorf("Frame is not begun and ended: %v ... %v\n", calls[0], calls[len(calls)-1])
+	}
+
+	// Background, player and a chunk group made for the first frame.
+	// Entities are out of the screen.
+	draws := backend.Filter("DrawElements")
+	if len(draws) != 1+1+1 || game.FrameStats().DrawCalls != len(draws) {
+		t.Errorf("%d draws, %d counted\n", len(draws), game.FrameStats().DrawCalls)
+	}
+	if created := backend.Filter("CreateVertexArray"); len(created) != 1 {
+		t.Errorf("%d chunk groups are made\n", len(created))
+	}
+	// Each texture is bound once, the background and the player at 0 and the texture array at 1.
+	if stats := game.FrameStats(); stats.TextureBinds != 3 || stats.ShaderBinds != 1 {
+		t.Errorf("Stats: %+v\n", stats)
+	}
+
+	// Chunks are drawn with the texture array, right after texMode is set.
+	var texMode int32 = -1
+	chunkDraws := 0
+	for _, call := range backend.Filter("UniformInt", "DrawElements") {
+		switch call.Name {
+		case "UniformInt":
+			if call.Args[1] == "texMode" {
+				texMode = call.Args[2].(int32)
+			}
+		case "DrawElements":
+			if texMode == 1 {
+				chunkDraws++
+			}
+		}
+	}
+	if chunkDraws != 1 {
+		t.Errorf("%d draws with the texture array\n", chunkDraws)
+	}
+
+	// Next frame makes only the next chunk group.
+	backend.Reset()
+	game.Render(0.5)
+	if created := backend.Filter("CreateBuffer", "CreateVertexArray", "CreateTexture2D"); len(created) != 5 {
+		t.Errorf("%d objects are made for the second frame\n", len(created))
+	}
+
+	// Screen of 20x15 blocks at the origin shows 2x2 groups of chunks, each of them takes one draw call.
+	for i := 0; i < 8; i++ {
+		game.Render(0)
+	}
+	if drawCalls := game.FrameStats().DrawCalls; drawCalls != 2+4 {
+		t.Errorf("%d draw calls\n", drawCalls)
+	}
+
+	// Zoomed out to 200x150 blocks.
+	lostinspace.PushEvent(lostinspace.ScrollEvent{YOff: -18})
+	game.Update(testStep)
+	for i := 0; i < 16; i++ {
+		game.Render(0)
+	}
+	// Entities and the door of one of them.
+	if drawCalls := game.FrameStats().DrawCalls; drawCalls != 2+16+2+1 {
+		t.Errorf("%d draw calls after zoomed out\n", drawCalls)
+	}
+}
+
+func TestGameResize(t *testing.T) {
+	chdirAssets(t)
+
+	sim := newTestSimulation(t, t.TempDir())
+	backend := lostinspace.NewRecordingBackend()
+	game := lostinspace.NewGame(sim, backend, 800, 600)
+	defer game.Destroy()
+
+	backend.Reset()
+	lostinspace.PushEvent(lostinspace.WindowSizeEvent{Width: 400, Height: 300})
+	game.Update(testStep)
+
+	resizes := backend.Filter("Resize")
+	if len(resizes) != 1 || resizes[0].Args[0] != 400 || resizes[0].Args[1] != 300 {
+		t.Errorf("Resized: %v\n", resizes)
+	}
+}
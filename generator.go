@@ -17,8 +17,6 @@ func GenerateSector(seed *Seed, sectorCoord WorldSectorCoord) *Sector {
 }
 
 func GenerateChunk(seed *Seed, worldChunkCoord WorldChunkCoord) *Chunk {
-	perm := seed.perm
-
 	sectorCoord, chunkCoord := worldChunkCoord.Parse()
 
 	chunk := NewChunk(chunkCoord)
@@ -26,35 +24,44 @@ func GenerateChunk(seed *Seed, worldChunkCoord WorldChunkCoord) *Chunk {
 	for y := uint8(0); y < CHUNK_HEIGHT; y++ {
 		for x := uint8(0); x < CHUNK_WIDTH; x++ {
 			blockCoord := BlockCoord{x, y}
-			block := NewBlock(blockCoord, BLOCK_TYPE_VOID, 0)
-
 			worldCoord := CombineWorldBlockCoord(sectorCoord, chunkCoord, blockCoord)
 
-			noise := PerlinNoiseImproved(perm,
-				float64(worldCoord.X)/32.0,
-				float64(worldCoord.Y)/32.0,
-				0)
-
-			if noise > 0.67 {
-				noise *= PerlinNoiseImproved(
-					perm,
-					float64(worldCoord.X)/8.0,
-					float64(worldCoord.Y)/8.0,
-					0,
-				)
-
-				if noise > 0.5 {
-					block.BlockType = "test1"
-				} else if noise > 0.45 {
-					block.BlockType = "test2"
-				} else {
-					block.BlockType = "stone"
-				}
+			blockType := generateBlockType(seed, worldCoord)
+			if blockType == BLOCK_TYPE_VOID {
+				continue
 			}
 
-			chunk.Set(block)
+			chunk.set(NewBlock(blockCoord, blockType, 0))
 		}
 	}
 
 	return chunk
 }
+
+func generateBlockType(seed *Seed, worldCoord WorldBlockCoord) BlockType {
+	perm := seed.perm
+
+	noise := PerlinNoiseImproved(perm,
+		float64(worldCoord.X)/32.0,
+		float64(worldCoord.Y)/32.0,
+		0)
+
+	if noise <= 0.67 {
+		return BLOCK_TYPE_VOID
+	}
+
+	noise *= PerlinNoiseImproved(
+		perm,
+		float64(worldCoord.X)/8.0,
+		float64(worldCoord.Y)/8.0,
+		0,
+	)
+
+	if noise > 0.5 {
+		return "test1"
+	} else if noise > 0.45 {
+		return "test2"
+	} else {
+		return "stone"
+	}
+}
@@ -1,171 +1,173 @@
 package lostinspace
 
-import "github.com/go-gl/glfw/v3.2/glfw"
+import "sort"
 
+// Keys, actions, mouse buttons and modifier keys have the values glfw gives them,
+// so that the window passes them through as they are.
 const (
-	KEY_UNKNOWN    Key = Key(glfw.KeyUnknown)
-	KEY_SPACE          = Key(glfw.KeySpace)
-	KEY_APOSTROPHE     = Key(glfw.KeyApostrophe)
-	KEY_COMMA          = Key(glfw.KeyComma)
-	KEY_MINUS          = Key(glfw.KeyMinus)
-	KEY_PERIOD         = Key(glfw.KeyPeriod)
-	KEY_SLASH          = Key(glfw.KeySlash)
-
-	KEY_0 = Key(glfw.Key0)
-	KEY_1 = Key(glfw.Key1)
-	KEY_2 = Key(glfw.Key2)
-	KEY_3 = Key(glfw.Key3)
-	KEY_4 = Key(glfw.Key4)
-	KEY_5 = Key(glfw.Key5)
-	KEY_6 = Key(glfw.Key6)
-	KEY_7 = Key(glfw.Key7)
-	KEY_8 = Key(glfw.Key8)
-	KEY_9 = Key(glfw.Key9)
-
-	KEY_SEMICOLON = Key(glfw.KeySemicolon)
-	KEY_EQUAL     = Key(glfw.KeyEqual)
-
-	KEY_A = Key(glfw.KeyA)
-	KEY_B = Key(glfw.KeyB)
-	KEY_C = Key(glfw.KeyC)
-	KEY_D = Key(glfw.KeyD)
-	KEY_E = Key(glfw.KeyE)
-	KEY_F = Key(glfw.KeyF)
-	KEY_G = Key(glfw.KeyG)
-	KEY_H = Key(glfw.KeyH)
-	KEY_I = Key(glfw.KeyI)
-	KEY_J = Key(glfw.KeyJ)
-	KEY_K = Key(glfw.KeyK)
-	KEY_L = Key(glfw.KeyL)
-	KEY_M = Key(glfw.KeyM)
-	KEY_N = Key(glfw.KeyN)
-	KEY_O = Key(glfw.KeyO)
-	KEY_P = Key(glfw.KeyP)
-	KEY_Q = Key(glfw.KeyQ)
-	KEY_R = Key(glfw.KeyR)
-	KEY_S = Key(glfw.KeyS)
-	KEY_T = Key(glfw.KeyT)
-	KEY_U = Key(glfw.KeyU)
-	KEY_V = Key(glfw.KeyV)
-	KEY_W = Key(glfw.KeyW)
-	KEY_X = Key(glfw.KeyX)
-	KEY_Y = Key(glfw.KeyY)
-	KEY_Z = Key(glfw.KeyZ)
-
-	KEY_BRACKET_LEFT  = Key(glfw.KeyLeftBracket)
-	KEY_BRACKET_RIGHT = Key(glfw.KeyRightBracket)
-	KEY_BACK_SLASH    = Key(glfw.KeyBackslash)
-	KEY_GRAVE_ACCENT  = Key(glfw.KeyGraveAccent)
-	KEY_WORLD_1       = Key(glfw.KeyWorld1)
-	KEY_WORLD_2       = Key(glfw.KeyWorld2)
-	KEY_ESCAPE        = Key(glfw.KeyEscape)
-	KEY_ENTER         = Key(glfw.KeyEnter)
-	KEY_TAB           = Key(glfw.KeyTab)
-	KEY_BACKSPACE     = Key(glfw.KeyBackspace)
-	KEY_INSERT        = Key(glfw.KeyInsert)
-	KEY_DELETE        = Key(glfw.KeyDelete)
-	KEY_RIGHT         = Key(glfw.KeyRight)
-	KEY_LEFT          = Key(glfw.KeyLeft)
-	KEY_DOWN          = Key(glfw.KeyDown)
-	KEY_UP            = Key(glfw.KeyUp)
-	KEY_PAGE_UP       = Key(glfw.KeyPageUp)
-	KEY_PAGE_DOWN     = Key(glfw.KeyPageDown)
-	KEY_HOME          = Key(glfw.KeyHome)
-	KEY_END           = Key(glfw.KeyEnd)
-	KEY_CAPS_LOCK     = Key(glfw.KeyCapsLock)
-	KEY_SCROLL_LOCK   = Key(glfw.KeyScrollLock)
-	KEY_NUM_LOCK      = Key(glfw.KeyNumLock)
-	KEY_PRINT_SCREEN  = Key(glfw.KeyPrintScreen)
-	KEY_PAUSE         = Key(glfw.KeyPause)
-
-	KEY_F1  = Key(glfw.KeyF1)
-	KEY_F2  = Key(glfw.KeyF2)
-	KEY_F3  = Key(glfw.KeyF3)
-	KEY_F4  = Key(glfw.KeyF4)
-	KEY_F5  = Key(glfw.KeyF5)
-	KEY_F6  = Key(glfw.KeyF6)
-	KEY_F7  = Key(glfw.KeyF7)
-	KEY_F8  = Key(glfw.KeyF8)
-	KEY_F9  = Key(glfw.KeyF9)
-	KEY_F10 = Key(glfw.KeyF10)
-	KEY_F11 = Key(glfw.KeyF11)
-	KEY_F12 = Key(glfw.KeyF12)
-	KEY_F13 = Key(glfw.KeyF13)
-	KEY_F14 = Key(glfw.KeyF14)
-	KEY_F15 = Key(glfw.KeyF15)
-	KEY_F16 = Key(glfw.KeyF16)
-	KEY_F17 = Key(glfw.KeyF17)
-	KEY_F18 = Key(glfw.KeyF18)
-	KEY_F19 = Key(glfw.KeyF19)
-	KEY_F20 = Key(glfw.KeyF20)
-	KEY_F21 = Key(glfw.KeyF21)
-	KEY_F22 = Key(glfw.KeyF22)
-	KEY_F23 = Key(glfw.KeyF23)
-	KEY_F24 = Key(glfw.KeyF24)
-	KEY_F25 = Key(glfw.KeyF25)
-
-	KEY_KP_0     = Key(glfw.KeyKP0)
-	KEY_KP_1     = Key(glfw.KeyKP1)
-	KEY_KP_2     = Key(glfw.KeyKP2)
-	KEY_KP_3     = Key(glfw.KeyKP3)
-	KEY_KP_4     = Key(glfw.KeyKP4)
-	KEY_KP_5     = Key(glfw.KeyKP5)
-	KEY_KP_6     = Key(glfw.KeyKP6)
-	KEY_KP_7     = Key(glfw.KeyKP7)
-	KEY_KP_8     = Key(glfw.KeyKP8)
-	KEY_KP_9     = Key(glfw.KeyKP9)
-	KEY_KP_DEC   = Key(glfw.KeyKPDecimal)
-	KEY_KP_DIV   = Key(glfw.KeyKPDivide)
-	KEY_KP_MUL   = Key(glfw.KeyKPMultiply)
-	KEY_KP_SUB   = Key(glfw.KeyKPSubtract)
-	KEY_KP_ADD   = Key(glfw.KeyKPAdd)
-	KEY_KP_ENTER = Key(glfw.KeyKPEnter)
-	KEY_KP_EQUAL = Key(glfw.KeyKPEqual)
-
-	KEY_SHIFT_LEFT    = Key(glfw.KeyLeftShift)
-	KEY_SHIFT_RIGHT   = Key(glfw.KeyRightShift)
-	KEY_CONTROL_LEFT  = Key(glfw.KeyLeftControl)
-	KEY_CONTROL_RIGHT = Key(glfw.KeyRightControl)
-	KEY_ALT_LEFT      = Key(glfw.KeyLeftAlt)
-	KEY_ALT_RIGHT     = Key(glfw.KeyRightAlt)
-	KEY_SUPER_LEFT    = Key(glfw.KeyLeftSuper)
-	KEY_SUPER_RIGHT   = Key(glfw.KeyRightSuper)
-	KEY_MENU          = Key(glfw.KeyMenu)
-	KEY_LAST          = Key(glfw.KeyLast)
+	KEY_UNKNOWN    Key = Key(-1)
+	KEY_SPACE          = Key(32)
+	KEY_APOSTROPHE     = Key(39)
+	KEY_COMMA          = Key(44)
+	KEY_MINUS          = Key(45)
+	KEY_PERIOD         = Key(46)
+	KEY_SLASH          = Key(47)
+
+	KEY_0 = Key(48)
+	KEY_1 = Key(49)
+	KEY_2 = Key(50)
+	KEY_3 = Key(51)
+	KEY_4 = Key(52)
+	KEY_5 = Key(53)
+	KEY_6 = Key(54)
+	KEY_7 = Key(55)
+	KEY_8 = Key(56)
+	KEY_9 = Key(57)
+
+	KEY_SEMICOLON = Key(59)
+	KEY_EQUAL     = Key(61)
+
+	KEY_A = Key(65)
+	KEY_B = Key(66)
+	KEY_C = Key(67)
+	KEY_D = Key(68)
+	KEY_E = Key(69)
+	KEY_F = Key(70)
+	KEY_G = Key(71)
+	KEY_H = Key(72)
+	KEY_I = Key(73)
+	KEY_J = Key(74)
+	KEY_K = Key(75)
+	KEY_L = Key(76)
+	KEY_M = Key(77)
+	KEY_N = Key(78)
+	KEY_O = Key(79)
+	KEY_P = Key(80)
+	KEY_Q = Key(81)
+	KEY_R = Key(82)
+	KEY_S = Key(83)
+	KEY_T = Key(84)
+	KEY_U = Key(85)
+	KEY_V = Key(86)
+	KEY_W = Key(87)
+	KEY_X = Key(88)
+	KEY_Y = Key(89)
+	KEY_Z = Key(90)
+
+	KEY_BRACKET_LEFT  = Key(91)
+	KEY_BRACKET_RIGHT = Key(93)
+	KEY_BACK_SLASH    = Key(92)
+	KEY_GRAVE_ACCENT  = Key(96)
+	KEY_WORLD_1       = Key(161)
+	KEY_WORLD_2       = Key(162)
+	KEY_ESCAPE        = Key(256)
+	KEY_ENTER         = Key(257)
+	KEY_TAB           = Key(258)
+	KEY_BACKSPACE     = Key(259)
+	KEY_INSERT        = Key(260)
+	KEY_DELETE        = Key(261)
+	KEY_RIGHT         = Key(262)
+	KEY_LEFT          = Key(263)
+	KEY_DOWN          = Key(264)
+	KEY_UP            = Key(265)
+	KEY_PAGE_UP       = Key(266)
+	KEY_PAGE_DOWN     = Key(267)
+	KEY_HOME          = Key(268)
+	KEY_END           = Key(269)
+	KEY_CAPS_LOCK     = Key(280)
+	KEY_SCROLL_LOCK   = Key(281)
+	KEY_NUM_LOCK      = Key(282)
+	KEY_PRINT_SCREEN  = Key(283)
+	KEY_PAUSE         = Key(284)
+
+	KEY_F1  = Key(290)
+	KEY_F2  = Key(291)
+	KEY_F3  = Key(292)
+	KEY_F4  = Key(293)
+	KEY_F5  = Key(294)
+	KEY_F6  = Key(295)
+	KEY_F7  = Key(296)
+	KEY_F8  = Key(297)
+	KEY_F9  = Key(298)
+	KEY_F10 = Key(299)
+	KEY_F11 = Key(300)
+	KEY_F12 = Key(301)
+	KEY_F13 = Key(302)
+	KEY_F14 = Key(303)
+	KEY_F15 = Key(304)
+	KEY_F16 = Key(305)
+	KEY_F17 = Key(306)
+	KEY_F18 = Key(307)
+	KEY_F19 = Key(308)
+	KEY_F20 = Key(309)
+	KEY_F21 = Key(310)
+	KEY_F22 = Key(311)
+	KEY_F23 = Key(312)
+	KEY_F24 = Key(313)
+	KEY_F25 = Key(314)
+
+	KEY_KP_0     = Key(320)
+	KEY_KP_1     = Key(321)
+	KEY_KP_2     = Key(322)
+	KEY_KP_3     = Key(323)
+	KEY_KP_4     = Key(324)
+	KEY_KP_5     = Key(325)
+	KEY_KP_6     = Key(326)
+	KEY_KP_7     = Key(327)
+	KEY_KP_8     = Key(328)
+	KEY_KP_9     = Key(329)
+	KEY_KP_DEC   = Key(330)
+	KEY_KP_DIV   = Key(331)
+	KEY_KP_MUL   = Key(332)
+	KEY_KP_SUB   = Key(333)
+	KEY_KP_ADD   = Key(334)
+	KEY_KP_ENTER = Key(335)
+	KEY_KP_EQUAL = Key(336)
+
+	KEY_SHIFT_LEFT    = Key(340)
+	KEY_SHIFT_RIGHT   = Key(344)
+	KEY_CONTROL_LEFT  = Key(341)
+	KEY_CONTROL_RIGHT = Key(345)
+	KEY_ALT_LEFT      = Key(342)
+	KEY_ALT_RIGHT     = Key(346)
+	KEY_SUPER_LEFT    = Key(343)
+	KEY_SUPER_RIGHT   = Key(347)
+	KEY_MENU          = Key(348)
+	KEY_LAST          = Key(348)
 )
 
 const (
-	ACTION_PRESS   Action = Action(glfw.Press)
-	ACTION_RELEASE        = Action(glfw.Release)
-	ACTION_REPEAT         = Action(glfw.Repeat)
+	ACTION_PRESS   Action = Action(1)
+	ACTION_RELEASE        = Action(0)
+	ACTION_REPEAT         = Action(2)
 )
 
 const (
-	MOUSE_BUTTON_1      MouseButton = MouseButton(glfw.MouseButton1)
-	MOUSE_BUTTON_2                  = MouseButton(glfw.MouseButton2)
-	MOUSE_BUTTON_3                  = MouseButton(glfw.MouseButton3)
-	MOUSE_BUTTON_4                  = MouseButton(glfw.MouseButton4)
-	MOUSE_BUTTON_5                  = MouseButton(glfw.MouseButton5)
-	MOUSE_BUTTON_6                  = MouseButton(glfw.MouseButton6)
-	MOUSE_BUTTON_7                  = MouseButton(glfw.MouseButton7)
-	MOUSE_BUTTON_8                  = MouseButton(glfw.MouseButton8)
-	MOUSE_BUTTON_LAST               = MouseButton(glfw.MouseButtonLast)
-	MOUSE_BUTTON_LEFT               = MouseButton(glfw.MouseButtonLeft)
-	MOUSE_BUTTON_RIGHT              = MouseButton(glfw.MouseButtonRight)
-	MOUSE_BUTTON_MIDDLE             = MouseButton(glfw.MouseButtonMiddle)
+	MOUSE_BUTTON_1      MouseButton = MouseButton(0)
+	MOUSE_BUTTON_2                  = MouseButton(1)
+	MOUSE_BUTTON_3                  = MouseButton(2)
+	MOUSE_BUTTON_4                  = MouseButton(3)
+	MOUSE_BUTTON_5                  = MouseButton(4)
+	MOUSE_BUTTON_6                  = MouseButton(5)
+	MOUSE_BUTTON_7                  = MouseButton(6)
+	MOUSE_BUTTON_8                  = MouseButton(7)
+	MOUSE_BUTTON_LAST               = MouseButton(7)
+	MOUSE_BUTTON_LEFT               = MouseButton(0)
+	MOUSE_BUTTON_RIGHT              = MouseButton(1)
+	MOUSE_BUTTON_MIDDLE             = MouseButton(2)
 )
 
 const (
-	MOD_ALT     ModifierKey = ModifierKey(glfw.ModAlt)
-	MOD_SHIFT               = ModifierKey(glfw.ModShift)
-	MOD_SUPER               = ModifierKey(glfw.ModSuper)
-	MOD_CONTROL             = ModifierKey(glfw.ModControl)
+	MOD_ALT     ModifierKey = ModifierKey(4)
+	MOD_SHIFT               = ModifierKey(1)
+	MOD_SUPER               = ModifierKey(8)
+	MOD_CONTROL             = ModifierKey(2)
 )
 
-type Key glfw.Key
-type Action glfw.Action
-type MouseButton glfw.MouseButton
-type ModifierKey glfw.ModifierKey
+type Key int
+type Action int
+type MouseButton int
+type ModifierKey int
 
 type inputListenerImpl struct{}
 
@@ -196,3 +198,21 @@ func GetKeyActionState(key Key) Action {
 
 	return action
 }
+
+// Keys which are not released, in order of key code.
+func pressedKeys() []Key {
+	keys := make([]Key, 0)
+	for key, action := range keyActionStates {
+		if action != ACTION_RELEASE {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	return keys
+}
+
+// Release every key.
+func resetKeyActionStates() {
+	keyActionStates = make(map[Key]Action)
+}
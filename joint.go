@@ -0,0 +1,373 @@
+package lostinspace
+
+import (
+	"math"
+
+	"github.com/rlj1202/box2d"
+)
+
+type JointType int
+
+const (
+	// Slides along an axis, see PrismaticJointDef.
+	JOINT_PRISMATIC JointType = iota
+	// Turns around an anchor, see RevoluteJointDef.
+	JOINT_REVOLUTE
+	// Glues bodies together, see WeldJointDef.
+	JOINT_WELD
+	// Keeps anchors at a distance, see DistanceJointDef.
+	JOINT_DISTANCE
+	// Keeps anchors within a distance, see RopeJointDef.
+	JOINT_ROPE
+)
+
+// What every joint definition has.
+// The joint is added to the world once both bodies are baked, see Body.Bake.
+type JointDef struct {
+	BodyA, BodyB *Body
+	// Where the joint is attached to each body, in the body's local coords.
+	AnchorA, AnchorB Vec2
+	// Whether the bodies collide with each other.
+	CollideConnected bool
+	// Joint breaks when its reaction force gets greater than this, never if it's zero.
+	// See Joint.Broken.
+	BreakForce float64
+}
+
+type PrismaticJointDef struct {
+	JointDef
+	// Direction BodyB slides in, in local coords of BodyA.
+	Axis Vec2
+	// Angle of BodyB minus angle of BodyA, which is kept.
+	ReferenceAngle float64
+
+	EnableLimit bool
+	// Translation along Axis from AnchorA.
+	Lower, Upper float64
+
+	EnableMotor   bool
+	MotorSpeed    float64
+	MaxMotorForce float64
+}
+
+type RevoluteJointDef struct {
+	JointDef
+	ReferenceAngle float64
+
+	EnableLimit bool
+	// Angle of BodyB relative to ReferenceAngle, in radians.
+	LowerAngle, UpperAngle float64
+
+	EnableMotor    bool
+	MotorSpeed     float64
+	MaxMotorTorque float64
+}
+
+type WeldJointDef struct {
+	JointDef
+	ReferenceAngle float64
+	// Softness of the weld, rigid if FrequencyHz is zero.
+	FrequencyHz  float64
+	DampingRatio float64
+}
+
+type DistanceJointDef struct {
+	JointDef
+	Length float64
+	// Softness like a spring, rigid if FrequencyHz is zero.
+	FrequencyHz  float64
+	DampingRatio float64
+}
+
+type RopeJointDef struct {
+	JointDef
+	MaxLength float64
+}
+
+// Joint between two bodies.
+// It's destroyed along with either of them, see Body.Destroy.
+type Joint struct {
+	world     *World
+	jointType JointType
+
+	bodyA, bodyB *Body
+	breakForce   float64
+	broken       bool
+	destroyed    bool
+
+	// Definition the joint is made from while a body isn't baked yet,
+	// common is part of it. Both are nil once the joint is made.
+	b2def  box2d.B2JointDefInterface
+	common *box2d.B2JointDef
+	// Nil until the joint is made and after it's destroyed.
+	b2joint box2d.B2JointInterface
+}
+
+// Joints which can tell how hard they pull their bodies.
+type b2ReactionJoint interface {
+	GetReactionForce(invDt float64) box2d.B2Vec2
+	GetReactionTorque(invDt float64) float64
+}
+
+func (world *World) CreatePrismaticJoint(def PrismaticJointDef) *Joint {
+	b2def := box2d.MakeB2PrismaticJointDef()
+	b2def.LocalAnchorA = toBox2dVec2(def.AnchorA)
+	b2def.LocalAnchorB = toBox2dVec2(def.AnchorB)
+	b2def.LocalAxisA = toBox2dVec2(def.Axis)
+	b2def.ReferenceAngle = def.ReferenceAngle
+	b2def.EnableLimit = def.EnableLimit
+	b2def.LowerTranslation = def.Lower
+	b2def.UpperTranslation = def.Upper
+	b2def.EnableMotor = def.EnableMotor
+	b2def.MotorSpeed = def.MotorSpeed
+	b2def.MaxMotorForce = def.MaxMotorForce
+
+	return world.createJoint(JOINT_PRISMATIC, def.JointDef, &b2def.B2JointDef, &b2def)
+}
+
+func (world *World) CreateRevoluteJoint(def RevoluteJointDef) *Joint {
+	b2def := box2d.MakeB2RevoluteJointDef()
+	b2def.LocalAnchorA = toBox2dVec2(def.AnchorA)
+	b2def.LocalAnchorB = toBox2dVec2(def.AnchorB)
+	b2def.ReferenceAngle = def.ReferenceAngle
+	b2def.EnableLimit = def.EnableLimit
+	b2def.LowerAngle = def.LowerAngle
+	b2def.UpperAngle = def.UpperAngle
+	b2def.EnableMotor = def.EnableMotor
+	b2def.MotorSpeed = def.MotorSpeed
+	b2def.MaxMotorTorque = def.MaxMotorTorque
+
+	return world.createJoint(JOINT_REVOLUTE, def.JointDef, &b2def.B2JointDef, &b2def)
+}
+
+func (world *World) CreateWeldJoint(def WeldJointDef) *Joint {
+	b2def := box2d.MakeB2WeldJointDef()
+	b2def.LocalAnchorA = toBox2dVec2(def.AnchorA)
+	b2def.LocalAnchorB = toBox2dVec2(def.AnchorB)
+	b2def.ReferenceAngle = def.ReferenceAngle
+	b2def.FrequencyHz = def.FrequencyHz
+	b2def.DampingRatio = def.DampingRatio
+
+	return world.createJoint(JOINT_WELD, def.JointDef, &b2def.B2JointDef, &b2def)
+}
+
+func (world *World) CreateDistanceJoint(def DistanceJointDef) *Joint {
+	b2def := box2d.MakeB2DistanceJointDef()
+	b2def.LocalAnchorA = toBox2dVec2(def.AnchorA)
+	b2def.LocalAnchorB = toBox2dVec2(def.AnchorB)
+	b2def.Length = def.Length
+	b2def.FrequencyHz = def.FrequencyHz
+	b2def.DampingRatio = def.DampingRatio
+
+	return world.createJoint(JOINT_DISTANCE, def.JointDef, &b2def.B2JointDef, &b2def)
+}
+
+func (world *World) CreateRopeJoint(def RopeJointDef) *Joint {
+	b2def := box2d.MakeB2RopeJointDef()
+	b2def.LocalAnchorA = toBox2dVec2(def.AnchorA)
+	b2def.LocalAnchorB = toBox2dVec2(def.AnchorB)
+	b2def.MaxLength = def.MaxLength
+
+	return world.createJoint(JOINT_ROPE, def.JointDef, &b2def.B2JointDef, &b2def)
+}
+
+// Fill what every joint has into common, which is part of b2def, and create the joint.
+// It's added to the world now if both bodies are baked, otherwise when they are.
+func (world *World) createJoint(jointType JointType, def JointDef, common *box2d.B2JointDef, b2def box2d.B2JointDefInterface) *Joint {
+	joint := &Joint{
+		world:      world,
+		jointType:  jointType,
+		bodyA:      def.BodyA,
+		bodyB:      def.BodyB,
+		breakForce: def.BreakForce,
+		b2def:      b2def,
+		common:     common,
+	}
+	common.CollideConnected = def.CollideConnected
+	common.UserData = joint
+
+	def.BodyA.joints = append(def.BodyA.joints, joint)
+	def.BodyB.joints = append(def.BodyB.joints, joint)
+	joint.bake()
+
+	return joint
+}
+
+// Add the joint to the world if both bodies are baked and it isn't yet.
+func (joint *Joint) bake() {
+	if joint.b2def == nil || joint.bodyA.b2body == nil || joint.bodyB.b2body == nil {
+		return
+	}
+
+	joint.common.BodyA = joint.bodyA.b2body
+	joint.common.BodyB = joint.bodyB.b2body
+	joint.b2joint = joint.world.b2world.CreateJoint(joint.b2def)
+	joint.b2def = nil
+	joint.common = nil
+}
+
+// Destroy joints whose reaction force in the last step is greater than their break force.
+func (world *World) breakJoints() {
+	broken := make([]*Joint, 0)
+	for b2joint := world.b2world.GetJointList(); b2joint != nil; b2joint = b2joint.GetNext() {
+		joint, ok := b2joint.GetUserData().(*Joint)
+		if ok && joint.breakForce > 0 && joint.GetReactionForce() > joint.breakForce {
+			broken = append(broken, joint)
+		}
+	}
+
+	for _, joint := range broken {
+		joint.Destroy()
+		joint.broken = true
+	}
+}
+
+func (joint *Joint) Type() JointType {
+	return joint.jointType
+}
+
+func (joint *Joint) BodyA() *Body {
+	return joint.bodyA
+}
+
+func (joint *Joint) BodyB() *Body {
+	return joint.bodyB
+}
+
+// Whether the joint is removed from the world, by Destroy, a body or breaking.
+func (joint *Joint) Destroyed() bool {
+	return joint.destroyed
+}
+
+// Whether the joint is destroyed because it's pulled harder than its break force.
+func (joint *Joint) Broken() bool {
+	return joint.broken
+}
+
+// Magnitude of force the joint applied to BodyB in the last world step.
+// Zero after it's destroyed.
+func (joint *Joint) GetReactionForce() float64 {
+	reaction, ok := joint.b2joint.(b2ReactionJoint)
+	if !ok {
+		return 0
+	}
+	force := reaction.GetReactionForce(joint.world.invDt)
+	return math.Hypot(force.X, force.Y)
+}
+
+// Torque the joint applied to BodyB in the last world step.
+func (joint *Joint) GetReactionTorque() float64 {
+	reaction, ok := joint.b2joint.(b2ReactionJoint)
+	if !ok {
+		return 0
+	}
+	return reaction.GetReactionTorque(joint.world.invDt)
+}
+
+// How far BodyB slid along the axis of prismatic joint, zero for other joints.
+func (joint *Joint) GetTranslation() float64 {
+	if prismatic, ok := joint.b2joint.(*box2d.B2PrismaticJoint); ok {
+		return prismatic.GetJointTranslation()
+	}
+	return 0
+}
+
+// How far BodyB turned from the reference angle of revolute joint, zero for other joints.
+func (joint *Joint) GetAngle() float64 {
+	if revolute, ok := joint.b2joint.(*box2d.B2RevoluteJoint); ok {
+		return revolute.GetJointAngle()
+	}
+	return 0
+}
+
+// Motor speed of prismatic and revolute joints, zero for other joints.
+func (joint *Joint) GetMotorSpeed() float64 {
+	switch b2joint := joint.b2joint.(type) {
+	case *box2d.B2PrismaticJoint:
+		return b2joint.GetMotorSpeed()
+	case *box2d.B2RevoluteJoint:
+		return b2joint.GetMotorSpeed()
+	}
+	switch b2def := joint.b2def.(type) {
+	case *box2d.B2PrismaticJointDef:
+		return b2def.MotorSpeed
+	case *box2d.B2RevoluteJointDef:
+		return b2def.MotorSpeed
+	}
+	return 0
+}
+
+// Change motor speed of prismatic and revolute joints, in units or radians per second.
+// Other joints have no motor.
+func (joint *Joint) SetMotorSpeed(speed float64) {
+	switch b2joint := joint.b2joint.(type) {
+	case *box2d.B2PrismaticJoint:
+		b2joint.SetMotorSpeed(speed)
+	case *box2d.B2RevoluteJoint:
+		b2joint.SetMotorSpeed(speed)
+	}
+	switch b2def := joint.b2def.(type) {
+	case *box2d.B2PrismaticJointDef:
+		b2def.MotorSpeed = speed
+	case *box2d.B2RevoluteJointDef:
+		b2def.MotorSpeed = speed
+	}
+}
+
+// Max force of prismatic joint motor, or max torque of revolute joint motor.
+func (joint *Joint) SetMaxMotorForce(force float64) {
+	switch b2joint := joint.b2joint.(type) {
+	case *box2d.B2PrismaticJoint:
+		b2joint.SetMaxMotorForce(force)
+	case *box2d.B2RevoluteJoint:
+		b2joint.SetMaxMotorTorque(force)
+	}
+	switch b2def := joint.b2def.(type) {
+	case *box2d.B2PrismaticJointDef:
+		b2def.MaxMotorForce = force
+	case *box2d.B2RevoluteJointDef:
+		b2def.MaxMotorTorque = force
+	}
+}
+
+func (joint *Joint) EnableMotor(enable bool) {
+	switch b2joint := joint.b2joint.(type) {
+	case *box2d.B2PrismaticJoint:
+		b2joint.EnableMotor(enable)
+	case *box2d.B2RevoluteJoint:
+		b2joint.EnableMotor(enable)
+	}
+	switch b2def := joint.b2def.(type) {
+	case *box2d.B2PrismaticJointDef:
+		b2def.EnableMotor = enable
+	case *box2d.B2RevoluteJointDef:
+		b2def.EnableMotor = enable
+	}
+}
+
+// Remove the joint from the world and from its bodies.
+// It does nothing if the joint is destroyed already.
+func (joint *Joint) Destroy() {
+	if joint.destroyed {
+		return
+	}
+
+	if joint.b2joint != nil {
+		joint.world.b2world.DestroyJoint(joint.b2joint)
+	}
+	joint.destroyed = true
+	joint.b2joint = nil
+	joint.b2def = nil
+	joint.common = nil
+	joint.bodyA.removeJoint(joint)
+	joint.bodyB.removeJoint(joint)
+}
+
+func (body *Body) removeJoint(joint *Joint) {
+	for i, other := range body.joints {
+		if other == joint {
+			body.joints = append(body.joints[:i], body.joints[i+1:]...)
+			return
+		}
+	}
+}
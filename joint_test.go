@@ -0,0 +1,192 @@
+package lostinspace_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+// Static body at the origin and a ball at (x, 0), both baked.
+func testJointBodies(world *lostinspace.World, x float64) (*lostinspace.Body, *lostinspace.Body) {
+	base := world.CreateBody(lostinspace.STATIC)
+	base.Bake()
+
+	ball := world.CreateBody(lostinspace.DYNAMIC)
+	ball.AddCircleFixture(1, 0.2, 0, 0.25)
+	ball.SetPosition(x, 0)
+	ball.Bake()
+
+	return base, ball
+}
+
+func TestJointTypes(t *testing.T) {
+	world := lostinspace.NewWorld()
+	create := []struct {
+		jointType lostinspace.JointType
+		create    func(def lostinspace.JointDef) *lostinspace.Joint
+	}{
+		{lostinspace.JOINT_PRISMATIC, func(def lostinspace.JointDef) *lostinspace.Joint {
+			return world.CreatePrismaticJoint(lostinspace.PrismaticJointDef{JointDef: def, Axis: lostinspace.Vec2{X: 1}})
+		}},
+		{lostinspace.JOINT_REVOLUTE, func(def lostinspace.JointDef) *lostinspace.Joint {
+			return world.CreateRevoluteJoint(lostinspace.RevoluteJointDef{JointDef: def})
+		}},
+		{lostinspace.JOINT_WELD, func(def lostinspace.JointDef) *lostinspace.Joint {
+			return world.CreateWeldJoint(lostinspace.WeldJointDef{JointDef: def})
+		}},
+		{lostinspace.JOINT_DISTANCE, func(def lostinspace.JointDef) *lostinspace.Joint {
+			return world.CreateDistanceJoint(lostinspace.DistanceJointDef{JointDef: def, Length: 2})
+		}},
+		{lostinspace.JOINT_ROPE, func(def lostinspace.JointDef) *lostinspace.Joint {
+			return world.CreateRopeJoint(lostinspace.RopeJointDef{JointDef: def, MaxLength: 2})
+		}},
+	}
+
+	for i, c := range create {
+		base, ball := testJointBodies(world, 2)
+		joint := c.create(lostinspace.JointDef{BodyA: base, BodyB: ball})
+		if joint.Type() != c.jointType || joint.BodyA() != base || joint.BodyB() != ball || joint.Destroyed() {
+			t.Errorf("Joint %d: %+v\n", i, joint)
+		}
+		for j := 0; j < 10; j++ {
+			world.Update(testStep)
+		}
+
+		// Destroying either body takes the joint with it.
+		if i%2 == 0 {
+			base.Destroy()
+		} else {
+			ball.Destroy()
+		}
+		if !joint.Destroyed() || joint.Broken() || joint.GetReactionForce() != 0 {
+			t.Errorf("Joint %d after its body is destroyed: %+v\n", i, joint)
+		}
+		joint.Destroy()
+		world.Update(testStep)
+	}
+}
+
+func TestJointMotor(t *testing.T) {
+	world := lostinspace.NewWorld()
+
+	base, ball := testJointBodies(world, 0)
+	prismatic := world.CreatePrismaticJoint(lostinspace.PrismaticJointDef{
+		JointDef:    lostinspace.JointDef{BodyA: base, BodyB: ball},
+		Axis:        lostinspace.Vec2{X: 0, Y: 1},
+		EnableLimit: true, Lower: -1, Upper: 2,
+		EnableMotor: true, MotorSpeed: 1, MaxMotorForce: 100,
+	})
+	for i := 0; i < 60; i++ {
+		world.Update(testStep)
+	}
+	if translation := prismatic.GetTranslation(); math.Abs(translation-1) > 0.05 {
+		t.Errorf("Translation after a second: %v\n", translation)
+	}
+	if _, y := ball.GetPosition(); math.Abs(y-1) > 0.05 {
+		t.Errorf("Ball is at %v\n", y)
+	}
+
+	// Back to the lower limit.
+	prismatic.SetMotorSpeed(-2)
+	if prismatic.GetMotorSpeed() != -2 {
+		t.Errorf("Motor speed: %v\n", prismatic.GetMotorSpeed())
+	}
+	for i := 0; i < 120; i++ {
+		world.Update(testStep)
+	}
+	if translation := prismatic.GetTranslation(); math.Abs(translation+1) > 0.05 {
+		t.Errorf("Translation at the lower limit: %v\n", translation)
+	}
+
+	base, wheel := testJointBodies(world, 5)
+	revolute := world.CreateRevoluteJoint(lostinspace.RevoluteJointDef{
+		JointDef:    lostinspace.JointDef{BodyA: base, BodyB: wheel, AnchorA: lostinspace.Vec2{X: 5}},
+		EnableMotor: true, MotorSpeed: math.Pi, MaxMotorTorque: 100,
+	})
+	for i := 0; i < 30; i++ {
+		world.Update(testStep)
+	}
+	if angle := revolute.GetAngle(); math.Abs(angle-math.Pi/2) > 0.05 {
+		t.Errorf("Angle after half a second: %v\n", angle)
+	}
+	if prismatic.GetAngle() != 0 || revolute.GetTranslation() != 0 {
+		t.Errorf("Angle of prismatic joint or translation of revolute joint\n")
+	}
+
+	revolute.EnableMotor(false)
+	revolute.SetMaxMotorForce(0)
+	revolute.Destroy()
+	if !revolute.Destroyed() || revolute.GetAngle() != 0 {
+		t.Errorf("Revolute joint after destroyed: %+v\n", revolute)
+	}
+}
+
+func TestJointUnbakedBody(t *testing.T) {
+	world := lostinspace.NewWorld()
+
+	base := world.CreateBody(lostinspace.STATIC)
+	ball := world.CreateBody(lostinspace.DYNAMIC)
+	ball.AddCircleFixture(1, 0.2, 0, 0.25)
+	prismatic := world.CreatePrismaticJoint(lostinspace.PrismaticJointDef{
+		JointDef:    lostinspace.JointDef{BodyA: base, BodyB: ball},
+		Axis:        lostinspace.Vec2{X: 0, Y: 1},
+		EnableLimit: true, Lower: -1, Upper: 2,
+		EnableMotor: true, MaxMotorForce: 100,
+	})
+	// Settings before the joint is in the world are kept.
+	prismatic.SetMotorSpeed(1)
+	if prismatic.Destroyed() || prismatic.GetMotorSpeed() != 1 || prismatic.GetTranslation() != 0 {
+		t.Errorf("Joint of unbaked bodies: %+v\n", prismatic)
+	}
+
+	base.Bake()
+	ball.Bake()
+	for i := 0; i < 60; i++ {
+		world.Update(testStep)
+	}
+	if translation := prismatic.GetTranslation(); math.Abs(translation-1) > 0.05 {
+		t.Errorf("Translation after the bodies are baked: %v\n", translation)
+	}
+
+	// Destroyed before it's ever in the world.
+	other := world.CreateBody(lostinspace.DYNAMIC)
+	weld := world.CreateWeldJoint(lostinspace.WeldJointDef{JointDef: lostinspace.JointDef{BodyA: base, BodyB: other}})
+	other.Destroy()
+	other.Bake()
+	world.Update(testStep)
+	if !weld.Destroyed() || weld.GetReactionForce() != 0 {
+		t.Errorf("Weld joint of destroyed body: %+v\n", weld)
+	}
+}
+
+func TestJointBreak(t *testing.T) {
+	world := lostinspace.NewWorld()
+
+	pull := func(breakForce float64) *lostinspace.Joint {
+		base, ball := testJointBodies(world, 2)
+		joint := world.CreateRopeJoint(lostinspace.RopeJointDef{
+			JointDef:  lostinspace.JointDef{BodyA: base, BodyB: ball, BreakForce: breakForce},
+			MaxLength: 2,
+		})
+		for i := 0; i < 30; i++ {
+			ball.ApplyForceToCenter(lostinspace.Vec2{X: 50})
+			world.Update(testStep)
+		}
+		return joint
+	}
+
+	// Force pulling the ball is less than the break force.
+	strong := pull(1000)
+	if strong.Destroyed() || strong.GetReactionForce() < 40 {
+		t.Errorf("Strong rope: destroyed %v, reaction force %v\n", strong.Destroyed(), strong.GetReactionForce())
+	}
+
+	weak := pull(10)
+	if !weak.Destroyed() || !weak.Broken() {
+		t.Errorf("Weak rope: destroyed %v, broken %v\n", weak.Destroyed(), weak.Broken())
+	}
+	if x, _ := weak.BodyB().GetPosition(); x <= 2 {
+		t.Errorf("Ball is at %v after the rope broke\n", x)
+	}
+}
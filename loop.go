@@ -0,0 +1,63 @@
+package lostinspace
+
+import "time"
+
+// Loop turns frame times into steps of fixed size,
+// so that the simulation runs the same no matter how fast frames are drawn.
+//
+//	loop := NewLoop(time.Second/60, 5)
+//	for {
+//	    loop.Advance(frameTime, game.Update)
+//	    game.Render(loop.Alpha())
+//	}
+type Loop struct {
+	step     time.Duration
+	maxSteps int
+
+	// Time which is not stepped yet, less than a step after Advance.
+	accumulator time.Duration
+}
+
+// Steps at most maxSteps per Advance, no limit if maxSteps is not positive.
+func NewLoop(step time.Duration, maxSteps int) *Loop {
+	loop := &Loop{
+		step:     step,
+		maxSteps: maxSteps,
+	}
+
+	return loop
+}
+
+func (loop *Loop) Step() time.Duration {
+	return loop.step
+}
+
+// Call step for every whole step in elapsed time plus time left from the last call.
+// The rest is carried over to the next call.
+// If more than max steps are due, like after a long hitch,
+// only max steps are run and the time behind is dropped,
+// so the loop slows down instead of falling behind more and more.
+// Return number of steps run.
+func (loop *Loop) Advance(elapsed time.Duration, step func(time.Duration)) int {
+	loop.accumulator += elapsed
+
+	steps := 0
+	for loop.accumulator >= loop.step {
+		if loop.maxSteps > 0 && steps == loop.maxSteps {
+			loop.accumulator = 0
+			break
+		}
+
+		step(loop.step)
+		loop.accumulator -= loop.step
+		steps++
+	}
+
+	return steps
+}
+
+// How far it is from the last step to the next one, between 0 and 1.
+// Render positions interpolated by it to move smoothly between steps.
+func (loop *Loop) Alpha() float64 {
+	return float64(loop.accumulator) / float64(loop.step)
+}
@@ -0,0 +1,75 @@
+package lostinspace_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+func TestLoop(t *testing.T) {
+	loop := lostinspace.NewLoop(10*time.Millisecond, 5)
+
+	var stepped time.Duration
+	step := func(dt time.Duration) {
+		if dt != 10*time.Millisecond {
+			t.Errorf("Step of %v\n", dt)
+		}
+		stepped += dt
+	}
+
+	if steps := loop.Advance(25*time.Millisecond, step); steps != 2 || loop.Alpha() != 0.5 {
+		t.Errorf("%d steps, alpha %v\n", steps, loop.Alpha())
+	}
+	// Time left from the last call makes another step.
+	if steps := loop.Advance(7*time.Millisecond, step); steps != 1 || math.Abs(loop.Alpha()-0.2) > 1e-9 {
+		t.Errorf("%d steps, alpha %v\n", steps, loop.Alpha())
+	}
+	if stepped != 30*time.Millisecond {
+		t.Errorf("Stepped %v\n", stepped)
+	}
+
+	// Hitch doesn't make the loop run more than max steps nor fall behind.
+	if steps := loop.Advance(time.Second, step); steps != 5 || loop.Alpha() != 0 {
+		t.Errorf("%d steps, alpha %v\n", steps, loop.Alpha())
+	}
+	if steps := loop.Advance(10*time.Millisecond, step); steps != 1 {
+		t.Errorf("%d steps after hitch\n", steps)
+	}
+}
+
+func TestBodyInterpolation(t *testing.T) {
+	world := lostinspace.NewWorld()
+	body := world.CreateBody(lostinspace.DYNAMIC)
+	body.AddCircleFixture(1, 0, 0, 0.5)
+	body.SetPosition(3, 4)
+	body.Bake()
+
+	if x, y := body.GetInterpolatedPosition(0.5); x != 3 || y != 4 {
+		t.Errorf("Interpolated before any step: (%v, %v)\n", x, y)
+	}
+
+	body.ApplyForceToCenter(lostinspace.Vec2{X: 1000, Y: 0})
+	world.Update(time.Second / 60)
+
+	x, y := body.GetPosition()
+	if x <= 3 {
+		t.Fatalf("Body didn't move: (%v, %v)\n", x, y)
+	}
+	if ix, iy := body.GetInterpolatedPosition(0); ix != 3 || iy != 4 {
+		t.Errorf("Interpolated by 0: (%v, %v)\n", ix, iy)
+	}
+	if ix, iy := body.GetInterpolatedPosition(1); ix != x || iy != y {
+		t.Errorf("Interpolated by 1: (%v, %v), expected (%v, %v)\n", ix, iy, x, y)
+	}
+	if ix, _ := body.GetInterpolatedPosition(0.5); math.Abs(ix-(3+x)/2) > 1e-9 {
+		t.Errorf("Interpolated by 0.5: %v, expected %v\n", ix, (3+x)/2)
+	}
+
+	// Teleport isn't interpolated.
+	body.SetPosition(-10, 0)
+	if ix, _ := body.GetInterpolatedPosition(0.5); ix != -10 {
+		t.Errorf("Interpolated after teleport: %v\n", ix)
+	}
+}
@@ -1,6 +1,6 @@
 package lostinspace
 
-import "github.com/go-gl/gl/v4.1-compatibility/gl"
+import "math"
 
 const (
 	ATTRIB_POSITION  = 0
@@ -16,21 +16,23 @@ type Mesh struct {
 	Positions []float32
 	Colors    []float32
 	TexCoords []float32
-	Indices   []uint16
+	Indices   []uint32
 
 	elementsCount int32
 
-	ibo uint32
-	vao uint32
+	// Backend the mesh is baked by.
+	backend     Backend
+	indexBuffer BufferID
+	vertexArray VertexArrayID
 
-	positionBuffer uint32
-	colorBuffer    uint32
-	texCoordBuffer uint32
+	positionBuffer BufferID
+	colorBuffer    BufferID
+	texCoordBuffer BufferID
 }
 
-// This doesn't create opengl buffer objects.
-// You must call Bake() function to create opengl objects.
-func NewMesh(positions, colors, texCoords []float32, indices []uint16) *Mesh {
+// This doesn't create buffer objects.
+// You must call Bake() function to create them.
+func NewMesh(positions, colors, texCoords []float32, indices []uint32) *Mesh {
 	mesh := &Mesh{
 		Positions: positions,
 		Colors:    colors,
@@ -42,78 +44,93 @@ func NewMesh(positions, colors, texCoords []float32, indices []uint16) *Mesh {
 }
 
 func (mesh *Mesh) Draw() {
-	gl.BindVertexArray(mesh.vao)
-	gl.DrawElements(gl.TRIANGLES, mesh.elementsCount, gl.UNSIGNED_SHORT, gl.PtrOffset(0))
+	if mesh.backend == nil { // not baked
+		return
+	}
+	mesh.backend.DrawElements(mesh.vertexArray, mesh.elementsCount)
 }
 
-func (mesh *Mesh) Bake() {
-	if mesh.vao == 0 { // uninitialized
-		gl.GenVertexArrays(1, &mesh.vao)
-		gl.BindVertexArray(mesh.vao)
+// Upload vertices by backend. Baked mesh keeps using the same backend.
+func (mesh *Mesh) Bake(backend Backend) {
+	if mesh.backend == nil { // uninitialized
+		mesh.backend = backend
+		mesh.vertexArray = backend.CreateVertexArray()
 
-		gl.GenBuffers(1, &mesh.positionBuffer)
-		gl.GenBuffers(1, &mesh.colorBuffer)
-		gl.GenBuffers(1, &mesh.texCoordBuffer)
-		gl.GenBuffers(1, &mesh.ibo)
+		mesh.positionBuffer = backend.CreateBuffer()
+		mesh.colorBuffer = backend.CreateBuffer()
+		mesh.texCoordBuffer = backend.CreateBuffer()
+		mesh.indexBuffer = backend.CreateBuffer()
 	}
+	backend = mesh.backend
 
-	gl.BindVertexArray(mesh.vao)
+	mesh.bakeAttrib(ATTRIB_POSITION, mesh.positionBuffer, mesh.Positions)
+	mesh.bakeAttrib(ATTRIB_COLOR, mesh.colorBuffer, mesh.Colors)
+	mesh.bakeAttrib(ATTRIB_TEX_COORD, mesh.texCoordBuffer, mesh.TexCoords)
 
-	if mesh.Positions != nil {
-		gl.BindBuffer(gl.ARRAY_BUFFER, mesh.positionBuffer)
-		gl.BufferData(gl.ARRAY_BUFFER, len(mesh.Positions)*4, gl.Ptr(mesh.Positions), gl.DYNAMIC_DRAW)
+	backend.BufferData(mesh.indexBuffer, mesh.indexData())
+	backend.IndexBuffer(mesh.vertexArray, mesh.indexBuffer)
+	mesh.elementsCount = int32(len(mesh.Indices))
+}
 
-		gl.EnableVertexAttribArray(ATTRIB_POSITION)
-		gl.VertexAttribPointer(ATTRIB_POSITION, 3, gl.FLOAT, false, 3*4, gl.PtrOffset(0))
-	} else {
-		gl.DisableVertexAttribArray(ATTRIB_POSITION)
+// Upload count vertices from first, which are changed in place since the mesh is baked.
+// Number of vertices and indices must be the same as when it's baked.
+func (mesh *Mesh) BakeVertices(first, count int) {
+	if mesh.backend == nil { // not baked
+		return
 	}
 
-	if mesh.Colors != nil {
-		gl.BindBuffer(gl.ARRAY_BUFFER, mesh.colorBuffer)
-		gl.BufferData(gl.ARRAY_BUFFER, len(mesh.Colors)*4, gl.Ptr(mesh.Colors), gl.DYNAMIC_DRAW)
+	mesh.bakeSubAttrib(mesh.positionBuffer, mesh.Positions, first, count)
+	mesh.bakeSubAttrib(mesh.colorBuffer, mesh.Colors, first, count)
+	mesh.bakeSubAttrib(mesh.texCoordBuffer, mesh.TexCoords, first, count)
+}
 
-		gl.EnableVertexAttribArray(ATTRIB_COLOR)
-		gl.VertexAttribPointer(ATTRIB_COLOR, 3, gl.FLOAT, false, 3*4, gl.PtrOffset(0))
-	} else {
-		gl.DisableVertexAttribArray(ATTRIB_COLOR)
+func (mesh *Mesh) bakeSubAttrib(buffer BufferID, data []float32, first, count int) {
+	if data == nil {
+		return
 	}
+	mesh.backend.BufferSubData(buffer, first*3, data[first*3:(first+count)*3])
+}
 
-	if mesh.TexCoords != nil {
-		gl.BindBuffer(gl.ARRAY_BUFFER, mesh.texCoordBuffer)
-		gl.BufferData(gl.ARRAY_BUFFER, len(mesh.TexCoords)*4, gl.Ptr(mesh.TexCoords), gl.DYNAMIC_DRAW)
+// Indices are uploaded in 16 bits if every vertex can be indexed by them.
+func (mesh *Mesh) indexData() interface{} {
+	if len(mesh.Positions)/3 > math.MaxUint16+1 {
+		return mesh.Indices
+	}
 
-		gl.EnableVertexAttribArray(ATTRIB_TEX_COORD)
-		gl.VertexAttribPointer(ATTRIB_TEX_COORD, 3, gl.FLOAT, false, 3*4, gl.PtrOffset(0))
-	} else {
-		gl.DisableVertexAttribArray(ATTRIB_TEX_COORD)
+	indices := make([]uint16, len(mesh.Indices))
+	for i, index := range mesh.Indices {
+		indices[i] = uint16(index)
 	}
+	return indices
+}
 
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, mesh.ibo)
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(mesh.Indices)*2, gl.Ptr(mesh.Indices), gl.DYNAMIC_DRAW)
-	mesh.elementsCount = int32(len(mesh.Indices))
+// Attribute is disabled if there is no data.
+func (mesh *Mesh) bakeAttrib(attrib uint32, buffer BufferID, data []float32) {
+	if data == nil {
+		mesh.backend.VertexAttrib(mesh.vertexArray, attrib, 0)
+		return
+	}
+
+	mesh.backend.BufferData(buffer, data)
+	mesh.backend.VertexAttrib(mesh.vertexArray, attrib, buffer)
 }
 
 func (mesh *Mesh) Destroy() {
-	if mesh.positionBuffer != 0 {
-		gl.DeleteBuffers(1, &mesh.positionBuffer)
-		mesh.positionBuffer = 0
-	}
-	if mesh.colorBuffer != 0 {
-		gl.DeleteBuffers(1, &mesh.colorBuffer)
-		mesh.colorBuffer = 0
-	}
-	if mesh.texCoordBuffer != 0 {
-		gl.DeleteBuffers(1, &mesh.texCoordBuffer)
-		mesh.texCoordBuffer = 0
-	}
-	if mesh.ibo != 0 {
-		gl.DeleteBuffers(1, &mesh.ibo)
-		mesh.ibo = 0
-	}
-	if mesh.vao != 0 {
-		gl.DeleteVertexArrays(1, &mesh.vao)
-		mesh.vao = 0
+	if mesh.backend == nil {
+		return
 	}
+
+	mesh.backend.DeleteBuffer(mesh.positionBuffer)
+	mesh.backend.DeleteBuffer(mesh.colorBuffer)
+	mesh.backend.DeleteBuffer(mesh.texCoordBuffer)
+	mesh.backend.DeleteBuffer(mesh.indexBuffer)
+	mesh.backend.DeleteVertexArray(mesh.vertexArray)
+
+	mesh.backend = nil
+	mesh.positionBuffer = 0
+	mesh.colorBuffer = 0
+	mesh.texCoordBuffer = 0
+	mesh.indexBuffer = 0
+	mesh.vertexArray = 0
 	mesh.elementsCount = 0
 }
@@ -0,0 +1,98 @@
+package lostinspace
+
+// packedArray stores fixed number of small unsigned integers
+// using only as many bits per element as the largest element needs.
+//
+// Bits per element is one of 0, 1, 2, 4 and 8 so an element never
+// straddles two words. With 0 bits every element is 0 and no word is allocated.
+type packedArray struct {
+	length int
+	bits   uint8
+	words  []uint64
+}
+
+func newPackedArray(length int) packedArray {
+	return packedArray{length: length}
+}
+
+func (array *packedArray) Len() int {
+	return array.length
+}
+
+func (array *packedArray) Get(i int) int {
+	if array.bits == 0 {
+		return 0
+	}
+
+	perWord := 64 / int(array.bits)
+	shift := uint(i%perWord) * uint(array.bits)
+	mask := uint64(1)<<array.bits - 1
+
+	return int(array.words[i/perWord] >> shift & mask)
+}
+
+// Set element at i to value.
+// Array is repacked with more bits if value doesn't fit.
+// value must be less than 256.
+func (array *packedArray) Set(i, value int) {
+	if value >= 1<<array.bits {
+		array.grow(bitsFor(value))
+	}
+	if array.bits == 0 {
+		return
+	}
+
+	perWord := 64 / int(array.bits)
+	shift := uint(i%perWord) * uint(array.bits)
+	mask := uint64(1)<<array.bits - 1
+
+	word := &array.words[i/perWord]
+	*word = *word&^(mask<<shift) | uint64(value)<<shift
+}
+
+func (array *packedArray) clone() packedArray {
+	cloned := *array
+	cloned.words = append([]uint64(nil), array.words...)
+
+	return cloned
+}
+
+func (array *packedArray) grow(bits uint8) {
+	grown := packedArray{
+		length: array.length,
+		bits:   bits,
+		words:  make([]uint64, wordsFor(array.length, bits)),
+	}
+	for i := 0; i < array.length; i++ {
+		if value := array.Get(i); value != 0 {
+			grown.Set(i, value)
+		}
+	}
+
+	*array = grown
+}
+
+// Smallest bits per element which can hold value.
+func bitsFor(value int) uint8 {
+	switch {
+	case value == 0:
+		return 0
+	case value < 1<<1:
+		return 1
+	case value < 1<<2:
+		return 2
+	case value < 1<<4:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func wordsFor(length int, bits uint8) int {
+	if bits == 0 {
+		return 0
+	}
+	perWord := 64 / int(bits)
+
+	return (length + perWord - 1) / perWord
+}
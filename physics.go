@@ -18,26 +18,29 @@ type BodyType int
 // Such as chunks, entities, etc.
 type World struct {
 	b2world *box2d.B2World
+	// Inverse of the last time step, to tell forces of joints.
+	invDt float64
 }
 
 // Physics body which can collide.
 type Body struct {
 	world *World
 
-	bodyDef *box2d.B2BodyDef
-	fixDefs []*box2d.B2FixtureDef
+	bodyDef  *box2d.B2BodyDef
+	fixtures []*Fixture
+	// Joints attached to the body, destroyed along with it.
+	joints []*Joint
 
 	b2body *box2d.B2Body
-}
-
-type Joint struct { // TODO
-	world *World
 
-	jointDef box2d.B2JointDefInterface
-	b2joint  box2d.B2JointInterface
+	// Transform before the last world step, for interpolation.
+	prevPosition Vec2
+	prevAngle    float64
 }
 
+// Fixture of a body, which is created in the world when the body is baked.
 type Fixture struct {
+	fixDef    *box2d.B2FixtureDef
 	b2fixture *box2d.B2Fixture
 }
 
@@ -50,7 +53,18 @@ func NewWorld() *World {
 }
 
 func (world *World) Update(dt time.Duration) {
+	for b2body := world.b2world.GetBodyList(); b2body != nil; b2body = b2body.GetNext() {
+		if body, ok := b2body.GetUserData().(*Body); ok {
+			body.prevPosition = toVec2(b2body.GetPosition())
+			body.prevAngle = b2body.GetAngle()
+		}
+	}
+
 	world.b2world.Step(dt.Seconds(), 8, 3)
+	if dt > 0 {
+		world.invDt = 1 / dt.Seconds()
+	}
+	world.breakJoints()
 }
 
 func (world *World) CreateBody(bodyType BodyType) *Body {
@@ -71,45 +85,7 @@ func (world *World) CreateBody(bodyType BodyType) *Body {
 	return body
 }
 
-func (world *World) CreatePrismaticJoint(
-	bodyA, bodyB *Body,
-	anchorA, anchorB, axisA Vec2,
-	angle float64,
-	limited bool, min, max float64,
-	motored bool, motorSpeed, motorForce float64,
-	collide bool,
-) *Joint { // TODO wtf how can i create joints in bytearena/box2d? there is no fucking single examples about joints.
-	def := box2d.MakeB2PrismaticJointDef()
-
-	def.BodyA = bodyA.b2body
-	def.BodyB = bodyB.b2body
-
-	def.LocalAnchorA = toBox2dVec2(anchorA)
-	def.LocalAnchorB = toBox2dVec2(anchorB)
-	def.LocalAxisA = toBox2dVec2(axisA)
-
-	def.ReferenceAngle = angle
-
-	def.EnableLimit = limited
-	def.LowerTranslation = min
-	def.UpperTranslation = max
-
-	def.EnableMotor = motored
-	def.MotorSpeed = motorSpeed
-	def.MaxMotorForce = motorForce
-
-	def.CollideConnected = collide
-
-	b2joint := world.b2world.CreateJoint(&def) // pass pointer to joint def
-
-	joint := new(Joint)
-	joint.jointDef = &def
-	joint.b2joint = b2joint
-
-	return joint
-}
-
-func (body *Body) AddCircleFixture(density, friction, restitution, radius float64) {
+func (body *Body) AddCircleFixture(density, friction, restitution, radius float64) *Fixture {
 	shape := box2d.MakeB2CircleShape()
 	shape.SetRadius(radius)
 
@@ -119,10 +95,10 @@ func (body *Body) AddCircleFixture(density, friction, restitution, radius float6
 	fixDef.Restitution = restitution
 	fixDef.Shape = &shape
 
-	body.fixDefs = append(body.fixDefs, &fixDef)
+	return body.addFixture(&fixDef)
 }
 
-func (body *Body) AddPolygonFixture(density, friction, restitution float64, vertices []Vec2) {
+func (body *Body) AddPolygonFixture(density, friction, restitution float64, vertices []Vec2) *Fixture {
 	b2vecs := make([]box2d.B2Vec2, len(vertices))
 	for i, vec := range vertices {
 		b2vecs[i] = box2d.B2Vec2(vec)
@@ -137,33 +113,66 @@ func (body *Body) AddPolygonFixture(density, friction, restitution float64, vert
 	fixDef.Restitution = restitution
 	fixDef.Shape = &shape
 
-	body.fixDefs = append(body.fixDefs, &fixDef)
+	return body.addFixture(&fixDef)
+}
+
+func (body *Body) addFixture(fixDef *box2d.B2FixtureDef) *Fixture {
+	fixture := &Fixture{fixDef: fixDef}
+	body.fixtures = append(body.fixtures, fixture)
+
+	return fixture
 }
 
 func (body *Body) Bake() {
 	if body.b2body == nil {
 		body.b2body = body.world.b2world.CreateBody(body.bodyDef)
+		body.b2body.SetUserData(body)
+		body.prevPosition = toVec2(body.bodyDef.Position)
+		body.prevAngle = body.bodyDef.Angle
 	}
 
-	for _, fixDef := range body.fixDefs {
-		body.b2body.CreateFixtureFromDef(fixDef)
+	// Fixtures added since the last bake.
+	for _, fixture := range body.fixtures {
+		if fixture.b2fixture == nil {
+			fixture.b2fixture = body.b2body.CreateFixtureFromDef(fixture.fixDef)
+		}
+	}
+	// Joints made while this or the other body wasn't baked.
+	for _, joint := range body.joints {
+		joint.bake()
 	}
 }
 
+// Fixtures added to the body since it's cleared.
+func (body *Body) GetFixtureCount() int {
+	return len(body.fixtures)
+}
+
 // Destroy all fixtures
 func (body *Body) Clear() {
-	if body.b2body != nil {
-		fixList := make([]*box2d.B2Fixture, 0)
-		for fix := body.b2body.GetFixtureList(); fix != nil; fix = fix.GetNext() {
-			fixList = append(fixList, fix)
-		}
+	for _, fixture := range body.fixtures {
+		body.destroyFixture(fixture)
+	}
 
-		for _, fix := range fixList {
-			body.b2body.DestroyFixture(fix)
+	body.fixtures = nil
+}
+
+// Remove a fixture of the body, leaving the others as they are.
+func (body *Body) DestroyFixture(fixture *Fixture) {
+	for i, other := range body.fixtures {
+		if other == fixture {
+			body.destroyFixture(fixture)
+			body.fixtures = append(body.fixtures[:i], body.fixtures[i+1:]...)
+			return
 		}
 	}
+}
 
-	body.fixDefs = nil
+func (body *Body) destroyFixture(fixture *Fixture) {
+	if fixture.b2fixture != nil {
+		body.b2body.DestroyFixture(fixture.b2fixture)
+		fixture.b2fixture = nil
+	}
 }
 
 func (body *Body) GetPosition() (float64, float64) {
@@ -176,6 +185,36 @@ func (body *Body) GetPosition() (float64, float64) {
 	return pos.X, pos.Y
 }
 
+// Position between the one before the last world step and the current one.
+// alpha 0 is the former and 1 is the latter, see Loop.Alpha.
+func (body *Body) GetInterpolatedPosition(alpha float64) (float64, float64) {
+	x, y := body.GetPosition()
+	if body.b2body == nil {
+		return x, y
+	}
+
+	return lerp(body.prevPosition.X, x, alpha), lerp(body.prevPosition.Y, y, alpha)
+}
+
+func (body *Body) GetInterpolatedAngle(alpha float64) float64 {
+	angle := body.GetAngle()
+	if body.b2body == nil {
+		return angle
+	}
+
+	return lerp(body.prevAngle, angle, alpha)
+}
+
+func (body *Body) GetLinearVelocity() (float64, float64) {
+	var vel box2d.B2Vec2
+	if body.b2body == nil {
+		vel = body.bodyDef.LinearVelocity
+	} else {
+		vel = body.b2body.GetLinearVelocity()
+	}
+	return vel.X, vel.Y
+}
+
 func (body *Body) GetAngle() float64 {
 	if body.b2body == nil {
 		return body.bodyDef.Angle
@@ -184,11 +223,32 @@ func (body *Body) GetAngle() float64 {
 	}
 }
 
+// Teleport body, it's not interpolated from where it was.
 func (body *Body) SetPosition(x, y float64) {
 	if body.b2body == nil {
 		body.bodyDef.Position = box2d.MakeB2Vec2(x, y)
 	} else {
 		body.b2body.SetTransform(box2d.MakeB2Vec2(x, y), 0)
+		body.prevPosition = Vec2{x, y}
+		body.prevAngle = 0
+	}
+}
+
+// Turn body at once, it's not interpolated from the angle it was.
+func (body *Body) SetAngle(angle float64) {
+	if body.b2body == nil {
+		body.bodyDef.Angle = angle
+	} else {
+		body.b2body.SetTransform(body.b2body.GetPosition(), angle)
+		body.prevAngle = angle
+	}
+}
+
+func (body *Body) SetLinearVelocity(x, y float64) {
+	if body.b2body == nil {
+		body.bodyDef.LinearVelocity = box2d.MakeB2Vec2(x, y)
+	} else {
+		body.b2body.SetLinearVelocity(box2d.MakeB2Vec2(x, y))
 	}
 }
 
@@ -207,9 +267,16 @@ func (body *Body) ApplyForceToCenter(force Vec2) {
 	body.b2body.ApplyForceToCenter(box2d.B2Vec2(force), true)
 }
 
-// Destroy body from world.
+// Destroy body from world, with joints attached to it.
+// Body which isn't baked yet only loses its fixtures.
 func (body *Body) Destroy() {
+	for len(body.joints) > 0 {
+		body.joints[0].Destroy()
+	}
 	body.Clear()
+	if body.b2body == nil {
+		return
+	}
 	b2world := body.b2body.GetWorld()
 	b2world.DestroyBody(body.b2body)
 	body.b2body = nil
@@ -218,3 +285,7 @@ func (body *Body) Destroy() {
 func toBox2dVec2(vec Vec2) box2d.B2Vec2 {
 	return box2d.B2Vec2(vec)
 }
+
+func toVec2(vec box2d.B2Vec2) Vec2 {
+	return Vec2(vec)
+}
@@ -6,7 +6,8 @@ type Player struct {
 	*Body
 }
 
-func NewPlayer(world *World, texture Texture) *Player {
+// Player on the screen, moved by body of the simulation.
+func NewPlayer(body *Body, texture Texture) *Player {
 	player := new(Player)
 	player.Mesh = NewMesh(
 		[]float32{
@@ -22,13 +23,19 @@ func NewPlayer(world *World, texture Texture) *Player {
 			1, 1, 0,
 			1, 0, 0,
 		},
-		[]uint16{0, 1, 2, 0, 2, 3},
+		[]uint32{0, 1, 2, 0, 2, 3},
 	)
 	player.Texture = texture
-	player.Body = world.CreateBody(DYNAMIC)
-	player.Body.AddCircleFixture(1.0, 0.2, 0.05, 0.49)
-	player.Body.SetLinearDamping(2.0)
-	player.Body.Bake()
+	player.Body = body
 
 	return player
 }
+
+func NewPlayerBody(world *World) *Body {
+	body := world.CreateBody(DYNAMIC)
+	body.AddCircleFixture(1.0, 0.2, 0.05, 0.49)
+	body.SetLinearDamping(2.0)
+	body.Bake()
+
+	return body
+}
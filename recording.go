@@ -0,0 +1,272 @@
+package lostinspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// Recording of a play session, enough to run it again exactly.
+// Record with Recorder, run again with Replayer.
+//
+// File is JSON lines. The first line is RecordingHeader,
+// the others are RecordedEvents in the order they were handled.
+//
+//	{"version":1,"name":"universe","seed":42,"universe":"session.rec.universe","step":16666666,...}
+//	{"tick":0,"name":"keyboardEvent","event":{"Key":68,"Scancode":0,"Action":1,"Mods":0}}
+//	{"tick":12,"name":"editBlockEvent","event":{"Coord":{"X":3,"Y":-1},"BlockType":"stone","FrontFace":0}}
+//	...
+//
+// Terrain isn't recorded, the universe is copied next to the recording when it starts
+// and replay runs on that copy.
+type Recording struct {
+	RecordingHeader
+	Events []RecordedEvent
+}
+
+// Seed and the state of the simulation when recording started.
+type RecordingHeader struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Seed    int64  `json:"seed"`
+	// Directory next to the recording, with the universe as it was when recording started.
+	// Empty if there is no copy, replay starts from a new universe then.
+	Universe string `json:"universe,omitempty"`
+	// Duration of a simulation step.
+	Step           time.Duration `json:"step"`
+	PlayerPosition Vec2          `json:"playerPosition"`
+	PlayerVelocity Vec2          `json:"playerVelocity"`
+}
+
+type RecordedEvent struct {
+	// Steps since the recording started. Event is handled in the next step.
+	Tick  uint64
+	Event Event
+}
+
+const RECORDING_FORMAT_VERSION = 1
+
+// Events which can be recorded, by name.
+// Mouse buttons aren't, Game turns them into EditBlockEvents which are,
+// so a replay with Game would edit twice.
+var recordableEvents map[string]reflect.Type
+
+func init() {
+	recordableEvents = make(map[string]reflect.Type)
+	for _, event := range []Event{
+		KeyboardEvent{},
+		ScrollEvent{},
+		CursorEnterEvent{},
+		CursorPosEvent{},
+		WindowSizeEvent{},
+		EditBlockEvent{},
+	} {
+		recordableEvents[event.Name()] = reflect.TypeOf(event)
+	}
+}
+
+type recordedEventJSON struct {
+	Tick  uint64          `json:"tick"`
+	Name  string          `json:"name"`
+	Event json.RawMessage `json:"event"`
+}
+
+func (recorded RecordedEvent) MarshalJSON() ([]byte, error) {
+	raw, err := json.Marshal(recorded.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(recordedEventJSON{recorded.Tick, recorded.Event.Name(), raw})
+}
+
+func (recorded *RecordedEvent) UnmarshalJSON(data []byte) error {
+	var raw recordedEventJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	eventType, exist := recordableEvents[raw.Name]
+	if !exist {
+		return fmt.Errorf("unknown event %q", raw.Name)
+	}
+	event := reflect.New(eventType)
+	if err := json.Unmarshal(raw.Event, event.Interface()); err != nil {
+		return fmt.Errorf("%s: %w", raw.Name, err)
+	}
+
+	recorded.Tick = raw.Tick
+	recorded.Event = event.Elem().Interface().(Event)
+
+	return nil
+}
+
+func ReadRecording(r io.Reader) (*Recording, error) {
+	decoder := json.NewDecoder(r)
+
+	recording := new(Recording)
+	if err := decoder.Decode(&recording.RecordingHeader); err != nil {
+		return nil, fmt.Errorf("recording header: %w", err)
+	}
+	if recording.Version > RECORDING_FORMAT_VERSION {
+		return nil, fmt.Errorf("recording version %d is newer than supported version %d",
+			recording.Version, RECORDING_FORMAT_VERSION)
+	}
+
+	for {
+		var recorded RecordedEvent
+		err := decoder.Decode(&recorded)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("recorded event %d: %w", len(recording.Events), err)
+		}
+		recording.Events = append(recording.Events, recorded)
+	}
+
+	return recording, nil
+}
+
+// Recorder writes every event handled by the simulation to a recording.
+// Events are written as they come, so the recording is usable even if the game crashes.
+//
+// Simulation should stream synchronously, see SimulationConfig.SyncStreaming,
+// otherwise chunks may be loaded at different steps when replayed.
+type Recorder struct {
+	sim       *Simulation
+	startTick uint64
+	encoder   *json.Encoder
+	err       error
+}
+
+// Start recording sim which is stepped by step.
+// Modified sectors are saved and the universe is copied into snapshot,
+// a directory next to the recording which must not exist.
+// Keys pressed at the moment are recorded as pressed at the first step.
+func NewRecorder(w io.Writer, sim *Simulation, step time.Duration, snapshot string) (*Recorder, error) {
+	err := sim.saveSectors()
+	if err == nil {
+		err = sim.universe.Flush()
+	}
+	if err == nil {
+		err = CopyUniverse(sim.universe.Path(), snapshot)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("copy universe: %w", err)
+	}
+
+	x, y := sim.player.GetPosition()
+	vx, vy := sim.player.GetLinearVelocity()
+	header := RecordingHeader{
+		Version:        RECORDING_FORMAT_VERSION,
+		Name:           sim.universe.Manifest.Name,
+		Seed:           sim.universe.Manifest.Seed,
+		Universe:       filepath.Base(snapshot),
+		Step:           step,
+		PlayerPosition: Vec2{x, y},
+		PlayerVelocity: Vec2{vx, vy},
+	}
+
+	recorder := &Recorder{
+		sim:       sim,
+		startTick: sim.Tick(),
+		encoder:   json.NewEncoder(w),
+	}
+	if err := recorder.encoder.Encode(&header); err != nil {
+		return nil, err
+	}
+	for _, key := range pressedKeys() {
+		recorder.OnEvent(KeyboardEvent{Key: key, Action: ACTION_PRESS})
+	}
+	if recorder.err != nil {
+		return nil, recorder.err
+	}
+
+	RegisterEventListener(recorder)
+
+	return recorder, nil
+}
+
+func (recorder *Recorder) OnEvent(event Event) {
+	if recorder.err != nil {
+		return
+	}
+	// Events of other packages can't be read back.
+	if _, exist := recordableEvents[event.Name()]; !exist {
+		return
+	}
+
+	recorder.err = recorder.encoder.Encode(RecordedEvent{recorder.sim.Tick() - recorder.startTick, event})
+}
+
+// Stop recording. Return the first error occurred while writing, if any.
+func (recorder *Recorder) Close() error {
+	UnregisterEventListener(recorder)
+
+	return recorder.err
+}
+
+// Replayer runs a recording through the event queue of a simulation, without window.
+type Replayer struct {
+	recording *Recording
+	sim       *Simulation
+	tick      uint64
+	next      int
+}
+
+// Sim must be new, made from a copy of RecordingHeader.Universe,
+// and stream synchronously.
+// Its player is moved to where the recording started, and keys are released.
+func NewReplayer(recording *Recording, sim *Simulation) (*Replayer, error) {
+	if seed := sim.universe.Manifest.Seed; seed != recording.Seed {
+		return nil, fmt.Errorf("recording of seed %d can't be replayed in universe of seed %d", recording.Seed, seed)
+	}
+	if !sim.config.SyncStreaming {
+		return nil, errors.New("simulation must stream synchronously to replay")
+	}
+
+	clearEvents()
+	resetKeyActionStates()
+	sim.player.SetPosition(recording.PlayerPosition.X, recording.PlayerPosition.Y)
+	sim.player.SetLinearVelocity(recording.PlayerVelocity.X, recording.PlayerVelocity.Y)
+	sim.updateFocus()
+
+	replayer := &Replayer{
+		recording: recording,
+		sim:       sim,
+	}
+
+	return replayer, nil
+}
+
+func (replayer *Replayer) Simulation() *Simulation {
+	return replayer.sim
+}
+
+// Push events of the current tick, then step the simulation.
+func (replayer *Replayer) Step() {
+	events := replayer.recording.Events
+	for ; replayer.next < len(events) && events[replayer.next].Tick <= replayer.tick; replayer.next++ {
+		PushEvent(events[replayer.next].Event)
+	}
+
+	replayer.sim.Step(replayer.recording.Step)
+	replayer.tick++
+}
+
+// Whether every event is replayed.
+func (replayer *Replayer) Done() bool {
+	return replayer.next == len(replayer.recording.Events)
+}
+
+// Step until every event is replayed.
+func (replayer *Replayer) Run() {
+	for !replayer.Done() {
+		replayer.Step()
+	}
+}
@@ -0,0 +1,102 @@
+package lostinspace_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+func TestRecording(t *testing.T) {
+	const steps = 150
+	wall := lostinspace.WorldBlockCoord{X: 3, Y: 0}
+
+	var buf bytes.Buffer
+	dir := t.TempDir()
+	sim := newTestSimulation(t, filepath.Join(dir, "universe"))
+	pressKey(lostinspace.KEY_D, lostinspace.ACTION_PRESS)
+	sim.Step(testStep)
+	// Terrain changed before recording has to be in the copy of the universe.
+	marker := lostinspace.WorldBlockCoord{X: -3, Y: 2}
+	if !sim.SetBlock(marker, lostinspace.NewBlock(lostinspace.BlockCoord{}, "stone", 0)) {
+		t.Fatalf("Chunk of %v isn't loaded\n", marker)
+	}
+
+	snapshot := filepath.Join(dir, "session.universe")
+	recorder, err := lostinspace.NewRecorder(&buf, sim, testStep, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < steps; i++ {
+		switch i {
+		case 10:
+			// Click which Game would turn into the edit, only the edit is recorded.
+			lostinspace.PushEvent(lostinspace.MouseEvent{Button: lostinspace.MOUSE_BUTTON_RIGHT, Action: lostinspace.ACTION_PRESS})
+			lostinspace.PushEvent(lostinspace.EditBlockEvent{Coord: wall, BlockType: "stone"})
+		case 60:
+			pressKey(lostinspace.KEY_W, lostinspace.ACTION_PRESS)
+		case 100:
+			pressKey(lostinspace.KEY_D, lostinspace.ACTION_RELEASE)
+			pressKey(lostinspace.KEY_W, lostinspace.ACTION_RELEASE)
+		}
+		sim.Step(testStep)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	x, y := sim.Player().GetPosition()
+	sim.Close()
+
+	recording, err := lostinspace.ReadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recording.Events) != 5 || recording.Events[0].Tick != 0 || recording.Events[4].Tick != 100 {
+		t.Errorf("Recorded %v\n", recording.Events)
+	}
+	if recording.Universe != "session.universe" {
+		t.Errorf("Copy of the universe: %q\n", recording.Universe)
+	}
+
+	// The same session on a copy of the universe as it was when recording started.
+	replayPath := filepath.Join(t.TempDir(), "universe")
+	if err := lostinspace.CopyUniverse(filepath.Join(dir, recording.Universe), replayPath); err != nil {
+		t.Fatal(err)
+	}
+	replaySim := newTestSimulation(t, replayPath)
+	defer replaySim.Close()
+
+	replayer, err := lostinspace.NewReplayer(recording, replaySim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer.Step()
+	if block := replaySim.Terrain().GetBlock(marker); block == nil || block.BlockType != "stone" {
+		t.Errorf("Block placed before recording: %v\n", block)
+	}
+	if block := replaySim.Terrain().GetBlock(wall); block != nil && block.BlockType != lostinspace.BLOCK_TYPE_VOID {
+		t.Errorf("Block placed while recording is there before replay: %v\n", block)
+	}
+	replayer.Run()
+	for replaySim.Tick() < steps {
+		replayer.Step()
+	}
+
+	if replayX, replayY := replaySim.Player().GetPosition(); replayX != x || replayY != y {
+		t.Errorf("Replayed (%v, %v), expected (%v, %v)\n", replayX, replayY, x, y)
+	}
+	if block := replaySim.Terrain().GetBlock(wall); block == nil || block.BlockType != "stone" {
+		t.Errorf("Replayed block: %v\n", block)
+	}
+}
+
+func TestReplayerSeed(t *testing.T) {
+	sim := newTestSimulation(t, t.TempDir())
+	defer sim.Close()
+
+	recording := &lostinspace.Recording{RecordingHeader: lostinspace.RecordingHeader{Seed: 12, Step: testStep}}
+	if _, err := lostinspace.NewReplayer(recording, sim); err == nil {
+		t.Errorf("Replayed recording of another seed\n")
+	}
+}
@@ -0,0 +1,172 @@
+package lostinspace
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// RecordingBackend draws nothing but records every call,
+// so that tests can check what is drawn without opengl.
+type RecordingBackend struct {
+	Calls []BackendCall
+
+	lastID uint32
+}
+
+// Call to a backend, named by the method.
+// Args are the arguments except the data of buffers and images, which are recorded by their lengths and sizes.
+type BackendCall struct {
+	Name string
+	Args []interface{}
+}
+
+func NewRecordingBackend() *RecordingBackend {
+	return new(RecordingBackend)
+}
+
+func (call BackendCall) String() string {
+	return fmt.Sprintf("%s%v", call.Name, call.Args)
+}
+
+// Calls of given names, in order.
+func (backend *RecordingBackend) Filter(names ...string) []BackendCall {
+	calls := make([]BackendCall, 0)
+	for _, call := range backend.Calls {
+		for _, name := range names {
+			if call.Name == name {
+				calls = append(calls, call)
+				break
+			}
+		}
+	}
+
+	return calls
+}
+
+// Forget recorded calls.
+func (backend *RecordingBackend) Reset() {
+	backend.Calls = nil
+}
+
+func (backend *RecordingBackend) record(name string, args ...interface{}) {
+	backend.Calls = append(backend.Calls, BackendCall{name, args})
+}
+
+func (backend *RecordingBackend) newID() uint32 {
+	backend.lastID++
+	return backend.lastID
+}
+
+func (backend *RecordingBackend) CreateBuffer() BufferID {
+	buffer := BufferID(backend.newID())
+	backend.record("CreateBuffer", buffer)
+	return buffer
+}
+
+func (backend *RecordingBackend) BufferData(buffer BufferID, data interface{}) {
+	length := 0
+	switch data := data.(type) {
+	case []float32:
+		length = len(data)
+	case []uint16:
+		length = len(data)
+	case []uint32:
+		length = len(data)
+	}
+	backend.record("BufferData", buffer, length)
+}
+
+func (backend *RecordingBackend) BufferSubData(buffer BufferID, offset int, data interface{}) {
+	length := 0
+	switch data := data.(type) {
+	case []float32:
+		length = len(data)
+	case []uint16:
+		length = len(data)
+	case []uint32:
+		length = len(data)
+	}
+	backend.record("BufferSubData", buffer, offset, length)
+}
+
+func (backend *RecordingBackend) DeleteBuffer(buffer BufferID) {
+	backend.record("DeleteBuffer", buffer)
+}
+
+func (backend *RecordingBackend) CreateVertexArray() VertexArrayID {
+	vertexArray := VertexArrayID(backend.newID())
+	backend.record("CreateVertexArray", vertexArray)
+	return vertexArray
+}
+
+func (backend *RecordingBackend) VertexAttrib(vertexArray VertexArrayID, attrib uint32, buffer BufferID) {
+	backend.record("VertexAttrib", vertexArray, attrib, buffer)
+}
+
+func (backend *RecordingBackend) IndexBuffer(vertexArray VertexArrayID, buffer BufferID) {
+	backend.record("IndexBuffer", vertexArray, buffer)
+}
+
+func (backend *RecordingBackend) DeleteVertexArray(vertexArray VertexArrayID) {
+	backend.record("DeleteVertexArray", vertexArray)
+}
+
+func (backend *RecordingBackend) CreateTexture2D(img *image.RGBA) TextureID {
+	texture := TextureID(backend.newID())
+	backend.record("CreateTexture2D", texture, img.Rect.Size())
+	return texture
+}
+
+func (backend *RecordingBackend) CreateTexture2DArray(width, height int32, layers []*image.RGBA) TextureID {
+	texture := TextureID(backend.newID())
+	backend.record("CreateTexture2DArray", texture, width, height, len(layers))
+	return texture
+}
+
+func (backend *RecordingBackend) BindTexture(unit uint32, texture TextureID) {
+	backend.record("BindTexture", unit, texture)
+}
+
+func (backend *RecordingBackend) DeleteTexture(texture TextureID) {
+	backend.record("DeleteTexture", texture)
+}
+
+func (backend *RecordingBackend) CreateProgram(vertexSource, fragmentSource string) (ProgramID, error) {
+	program := ProgramID(backend.newID())
+	backend.record("CreateProgram", program)
+	return program, nil
+}
+
+func (backend *RecordingBackend) UseProgram(program ProgramID) {
+	backend.record("UseProgram", program)
+}
+
+func (backend *RecordingBackend) UniformInt(program ProgramID, name string, value int32) {
+	backend.record("UniformInt", program, name, value)
+}
+
+func (backend *RecordingBackend) UniformMat4(program ProgramID, name string, value mgl32.Mat4) {
+	backend.record("UniformMat4", program, name, value)
+}
+
+func (backend *RecordingBackend) DeleteProgram(program ProgramID) {
+	backend.record("DeleteProgram", program)
+}
+
+func (backend *RecordingBackend) Resize(width, height int) {
+	backend.record("Resize", width, height)
+}
+
+func (backend *RecordingBackend) BeginFrame() {
+	backend.record("BeginFrame")
+}
+
+func (backend *RecordingBackend) DrawElements(vertexArray VertexArrayID, count int32) {
+	backend.record("DrawElements", vertexArray, count)
+}
+
+func (backend *RecordingBackend) EndFrame() {
+	backend.record("EndFrame")
+}
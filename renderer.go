@@ -1,42 +1,154 @@
 package lostinspace
 
-type RendererTest interface { // TODO
-	Bind()
-	Render()
-}
+import (
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
 
+// Renderer draws a frame from a draw list.
+// Commands are added during a frame and drawn by Render,
+// sorted so that shaders and textures are bound as few times as possible.
+// Uniforms are uploaded only when they differ from what the shader already has.
+//
+// Uniforms set by commands must not be set on the shaders by other means.
 type Renderer struct {
-	shaderProgram    *ShaderProgram
-	meshesPerTexture map[Texture][]*Mesh
+	backend  Backend
+	commands []DrawCommand
+
+	stats FrameStats
+
+	// Uniform values last uploaded to each program.
+	ints map[ProgramID]map[string]int32
+	mats map[ProgramID]map[string]mgl32.Mat4
 }
 
-func NewRenderer(vertexShaderRaw, fragmentShaderRaw string) *Renderer {
-	renderer := new(Renderer)
-	renderer.shaderProgram = NewShaderProgram(vertexShaderRaw, fragmentShaderRaw)
-	renderer.meshesPerTexture = make(map[Texture][]*Mesh)
+// Draw a mesh by a shader with a texture.
+type DrawCommand struct {
+	// Commands are drawn in order of layers, then grouped by shader and texture.
+	// Commands which are equal in them are drawn in the order they are added.
+	Layer       int
+	Shader      *ShaderProgram
+	Texture     Texture
+	TextureUnit uint32
+	Mesh        *Mesh
+
+	Ints map[string]int32
+	Mats map[string]mgl32.Mat4
+}
+
+// What the last frame took.
+type FrameStats struct {
+	DrawCalls int
+	// Vertices processed, counted by indices.
+	Vertices       int
+	ShaderBinds    int
+	TextureBinds   int
+	UniformUploads int
+}
+
+func NewRenderer(backend Backend) *Renderer {
+	renderer := &Renderer{
+		backend: backend,
+		ints:    make(map[ProgramID]map[string]int32),
+		mats:    make(map[ProgramID]map[string]mgl32.Mat4),
+	}
 
 	return renderer
 }
 
-func (renderer *Renderer) Bind() {
-	renderer.shaderProgram.Bind()
+func (renderer *Renderer) Add(command DrawCommand) {
+	renderer.commands = append(renderer.commands, command)
 }
 
-func (renderer *Renderer) Add(texture Texture, mesh *Mesh) { // TODO
-	meshes, exist := renderer.meshesPerTexture[texture]
-	if !exist {
-		meshes = make([]*Mesh, 0, 10)
+// Draw commands added since the last call as a frame.
+func (renderer *Renderer) Render() {
+	commands := renderer.commands
+	sort.SliceStable(commands, func(i, j int) bool {
+		a, b := commands[i], commands[j]
+		if a.Layer != b.Layer {
+			return a.Layer < b.Layer
+		}
+		if programA, programB := a.Shader.program, b.Shader.program; programA != programB {
+			return programA < programB
+		}
+		return textureID(a.Texture) < textureID(b.Texture)
+	})
+
+	renderer.stats = FrameStats{}
+	renderer.backend.BeginFrame()
+
+	var shader *ShaderProgram
+	bound := make(map[uint32]TextureID)
+	for _, command := range commands {
+		if command.Shader != shader {
+			shader = command.Shader
+			shader.Bind()
+			renderer.stats.ShaderBinds++
+		}
+		if id := textureID(command.Texture); id != 0 && bound[command.TextureUnit] != id {
+			command.Texture.Bind(command.TextureUnit)
+			bound[command.TextureUnit] = id
+			renderer.stats.TextureBinds++
+		}
+		renderer.upload(shader, command.Ints, command.Mats)
+
+		command.Mesh.Draw()
+		renderer.stats.DrawCalls++
+		renderer.stats.Vertices += int(command.Mesh.elementsCount)
+	}
+
+	renderer.backend.EndFrame()
+
+	for i := range commands {
+		commands[i] = DrawCommand{}
 	}
+	renderer.commands = commands[:0]
+}
+
+func (renderer *Renderer) Stats() FrameStats {
+	return renderer.stats
+}
 
-	renderer.meshesPerTexture[texture] = append(meshes, mesh)
+// Forget uniforms uploaded to the shader, like when it's destroyed.
+func (renderer *Renderer) Forget(shader *ShaderProgram) {
+	delete(renderer.ints, shader.program)
+	delete(renderer.mats, shader.program)
 }
 
-func (renderer *Renderer) Render() { // TODO
-	for texture, meshes := range renderer.meshesPerTexture {
-		texture.Bind(0)
+func (renderer *Renderer) upload(shader *ShaderProgram, ints map[string]int32, mats map[string]mgl32.Mat4) {
+	uploadedInts, exist := renderer.ints[shader.program]
+	if !exist {
+		uploadedInts = make(map[string]int32)
+		renderer.ints[shader.program] = uploadedInts
+	}
+	uploadedMats, exist := renderer.mats[shader.program]
+	if !exist {
+		uploadedMats = make(map[string]mgl32.Mat4)
+		renderer.mats[shader.program] = uploadedMats
+	}
 
-		for _, mesh := range meshes {
-			mesh.Draw()
+	for name, value := range ints {
+		if uploaded, exist := uploadedInts[name]; exist && uploaded == value {
+			continue
+		}
+		shader.UniformInt(name, value)
+		uploadedInts[name] = value
+		renderer.stats.UniformUploads++
+	}
+	for name, value := range mats {
+		if uploaded, exist := uploadedMats[name]; exist && uploaded == value {
+			continue
 		}
+		shader.UniformMat4(name, value)
+		uploadedMats[name] = value
+		renderer.stats.UniformUploads++
+	}
+}
+
+func textureID(texture Texture) TextureID {
+	if texture == nil {
+		return 0
 	}
+	return texture.ID()
 }
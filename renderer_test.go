@@ -0,0 +1,59 @@
+package lostinspace_test
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/rlj1202/LostInSpace"
+)
+
+func TestRenderer(t *testing.T) {
+	backend := lostinspace.NewRecordingBackend()
+	renderer := lostinspace.NewRenderer(backend)
+	shader := lostinspace.NewShaderProgram(backend, "", "")
+
+	meshes := make([]*lostinspace.Mesh, 4)
+	for i := range meshes {
+		meshes[i] = lostinspace.NewMesh([]float32{0, 0, 0, 1, 0, 0, 0, 1, 0}, nil, nil, []uint32{0, 1, 2})
+		meshes[i].Bake(backend)
+	}
+	vertexArrays := make(map[interface{}]int)
+	for i, call := range backend.Filter("CreateVertexArray") {
+		vertexArrays[call.Args[0]] = i
+	}
+
+	translate := map[string]mgl32.Mat4{"translate": mgl32.Translate3D(1, 2, 0)}
+	renderer.Add(lostinspace.DrawCommand{Layer: 1, Shader: shader, Mesh: meshes[0], Mats: translate})
+	renderer.Add(lostinspace.DrawCommand{Layer: 0, Shader: shader, Mesh: meshes[1], Ints: map[string]int32{"texMode": 1}})
+	renderer.Add(lostinspace.DrawCommand{Layer: 1, Shader: shader, Mesh: meshes[2], Mats: translate})
+	renderer.Add(lostinspace.DrawCommand{Layer: 0, Shader: shader, Mesh: meshes[3], Ints: map[string]int32{"texMode": 1}})
+
+	backend.Reset()
+	renderer.Render()
+
+	// Lower layer first, in the order they are added in a layer.
+	draws := backend.Filter("DrawElements")
+	order := make([]int, len(draws))
+	for i, draw := range draws {
+		order[i] = vertexArrays[draw.Args[0]]
+	}
+	if len(order) != 4 || order[0] != 1 || order[1] != 3 || order[2] != 0 || order[3] != 2 {
+		t.Errorf("Drawn in order %v\n", order)
+	}
+
+	// Same uniforms are uploaded once.
+	stats := renderer.Stats()
+	if stats.DrawCalls != 4 || stats.Vertices != 12 || stats.ShaderBinds != 1 || stats.UniformUploads != 2 {
+		t.Errorf("Stats: %+v\n", stats)
+	}
+	if uploads := backend.Filter("UniformInt", "UniformMat4"); len(uploads) != 2 {
+		t.Errorf("Uploaded %v\n", uploads)
+	}
+
+	// Draw list is emptied by a frame, uploaded uniforms are remembered.
+	renderer.Add(lostinspace.DrawCommand{Shader: shader, Mesh: meshes[0], Mats: translate})
+	renderer.Render()
+	if stats := renderer.Stats(); stats.DrawCalls != 1 || stats.UniformUploads != 0 {
+		t.Errorf("Stats of the second frame: %+v\n", stats)
+	}
+}
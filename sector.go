@@ -1,9 +1,16 @@
 package lostinspace
 
+import "sync"
+
 // A sector is a set of chunks.
 // One sector will be saved into one file.
+// It's safe to use a sector from multiple goroutines.
 type Sector struct {
-	Chunks [SECTOR_WIDTH * SECTOR_HEIGHT]*Chunk
+	mu     sync.RWMutex
+	chunks [SECTOR_WIDTH * SECTOR_HEIGHT]*Chunk
+	// Held while the sector is encoded and queued to be written,
+	// so that an older encoding can't be queued after a newer one.
+	saveMu sync.Mutex
 
 	coord WorldSectorCoord
 }
@@ -16,24 +23,78 @@ func NewSector(coord WorldSectorCoord) *Sector {
 	return sector
 }
 
+func (sector *Sector) Coord() WorldSectorCoord {
+	return sector.coord
+}
+
 func (sector *Sector) Set(chunk *Chunk) {
 	if !chunk.coord.Valid() {
 		return
 	}
-	sector.Chunks[chunk.coord.X+chunk.coord.Y*SECTOR_WIDTH] = chunk
+
+	chunk.mu.Lock()
+	chunk.sectorCoord = sector.coord
+	chunk.mu.Unlock()
+
+	sector.mu.Lock()
+	sector.chunks[chunk.coord.X+chunk.coord.Y*SECTOR_WIDTH] = chunk
+	sector.mu.Unlock()
 }
 
 func (sector *Sector) At(coord ChunkCoord) *Chunk {
 	if !coord.Valid() {
 		return nil
 	}
-	return sector.Chunks[coord.X+coord.Y*SECTOR_WIDTH]
+
+	sector.mu.RLock()
+	defer sector.mu.RUnlock()
+
+	return sector.chunks[coord.X+coord.Y*SECTOR_WIDTH]
 }
 
-// Deallocate all chunks.
-// vao, vbo, ebo and b2body.
+// Call f for every chunk the sector has, in row major order.
+// f may change the sector.
+func (sector *Sector) ForEach(f func(*Chunk)) {
+	for _, chunk := range sector.chunkList() {
+		if chunk != nil {
+			f(chunk)
+		}
+	}
+}
+
+// Copy of the chunk array.
+func (sector *Sector) chunkList() [SECTOR_WIDTH * SECTOR_HEIGHT]*Chunk {
+	sector.mu.RLock()
+	defer sector.mu.RUnlock()
+
+	return sector.chunks
+}
+
+// Whether any chunk was changed since the sector was generated, loaded or saved.
+// Unmodified sectors don't need to be saved,
+// they are either in the file already or generated again from the seed.
+func (sector *Sector) Modified() bool {
+	for _, chunk := range sector.chunkList() {
+		if chunk != nil && chunk.Modified() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mark chunks as saved up to the revisions returned by encodeSector.
+func (sector *Sector) markSaved(revisions *[SECTOR_WIDTH * SECTOR_HEIGHT]uint64) {
+	for i, chunk := range sector.chunkList() {
+		if chunk != nil {
+			chunk.markSaved(revisions[i])
+		}
+	}
+}
+
+// Remove b2body of all chunks from the world.
 func (sector *Sector) Destroy() {
-	for _, chunk := range sector.Chunks {
+	sector.ForEach(func(chunk *Chunk) {
 		chunk.Destroy()
-	}
+	})
 }
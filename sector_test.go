@@ -1,7 +1,13 @@
 package lostinspace_test
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/rlj1202/LostInSpace"
@@ -9,6 +15,7 @@ import (
 
 func TestSector(t *testing.T) {
 	sector := lostinspace.NewSector(lostinspace.WorldSectorCoord{0, 0})
+	mapping := lostinspace.NewBlockMapping(nil)
 
 	for chunkY := uint8(0); chunkY < 16; chunkY++ {
 		for chunkX := uint8(0); chunkX < 16; chunkX++ {
@@ -16,23 +23,192 @@ func TestSector(t *testing.T) {
 
 			for blockY := uint8(0); blockY < 16; blockY++ {
 				for blockX := uint8(0); blockX < 16; blockX++ {
-					chunk.Set(lostinspace.NewBlock(
+					block := lostinspace.NewBlock(
 						lostinspace.BlockCoord{blockX, blockY},
 						lostinspace.BlockType(fmt.Sprintf("stone_%d_%d", blockX, blockY)),
-					))
+						int(blockX%4),
+					)
+					block.State = lostinspace.BlockState(blockY) << 20
+					chunk.Set(block)
 				}
 			}
 			sector.Set(chunk)
 		}
 	}
 
-	lostinspace.SaveSector(sector)
-	t.Logf("Saved file\n")
+	var buf bytes.Buffer
+	if err := lostinspace.EncodeSector(&buf, sector, 42, mapping); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Encoded sector: %d bytes\n", buf.Len())
+
+	newSector, header, err := lostinspace.DecodeSector(&buf, lostinspace.WorldSectorCoord{0, 0}, mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != lostinspace.SECTOR_FILE_VERSION || header.Seed != 42 {
+		t.Errorf("Header: %+v\n", header)
+	}
+
+	sector.ForEach(func(chunk *lostinspace.Chunk) {
+		newChunk := newSector.At(chunk.Coord())
+		chunk.ForEach(func(block *lostinspace.Block) {
+			newBlock := newChunk.At(block.Coord())
+			if *block != *newBlock {
+				t.Fatalf("%v %v: %v != %v\n", chunk.Coord(), block.Coord(), block, newBlock)
+			}
+		})
+	})
+}
+
+type legacySector struct {
+	Chunks [256]*legacyChunk
+}
+
+type legacyChunk struct {
+	Blocks [256]*legacyBlock
+}
+
+type legacyBlock struct {
+	BlockType string
+	FrontFace int
+}
+
+func TestLegacySector(t *testing.T) {
+	legacy := new(legacySector)
+	for i := range legacy.Chunks {
+		legacy.Chunks[i] = new(legacyChunk)
+		for j := range legacy.Chunks[i].Blocks {
+			legacy.Chunks[i].Blocks[j] = &legacyBlock{}
+		}
+	}
+	legacy.Chunks[17].Blocks[3] = &legacyBlock{BlockType: "door0", FrontFace: 1}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	sector, header, err := lostinspace.DecodeSector(&buf, lostinspace.WorldSectorCoord{3, -2}, lostinspace.NewBlockMapping(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Migrated header: %+v\n", header)
+
+	block := sector.At(lostinspace.ChunkCoord{1, 1}).At(lostinspace.BlockCoord{3, 0})
+	if block.BlockType != "door0" || block.FrontFace != 1 {
+		t.Errorf("Migrated block: %v\n", block)
+	}
+	block = sector.At(lostinspace.ChunkCoord{0, 0}).At(lostinspace.BlockCoord{3, 0})
+	if block.BlockType != lostinspace.BLOCK_TYPE_VOID {
+		t.Errorf("Migrated block: %v\n", block)
+	}
+}
 
-	newSector := lostinspace.LoadSector(lostinspace.WorldSectorCoord{0, 0})
-	t.Logf("Loaded file\n")
+func TestInvalidSector(t *testing.T) {
+	sector := lostinspace.NewSector(lostinspace.WorldSectorCoord{0, 0})
+	mapping := lostinspace.NewBlockMapping(nil)
+	chunk := lostinspace.NewChunk(lostinspace.ChunkCoord{0, 0})
+	chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{0, 0}, "stone", 0))
+	sector.Set(chunk)
 
-	for i, block := range newSector.Chunks[0].Blocks {
-		t.Logf("[%3d]: %v\n", i, block.BlockType)
+	var buf bytes.Buffer
+	if err := lostinspace.EncodeSector(&buf, sector, 0, mapping); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	inputs := map[string][]byte{
+		"empty":     {},
+		"garbage":   []byte("definitely not a sector"),
+		"truncated": encoded[:len(encoded)/2],
+	}
+	for name, input := range inputs {
+		_, _, err := lostinspace.DecodeSector(bytes.NewReader(input), lostinspace.WorldSectorCoord{0, 0}, mapping)
+		if err == nil {
+			t.Errorf("%s: expected error\n", name)
+			continue
+		}
+		if !errors.Is(err, lostinspace.ErrInvalidSectorFile) && !errors.Is(err, lostinspace.ErrUnsupportedSectorVersion) {
+			t.Errorf("%s: unexpected error: %v\n", name, err)
+		}
+		t.Logf("%s: %v\n", name, err)
+	}
+
+	// Whole file of a version this build doesn't know yet.
+	future := append([]byte(nil), encoded...)
+	binary.BigEndian.PutUint16(future[len(lostinspace.SECTOR_FILE_MAGIC):], lostinspace.SECTOR_FILE_VERSION+1)
+	_, _, err := lostinspace.DecodeSector(bytes.NewReader(future), lostinspace.WorldSectorCoord{0, 0}, mapping)
+	if !errors.Is(err, lostinspace.ErrUnsupportedSectorVersion) {
+		t.Errorf("Future version: %v\n", err)
+	}
+
+	// Ids which aren't in the mapping.
+	_, _, err = lostinspace.DecodeSector(bytes.NewReader(encoded), lostinspace.WorldSectorCoord{0, 0}, lostinspace.NewBlockMapping(nil))
+	if !errors.Is(err, lostinspace.ErrInvalidSectorFile) {
+		t.Errorf("Decoded with another mapping: %v\n", err)
+	}
+}
+
+func TestSectorModified(t *testing.T) {
+	path := t.TempDir()
+	universe, err := lostinspace.CreateUniverse(path, "test", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer universe.Close()
+
+	coord := lostinspace.WorldSectorCoord{1, 1}
+	sector := lostinspace.GenerateSector(universe.Seed(), coord)
+	if sector.Modified() {
+		t.Errorf("Generated sector is modified\n")
+	}
+
+	chunk := sector.At(lostinspace.ChunkCoord{5, 5})
+	block := chunk.At(lostinspace.BlockCoord{1, 1})
+	chunk.Set(block)
+	if sector.Modified() {
+		t.Errorf("Setting the same block modified sector\n")
+	}
+
+	block.BlockType = "door0"
+	chunk.Set(block)
+	if !chunk.Modified() || !sector.Modified() {
+		t.Errorf("Sector is not modified\n")
+	}
+
+	if err := universe.SaveSector(sector); err != nil {
+		t.Fatal(err)
+	}
+	if err := universe.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if sector.Modified() {
+		t.Errorf("Saved sector is modified\n")
+	}
+
+	loaded, err := universe.LoadSector(coord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Modified() {
+		t.Errorf("Loaded sector is modified\n")
+	}
+
+	// Sector which fails to be written stays modified.
+	// A file where its region directory should be makes writing fail.
+	failing := lostinspace.GenerateSector(universe.Seed(), lostinspace.WorldSectorCoord{-1, -1})
+	failing.At(lostinspace.ChunkCoord{0, 0}).Set(lostinspace.NewBlock(lostinspace.BlockCoord{1, 1}, "door0", 0))
+	if err := os.WriteFile(filepath.Join(path, "regions", "region_-1_-1"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := universe.SaveSector(failing); err != nil {
+		t.Fatal(err)
+	}
+	if err := universe.Flush(); err == nil {
+		t.Errorf("Writing into a file succeeded\n")
+	}
+	if !failing.Modified() {
+		t.Errorf("Sector which failed to be written isn't modified\n")
 	}
 }
@@ -0,0 +1,474 @@
+package lostinspace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Sector file layout.
+// Fixed size integers are big endian, uvarint and varint are
+// the variable length integers of encoding/binary.
+//
+//	header
+//	    magic          [4]byte          "LISS"
+//	    version        uint16
+//	    seed           int64
+//	    paletteLen     uvarint
+//	    palette        paletteLen * uvarint   # block ids of the universe, see BlockMapping
+//	chunks             SECTOR_WIDTH * SECTOR_HEIGHT times, row by row
+//	    payloadLen     uvarint          # 0 means the chunk is absent
+//	    payload        payloadLen bytes # layout depends on version
+//	tileEntityCount    uvarint
+//	tileEntities       tileEntityCount times, see TileEntity
+//	    chunk          uvarint          # index of the chunk, row by row
+//	    block          uvarint          # index of the block in the chunk, row by row
+//	    kind           uvarint length, bytes
+//	    data           uvarint length, bytes
+//
+// Versions before 5 have no tile entities.
+// Versions before 3 store block types in the palette by name,
+// as (uvarint length, bytes).
+//
+// Chunk payload of version 4
+//
+//	chunkPaletteLen    uvarint
+//	chunkPalette       chunkPaletteLen times
+//	    paletteIndex   uvarint          # index into the block type palette
+//	    frontFace      varint
+//	    state          uvarint          # BlockState
+//	bits               uint8            # bits per block, 0, 1, 2, 4 or 8
+//	indices            uint64 words     # chunk palette index of each block, packed
+//
+// Payload of version 2 and 3 is the same but without state.
+//
+// Files of older versions are upgraded by migrations on load.
+// See sectormigration.go.
+const (
+	SECTOR_FILE_MAGIC   = "LISS"
+	SECTOR_FILE_VERSION = 5
+)
+
+var (
+	ErrInvalidSectorFile        = errors.New("invalid sector file")
+	ErrUnsupportedSectorVersion = errors.New("unsupported sector file version")
+)
+
+// SectorHeader is stored in front of every sector file.
+type SectorHeader struct {
+	Version uint16
+	// Seed of the terrain which the sector was generated from.
+	Seed int64
+	// Every block type used in the sector.
+	// Blocks refer to it by index.
+	// It's kept by name whatever the version is.
+	Palette []BlockType
+}
+
+// sectorData is a sector file which is read but not decoded yet.
+// Chunk payloads are in the layout of Version
+// so migrations can rewrite them before decoding.
+type sectorData struct {
+	SectorHeader
+
+	chunks       [SECTOR_WIDTH * SECTOR_HEIGHT][]byte
+	tileEntities []sectorTileEntity
+
+	// Whole file for version 0, which has no header at all.
+	raw []byte
+}
+
+// Tile entity as it's in a sector file.
+type sectorTileEntity struct {
+	chunk int
+	block BlockCoord
+	kind  string
+	data  []byte
+}
+
+// Write sector in the current sector file format.
+// Block types get ids from mapping, which has to be saved along with the sector.
+func EncodeSector(w io.Writer, sector *Sector, seed int64, mapping *BlockMapping) error {
+	_, err := encodeSector(w, sector, seed, mapping)
+	return err
+}
+
+// Same as EncodeSector but also return the revision of each chunk which is written.
+func encodeSector(w io.Writer, sector *Sector, seed int64, mapping *BlockMapping) (*[SECTOR_WIDTH * SECTOR_HEIGHT]uint64, error) {
+	data := &sectorData{
+		SectorHeader: SectorHeader{
+			Version: SECTOR_FILE_VERSION,
+			Seed:    seed,
+		},
+	}
+
+	revisions := new([SECTOR_WIDTH * SECTOR_HEIGHT]uint64)
+	paletteIndices := make(map[BlockType]int)
+	for i, chunk := range sector.chunkList() {
+		if chunk == nil {
+			continue
+		}
+		data.chunks[i], revisions[i] = encodeChunk(chunk, SECTOR_FILE_VERSION, &data.Palette, paletteIndices)
+
+		// Tile entities may use the chunk while they marshal, so it isn't locked then.
+		chunk.mu.RLock()
+		entries := chunk.sortedTileEntities()
+		chunk.mu.RUnlock()
+		for _, entry := range entries {
+			raw, err := entry.tile.MarshalBinary()
+			if err != nil {
+				return nil, fmt.Errorf("tile entity %q at %v %v: %w", entry.kind, chunk.coord, entry.coord, err)
+			}
+			data.tileEntities = append(data.tileEntities, sectorTileEntity{i, entry.coord, entry.kind, raw})
+		}
+	}
+
+	return revisions, writeSectorData(w, data, mapping)
+}
+
+// Read sector file, upgrading it if it was written by older version.
+// Mapping has to be the one the sector was written with.
+func DecodeSector(r io.Reader, coord WorldSectorCoord, mapping *BlockMapping) (*Sector, *SectorHeader, error) {
+	data, err := readSectorData(r, mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := migrateSectorData(data); err != nil {
+		return nil, nil, err
+	}
+
+	sector := NewSector(coord)
+	for i, payload := range data.chunks {
+		if len(payload) == 0 {
+			continue
+		}
+
+		chunkCoord := ChunkCoord{uint8(i % SECTOR_WIDTH), uint8(i / SECTOR_WIDTH)}
+		chunk, err := decodeChunk(chunkCoord, payload, SECTOR_FILE_VERSION, data.Palette)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v: %w", chunkCoord, err)
+		}
+		sector.Set(chunk)
+	}
+
+	chunks := sector.chunkList()
+	for _, entity := range data.tileEntities {
+		chunk := chunks[entity.chunk]
+		if chunk == nil {
+			return nil, nil, fmt.Errorf("%w: tile entity in absent chunk %d", ErrInvalidSectorFile, entity.chunk)
+		}
+
+		tile := newTileEntity(entity.kind)
+		if tile == nil {
+			tile = new(unknownTileEntity)
+		}
+		if err := tile.UnmarshalBinary(entity.data); err != nil {
+			return nil, nil, fmt.Errorf("%w: tile entity %q at %v %v: %v", ErrInvalidSectorFile, entity.kind, chunk.coord, entity.block, err)
+		}
+		chunk.setTileEntity(entity.block, tileEntry{entity.kind, tile})
+	}
+
+	return sector, &data.SectorHeader, nil
+}
+
+// Encode chunk into payload of version, from 2.
+// Block types which are not in the palette yet are appended to it.
+// Return the payload and the data revision of the chunk it contains.
+func encodeChunk(chunk *Chunk, version uint16, palette *[]BlockType, paletteIndices map[BlockType]int) ([]byte, uint64) {
+	chunk.mu.RLock()
+	defer chunk.mu.RUnlock()
+
+	payload := make([]byte, 0, 16+len(chunk.indices.words)*8)
+
+	payload = binary.AppendUvarint(payload, uint64(len(chunk.palette)))
+	for _, state := range chunk.palette {
+		index, exist := paletteIndices[state.BlockType]
+		if !exist {
+			index = len(*palette)
+			paletteIndices[state.BlockType] = index
+			*palette = append(*palette, state.BlockType)
+		}
+
+		payload = binary.AppendUvarint(payload, uint64(index))
+		payload = binary.AppendVarint(payload, int64(state.FrontFace))
+		if version >= 4 {
+			payload = binary.AppendUvarint(payload, uint64(state.State))
+		}
+	}
+
+	payload = append(payload, chunk.indices.bits)
+	for _, word := range chunk.indices.words {
+		payload = binary.BigEndian.AppendUint64(payload, word)
+	}
+
+	return payload, chunk.dataRevision
+}
+
+// Decode payload of version, from 2.
+func decodeChunk(coord ChunkCoord, payload []byte, version uint16, palette []BlockType) (*Chunk, error) {
+	errTruncated := fmt.Errorf("%w: truncated chunk payload", ErrInvalidSectorFile)
+
+	paletteLen, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return nil, errTruncated
+	}
+	payload = payload[n:]
+	if paletteLen == 0 || paletteLen > CHUNK_WIDTH*CHUNK_HEIGHT {
+		return nil, fmt.Errorf("%w: chunk palette of %d entries", ErrInvalidSectorFile, paletteLen)
+	}
+
+	chunk := NewChunk(coord)
+	chunk.palette = make([]blockState, paletteLen)
+	chunk.refs = make([]int, paletteLen)
+	for i := range chunk.palette {
+		index, n := binary.Uvarint(payload)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		payload = payload[n:]
+
+		face, n := binary.Varint(payload)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		payload = payload[n:]
+
+		state := uint64(0)
+		if version >= 4 {
+			state, n = binary.Uvarint(payload)
+			if n <= 0 {
+				return nil, errTruncated
+			}
+			payload = payload[n:]
+			if state > math.MaxUint32 {
+				return nil, fmt.Errorf("%w: block state %d out of range", ErrInvalidSectorFile, state)
+			}
+		}
+
+		if index >= uint64(len(palette)) {
+			return nil, fmt.Errorf("%w: palette index %d out of range", ErrInvalidSectorFile, index)
+		}
+		chunk.palette[i] = blockState{palette[index], int(face), BlockState(state)}
+	}
+
+	if len(payload) == 0 {
+		return nil, errTruncated
+	}
+	bits := payload[0]
+	payload = payload[1:]
+	switch bits {
+	case 0, 1, 2, 4, 8:
+	default:
+		return nil, fmt.Errorf("%w: %d bits per block", ErrInvalidSectorFile, bits)
+	}
+
+	words := wordsFor(chunk.indices.Len(), bits)
+	if len(payload) != words*8 {
+		return nil, fmt.Errorf("%w: %d bytes of indices for %d bits per block", ErrInvalidSectorFile, len(payload), bits)
+	}
+	chunk.indices.bits = bits
+	chunk.indices.words = make([]uint64, words)
+	for i := range chunk.indices.words {
+		chunk.indices.words[i] = binary.BigEndian.Uint64(payload[i*8:])
+	}
+
+	for i := 0; i < chunk.indices.Len(); i++ {
+		index := chunk.indices.Get(i)
+		if index >= len(chunk.palette) {
+			return nil, fmt.Errorf("%w: chunk palette index %d out of range", ErrInvalidSectorFile, index)
+		}
+		chunk.refs[index]++
+	}
+
+	return chunk, nil
+}
+
+// Data has to be of the current version.
+func writeSectorData(w io.Writer, data *sectorData, mapping *BlockMapping) error {
+	bw := bufio.NewWriter(w)
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, SECTOR_FILE_MAGIC...)
+	buf = binary.BigEndian.AppendUint16(buf, data.Version)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(data.Seed))
+	buf = binary.AppendUvarint(buf, uint64(len(data.Palette)))
+	for _, blockType := range data.Palette {
+		buf = binary.AppendUvarint(buf, uint64(mapping.ID(blockType)))
+	}
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+
+	for _, payload := range data.chunks {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(payload)))
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+		if _, err := bw.Write(payload); err != nil {
+			return err
+		}
+	}
+
+	buf = binary.AppendUvarint(buf[:0], uint64(len(data.tileEntities)))
+	for _, entity := range data.tileEntities {
+		buf = binary.AppendUvarint(buf, uint64(entity.chunk))
+		buf = binary.AppendUvarint(buf, uint64(blockIndex(entity.block)))
+		buf = binary.AppendUvarint(buf, uint64(len(entity.kind)))
+		buf = append(buf, entity.kind...)
+		buf = binary.AppendUvarint(buf, uint64(len(entity.data)))
+		buf = append(buf, entity.data...)
+	}
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func readSectorData(r io.Reader, mapping *BlockMapping) (*sectorData, error) {
+	br := bufio.NewReader(r)
+	data := new(sectorData)
+
+	magic, err := br.Peek(len(SECTOR_FILE_MAGIC))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if string(magic) != SECTOR_FILE_MAGIC {
+		// Files without header were written before the sector file format existed.
+		data.raw, err = io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	br.Discard(len(SECTOR_FILE_MAGIC))
+
+	var fixed struct {
+		Version uint16
+		Seed    int64
+	}
+	if err := binary.Read(br, binary.BigEndian, &fixed); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidSectorFile, err)
+	}
+	data.Version = fixed.Version
+	data.Seed = fixed.Seed
+
+	if data.Version > SECTOR_FILE_VERSION {
+		return nil, fmt.Errorf("%w: %d is newer than %d", ErrUnsupportedSectorVersion, data.Version, SECTOR_FILE_VERSION)
+	}
+
+	paletteLen, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: palette: %v", ErrInvalidSectorFile, err)
+	}
+	for i := uint64(0); i < paletteLen; i++ {
+		blockType, err := readSectorBlockType(br, data.Version, mapping)
+		if err != nil {
+			return nil, fmt.Errorf("%w: palette: %v", ErrInvalidSectorFile, err)
+		}
+		data.Palette = append(data.Palette, blockType)
+	}
+
+	for i := range data.chunks {
+		payload, err := readSectorBytes(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: chunk %d: %v", ErrInvalidSectorFile, i, err)
+		}
+		data.chunks[i] = payload
+	}
+
+	if data.Version >= 5 {
+		if err := readSectorTileEntities(br, data); err != nil {
+			return nil, fmt.Errorf("%w: tile entities: %v", ErrInvalidSectorFile, err)
+		}
+	}
+
+	return data, nil
+}
+
+func readSectorTileEntities(br *bufio.Reader, data *sectorData) error {
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+	// Every block can have one at most.
+	if count > SECTOR_WIDTH*SECTOR_HEIGHT*CHUNK_WIDTH*CHUNK_HEIGHT {
+		return fmt.Errorf("%d tile entities", count)
+	}
+
+	for i := uint64(0); i < count; i++ {
+		chunk, err := binary.ReadUvarint(br)
+		if err != nil {
+			return err
+		}
+		block, err := binary.ReadUvarint(br)
+		if err != nil {
+			return err
+		}
+		if chunk >= SECTOR_WIDTH*SECTOR_HEIGHT || block >= CHUNK_WIDTH*CHUNK_HEIGHT {
+			return fmt.Errorf("tile entity at chunk %d block %d", chunk, block)
+		}
+		kind, err := readSectorBytes(br)
+		if err != nil {
+			return err
+		}
+		raw, err := readSectorBytes(br)
+		if err != nil {
+			return err
+		}
+
+		data.tileEntities = append(data.tileEntities, sectorTileEntity{
+			chunk: int(chunk),
+			block: BlockCoord{uint8(block % CHUNK_WIDTH), uint8(block / CHUNK_WIDTH)},
+			kind:  string(kind),
+			data:  raw,
+		})
+	}
+
+	return nil
+}
+
+// Read a palette entry, which is a name before version 3.
+func readSectorBlockType(br *bufio.Reader, version uint16, mapping *BlockMapping) (BlockType, error) {
+	if version < 3 {
+		name, err := readSectorBytes(br)
+		return BlockType(name), err
+	}
+
+	id, err := binary.ReadUvarint(br)
+	if err != nil {
+		return BLOCK_TYPE_VOID, err
+	}
+	if id > math.MaxUint16 {
+		return BLOCK_TYPE_VOID, fmt.Errorf("block id %d out of range", id)
+	}
+	blockType, exist := mapping.Type(BlockID(id))
+	if !exist {
+		return BLOCK_TYPE_VOID, fmt.Errorf("block id %d isn't in the block mapping", id)
+	}
+
+	return blockType, nil
+}
+
+// Read length prefixed bytes.
+func readSectorBytes(br *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	// Guard against allocating huge buffers for corrupted lengths.
+	if length > 1<<24 {
+		return nil, fmt.Errorf("length %d is too long", length)
+	}
+
+	bytes := make([]byte, length)
+	if _, err := io.ReadFull(br, bytes); err != nil {
+		return nil, err
+	}
+
+	return bytes, nil
+}
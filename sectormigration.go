@@ -0,0 +1,189 @@
+package lostinspace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+)
+
+// A sector migration upgrades sector data of one version to the next version.
+type sectorMigration func(data *sectorData) error
+
+// Migrations keyed by the version they upgrade from.
+var sectorMigrations = make(map[uint16]sectorMigration)
+
+func init() {
+	registerSectorMigration(0, migrateLegacySector)
+	registerSectorMigration(1, migrateBlockListSector)
+	registerSectorMigration(2, migrateNamedPaletteSector)
+	registerSectorMigration(3, migrateStatelessSector)
+	registerSectorMigration(4, migrateTilelessSector)
+}
+
+func registerSectorMigration(from uint16, migration sectorMigration) {
+	if _, exist := sectorMigrations[from]; exist {
+		panic(fmt.Sprintf("sector migration from version %d is already registered", from))
+	}
+	sectorMigrations[from] = migration
+}
+
+// Upgrade data to SECTOR_FILE_VERSION.
+func migrateSectorData(data *sectorData) error {
+	for data.Version < SECTOR_FILE_VERSION {
+		migration, exist := sectorMigrations[data.Version]
+		if !exist {
+			return fmt.Errorf("%w: no migration from version %d", ErrUnsupportedSectorVersion, data.Version)
+		}
+
+		from := data.Version
+		if err := migration(data); err != nil {
+			return fmt.Errorf("migrate sector file from version %d: %w", from, err)
+		}
+		data.Version = from + 1
+	}
+
+	return nil
+}
+
+// Version 0 is a raw gob dump of Sector,
+// which was used before the sector file format existed.
+type legacySector struct {
+	Chunks [SECTOR_WIDTH * SECTOR_HEIGHT]*legacyChunk
+}
+
+type legacyChunk struct {
+	Blocks [CHUNK_WIDTH * CHUNK_HEIGHT]*legacyBlock
+}
+
+type legacyBlock struct {
+	BlockType string
+	FrontFace int
+}
+
+func migrateLegacySector(data *sectorData) error {
+	legacy := new(legacySector)
+	if err := gob.NewDecoder(bytes.NewReader(data.raw)).Decode(legacy); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSectorFile, err)
+	}
+	data.raw = nil
+
+	paletteIndices := make(map[BlockType]int)
+	for i, chunk := range legacy.Chunks {
+		if chunk == nil {
+			continue
+		}
+
+		payload := make([]byte, 0, CHUNK_WIDTH*CHUNK_HEIGHT*2)
+		for _, block := range chunk.Blocks {
+			blockType, face := BLOCK_TYPE_VOID, 0
+			if block != nil {
+				blockType, face = BlockType(block.BlockType), block.FrontFace
+			}
+
+			index, exist := paletteIndices[blockType]
+			if !exist {
+				index = len(data.Palette)
+				paletteIndices[blockType] = index
+				data.Palette = append(data.Palette, blockType)
+			}
+
+			payload = binary.AppendUvarint(payload, uint64(index))
+			payload = binary.AppendVarint(payload, int64(face))
+		}
+		data.chunks[i] = payload
+	}
+
+	return nil
+}
+
+// Version 1 stores every block of a chunk as
+// (uvarint palette index, varint front face) in row major order.
+// Version 2 replaced it with chunk palettes and packed indices.
+func migrateBlockListSector(data *sectorData) error {
+	paletteIndices := make(map[BlockType]int)
+	for i, blockType := range data.Palette {
+		paletteIndices[blockType] = i
+	}
+
+	for i, payload := range data.chunks {
+		if len(payload) == 0 {
+			continue
+		}
+
+		chunkCoord := ChunkCoord{uint8(i % SECTOR_WIDTH), uint8(i / SECTOR_WIDTH)}
+		chunk, err := decodeBlockListChunk(chunkCoord, payload, data.Palette)
+		if err != nil {
+			return fmt.Errorf("%v: %w", chunkCoord, err)
+		}
+		data.chunks[i], _ = encodeChunk(chunk, 2, &data.Palette, paletteIndices)
+	}
+
+	return nil
+}
+
+func decodeBlockListChunk(coord ChunkCoord, payload []byte, palette []BlockType) (*Chunk, error) {
+	chunk := NewChunk(coord)
+
+	for i := 0; i < CHUNK_WIDTH*CHUNK_HEIGHT; i++ {
+		index, n := binary.Uvarint(payload)
+		if n <= 0 {
+			return nil, fmt.Errorf("%w: truncated chunk payload", ErrInvalidSectorFile)
+		}
+		payload = payload[n:]
+
+		face, n := binary.Varint(payload)
+		if n <= 0 {
+			return nil, fmt.Errorf("%w: truncated chunk payload", ErrInvalidSectorFile)
+		}
+		payload = payload[n:]
+
+		if index >= uint64(len(palette)) {
+			return nil, fmt.Errorf("%w: palette index %d out of range", ErrInvalidSectorFile, index)
+		}
+
+		blockCoord := BlockCoord{uint8(i % CHUNK_WIDTH), uint8(i / CHUNK_WIDTH)}
+		chunk.set(NewBlock(blockCoord, palette[index], int(face)))
+	}
+
+	if len(payload) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes in chunk payload", ErrInvalidSectorFile, len(payload))
+	}
+
+	return chunk, nil
+}
+
+// Version 2 stores the palette by name, version 3 by id of the block mapping.
+// Both are read into names, so there is nothing to do.
+func migrateNamedPaletteSector(data *sectorData) error {
+	return nil
+}
+
+// Version 4 added block state to chunk palette entries.
+// Blocks of older versions have zero state.
+func migrateStatelessSector(data *sectorData) error {
+	paletteIndices := make(map[BlockType]int)
+	for i, blockType := range data.Palette {
+		paletteIndices[blockType] = i
+	}
+
+	for i, payload := range data.chunks {
+		if len(payload) == 0 {
+			continue
+		}
+
+		chunkCoord := ChunkCoord{uint8(i % SECTOR_WIDTH), uint8(i / SECTOR_WIDTH)}
+		chunk, err := decodeChunk(chunkCoord, payload, 3, data.Palette)
+		if err != nil {
+			return fmt.Errorf("%v: %w", chunkCoord, err)
+		}
+		data.chunks[i], _ = encodeChunk(chunk, 4, &data.Palette, paletteIndices)
+	}
+
+	return nil
+}
+
+// Version 5 added tile entities, which older versions have none of.
+func migrateTilelessSector(data *sectorData) error {
+	return nil
+}
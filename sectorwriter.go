@@ -0,0 +1,189 @@
+package lostinspace
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrUniverseClosed = errors.New("universe is closed")
+
+// sectorWriter writes encoded sectors to files in background.
+//
+// Saving a sector which is still waiting in the queue replaces the queued data,
+// so a sector is written at most once per turn no matter how often it is saved.
+// Files are replaced atomically, a crash leaves either the old or the new file.
+type sectorWriter struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// Waiting sectors in the order they were first queued.
+	queue   []WorldSectorCoord
+	pending map[WorldSectorCoord]sectorWrite
+	// Sector which is being written right now.
+	writing *sectorWrite
+
+	// First error since last flush.
+	err    error
+	closed bool
+	done   chan struct{}
+}
+
+type sectorWrite struct {
+	coord WorldSectorCoord
+	path  string
+	data  []byte
+	// Called once the data is on the disk, not if it's replaced before or fails.
+	written func()
+}
+
+func newSectorWriter() *sectorWriter {
+	writer := &sectorWriter{
+		pending: make(map[WorldSectorCoord]sectorWrite),
+		done:    make(chan struct{}),
+	}
+	writer.cond = sync.NewCond(&writer.mu)
+
+	go writer.run()
+
+	return writer
+}
+
+// Queue data to be written to path.
+// Written is called from the writer goroutine once the data is on the disk, it may be nil.
+func (writer *sectorWriter) Write(coord WorldSectorCoord, path string, data []byte, written func()) error {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	if writer.closed {
+		return ErrUniverseClosed
+	}
+
+	if _, exist := writer.pending[coord]; !exist {
+		writer.queue = append(writer.queue, coord)
+	}
+	writer.pending[coord] = sectorWrite{coord, path, data, written}
+	writer.cond.Broadcast()
+
+	return nil
+}
+
+// Latest data of the sector which is not on the disk yet.
+func (writer *sectorWriter) Pending(coord WorldSectorCoord) ([]byte, bool) {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	if write, exist := writer.pending[coord]; exist {
+		return write.data, true
+	}
+	if writer.writing != nil && writer.writing.coord == coord {
+		return writer.writing.data, true
+	}
+
+	return nil, false
+}
+
+// Wait until every queued sector is written.
+// Return the first error occurred since last flush.
+func (writer *sectorWriter) Flush() error {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	for len(writer.queue) > 0 || writer.writing != nil {
+		writer.cond.Wait()
+	}
+
+	err := writer.err
+	writer.err = nil
+
+	return err
+}
+
+// Flush and stop the writer.
+func (writer *sectorWriter) Close() error {
+	writer.mu.Lock()
+	writer.closed = true
+	writer.cond.Broadcast()
+	writer.mu.Unlock()
+
+	<-writer.done
+
+	return writer.Flush()
+}
+
+func (writer *sectorWriter) run() {
+	defer close(writer.done)
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	for {
+		for len(writer.queue) == 0 && !writer.closed {
+			writer.cond.Wait()
+		}
+		if len(writer.queue) == 0 {
+			return
+		}
+
+		coord := writer.queue[0]
+		writer.queue = writer.queue[1:]
+		write := writer.pending[coord]
+		delete(writer.pending, coord)
+		writer.writing = &write
+
+		writer.mu.Unlock()
+		err := writeFileAtomic(write.path, write.data)
+		if err == nil && write.written != nil {
+			write.written()
+		}
+		writer.mu.Lock()
+
+		writer.writing = nil
+		if err != nil {
+			log.Printf("Failed to write %v: %v\n", coord, err)
+			if writer.err == nil {
+				writer.err = err
+			}
+		}
+		writer.cond.Broadcast()
+	}
+}
+
+// Write data to a temporary file next to path, sync it and rename it to path.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// Sync the directory too, otherwise the rename itself may be lost.
+	if dirFile, err := os.Open(dir); err == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+
+	return nil
+}
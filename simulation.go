@@ -0,0 +1,387 @@
+package lostinspace
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+type SimulationConfig struct {
+	Streaming StreamingConfig
+	// Stream chunks in Step instead of in background,
+	// so that runs with the same universe and input are the same.
+	SyncStreaming bool
+}
+
+func DefaultSimulationConfig() SimulationConfig {
+	return SimulationConfig{
+		Streaming: DefaultStreamingConfig(),
+	}
+}
+
+// Number of chunk bodies added to the world per step when streaming in background.
+// Adjusting it keeps the game going without hitches.
+const chunkBodiesPerStep = 5
+
+// Simulation is the game without window, rendering and input devices.
+// It runs terrain, physics and the player, and streams chunks around the player.
+// Rendering is a layer on top of it, see Game.
+//
+// Simulation must be stepped and edited from one goroutine.
+type Simulation struct {
+	config   SimulationConfig
+	universe *Universe
+	world    *World
+	terrain  *Terrain
+	dic      *BlockTypeDictionary
+	player   *Body
+	streamer *Streamer
+
+	tick uint64
+	// Chunks whose bodies are in the world, whose tile entities tick.
+	chunks map[WorldChunkCoord]*Chunk
+
+	// Bodies baked by the streamer, waiting to be added to the world,
+	// and changed blocks of loaded chunks, waiting for their collision to be updated.
+	// Each block is kept once, so changes can't pile up more than loaded chunks have blocks.
+	mu            sync.Mutex
+	chunkTasks    []chunkTask
+	changedBlocks []WorldBlockCoord
+	changedSet    map[WorldBlockCoord]struct{}
+}
+
+// Change of a chunk body, applied in the order the streamer made them.
+type chunkTask struct {
+	chunk *Chunk
+	// Baked but not added to the world yet. Nil to remove the chunk body.
+	collision *blockCollision
+	// Chunk revision the collision was baked from.
+	revision uint64
+}
+
+// Player is placed where it was when the universe was saved last time.
+// Streaming starts right away unless config.SyncStreaming is set.
+func NewSimulation(universe *Universe, dic *BlockTypeDictionary, config SimulationConfig) *Simulation {
+	sim := &Simulation{
+		config:   config,
+		universe: universe,
+		world:    NewWorld(),
+		terrain:  NewTerrain(),
+		dic:      dic,
+		chunks:   make(map[WorldChunkCoord]*Chunk),
+
+		changedSet: make(map[WorldBlockCoord]struct{}),
+	}
+	sim.terrain.Seed = universe.Seed()
+	sim.terrain.dic = dic
+
+	types := universe.Blocks().Types()
+	for id, dicID := range universe.Blocks().Remap(dic) {
+		if dicID == BLOCK_ID_MISSING && id < len(types) {
+			log.Printf("Block type %q of the universe is unknown, shown as missing block\n", types[id])
+		}
+	}
+
+	sim.player = NewPlayerBody(sim.world)
+	sim.player.SetPosition(universe.Manifest.PlayerPosition.X, universe.Manifest.PlayerPosition.Y)
+
+	sim.streamer = NewStreamer(config.Streaming, sim.terrain, universe, sim)
+	sim.updateFocus()
+	if !config.SyncStreaming {
+		sim.streamer.Start()
+	}
+
+	sim.terrain.AddBlockListener(sim)
+	RegisterEventListener(sim)
+
+	return sim
+}
+
+func (sim *Simulation) Universe() *Universe {
+	return sim.universe
+}
+
+func (sim *Simulation) World() *World {
+	return sim.world
+}
+
+func (sim *Simulation) Terrain() *Terrain {
+	return sim.terrain
+}
+
+func (sim *Simulation) Player() *Body {
+	return sim.player
+}
+
+func (sim *Simulation) Streamer() *Streamer {
+	return sim.streamer
+}
+
+// Number of steps taken.
+func (sim *Simulation) Tick() uint64 {
+	return sim.tick
+}
+
+// Handle queued events, update collision of changed blocks, move the player by pressed keys,
+// add streamed chunks to the world and step the world by dt.
+func (sim *Simulation) Step(dt time.Duration) {
+	PollEvents()
+	sim.updateChangedBlocks()
+
+	keyA := GetKeyActionState(KEY_A)
+	keyD := GetKeyActionState(KEY_D)
+	keyW := GetKeyActionState(KEY_W)
+	keyS := GetKeyActionState(KEY_S)
+	if keyA == ACTION_PRESS || keyA == ACTION_REPEAT {
+		sim.player.ApplyForceToCenter(Vec2{-40, 0})
+	}
+	if keyD == ACTION_PRESS || keyD == ACTION_REPEAT {
+		sim.player.ApplyForceToCenter(Vec2{40, 0})
+	}
+	if keyW == ACTION_PRESS || keyW == ACTION_REPEAT {
+		sim.player.ApplyForceToCenter(Vec2{0, 40})
+	}
+	if keyS == ACTION_PRESS || keyS == ACTION_REPEAT {
+		sim.player.ApplyForceToCenter(Vec2{0, -40})
+	}
+
+	if sim.config.SyncStreaming {
+		for sim.streamer.step() {
+		}
+		sim.applyChunkTasks(-1)
+	} else {
+		sim.applyChunkTasks(chunkBodiesPerStep)
+	}
+	sim.tickTileEntities(dt)
+
+	sim.world.Update(dt)
+	sim.tick++
+	sim.updateFocus()
+}
+
+// Set block at world coord without validation, see Terrain.PlaceBlock for that.
+// Collision of its chunk follows at the next step, see OnBlockChanged.
+// Return false if the chunk isn't loaded or the block is of BLOCK_TYPE_MISSING.
+func (sim *Simulation) SetBlock(coord WorldBlockCoord, block *Block) bool {
+	if block.BlockType == BLOCK_TYPE_MISSING {
+		return false
+	}
+	sectorCoord, chunkCoord, _ := coord.Parse()
+	worldChunkCoord := CombineWorldChunkCoord(sectorCoord, chunkCoord)
+
+	chunk := sim.streamer.LoadedChunk(worldChunkCoord)
+	if chunk == nil {
+		return false
+	}
+
+	sim.terrain.SetBlock(coord, block)
+
+	return true
+}
+
+// Stop streaming and save modified sectors and the player position.
+// Return after all sectors are written to the disk.
+func (sim *Simulation) Close() {
+	UnregisterEventListener(sim)
+	sim.terrain.RemoveBlockListener(sim)
+
+	if !sim.config.SyncStreaming {
+		sim.streamer.Close()
+	}
+
+	for _, coord := range sim.sortedChunks() {
+		sim.notifyTileEntities(coord, sim.chunks[coord], TileEntity.OnUnloaded)
+		delete(sim.chunks, coord)
+	}
+
+	if err := sim.saveSectors(); err != nil {
+		log.Printf("Failed to save sector: %v\n", err)
+	}
+
+	x, y := sim.player.GetPosition()
+	sim.universe.Manifest.PlayerPosition = Vec2{x, y}
+	if err := sim.universe.SaveManifest(); err != nil {
+		log.Printf("Failed to save universe: %v\n", err)
+	}
+
+	if err := sim.universe.Close(); err != nil {
+		log.Printf("Failed to save sectors: %v\n", err)
+	}
+}
+
+// Save modified sectors, which are written in background.
+// Return the first error, the other sectors are saved anyway.
+func (sim *Simulation) saveSectors() error {
+	var firstErr error
+	for _, sector := range sim.terrain.Sectors() {
+		if !sector.Modified() {
+			continue
+		}
+		if err := sim.universe.SaveSector(sector); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (sim *Simulation) OnEvent(event Event) {
+	switch event := event.(type) {
+	case EditBlockEvent:
+		// Edits which can't be made, like placing a block on another, are ignored.
+		if event.BlockType == BLOCK_TYPE_VOID {
+			sim.terrain.BreakBlock(event.Coord)
+		} else {
+			sim.terrain.PlaceBlock(event.Coord, event.BlockType, event.FrontFace)
+		}
+	}
+}
+
+// Keep the changed block for the next step, if its chunk is loaded.
+// Chunks which aren't loaded yet are baked as they are once they are.
+// Called from whichever goroutine changed the block.
+func (sim *Simulation) OnBlockChanged(event BlockChangedEvent) {
+	sectorCoord, chunkCoord, _ := event.Coord.Parse()
+	if sim.streamer.LoadedChunk(CombineWorldChunkCoord(sectorCoord, chunkCoord)) == nil {
+		return
+	}
+
+	sim.mu.Lock()
+	if _, exist := sim.changedSet[event.Coord]; !exist {
+		sim.changedSet[event.Coord] = struct{}{}
+		sim.changedBlocks = append(sim.changedBlocks, event.Coord)
+	}
+	sim.mu.Unlock()
+}
+
+// Update collision of blocks changed since the last step, in the order they were first changed.
+func (sim *Simulation) updateChangedBlocks() {
+	sim.mu.Lock()
+	changed := sim.changedBlocks
+	sim.changedBlocks = nil
+	for _, coord := range changed {
+		delete(sim.changedSet, coord)
+	}
+	sim.mu.Unlock()
+
+	for _, coord := range changed {
+		sim.updateCollision(coord)
+	}
+}
+
+// Update collision of the block at coord, which is changed.
+// Chunk whose body is still waiting is updated when it's added.
+func (sim *Simulation) updateCollision(coord WorldBlockCoord) {
+	sectorCoord, chunkCoord, blockCoord := coord.Parse()
+	chunk := sim.streamer.LoadedChunk(CombineWorldChunkCoord(sectorCoord, chunkCoord))
+	if chunk == nil || chunk.collision == nil {
+		return
+	}
+
+	chunk.collision.update(chunk, blockCoord)
+	chunk.collision.bakeBodies()
+}
+
+// Let the streamer know where the player is.
+func (sim *Simulation) updateFocus() {
+	x, y := sim.player.GetPosition()
+	vx, vy := sim.player.GetLinearVelocity()
+	sim.streamer.SetFocus(Vec2{x, y}, Vec2{vx, vy})
+}
+
+// Bake chunk collision, then let Step add it to the world.
+// The chunk itself is left alone since Step may still be removing its old body.
+func (sim *Simulation) OnChunkLoaded(coord WorldChunkCoord, chunk *Chunk) {
+	revision := chunk.Revision()
+	collision := newBlockCollision(newChunkBody(sim.world, coord), sim.dic, true)
+	collision.bake(chunk)
+
+	sim.queueChunkTask(chunkTask{chunk, collision, revision})
+}
+
+func (sim *Simulation) OnChunkUnloaded(coord WorldChunkCoord, chunk *Chunk) {
+	sim.queueChunkTask(chunkTask{chunk: chunk})
+}
+
+func (sim *Simulation) queueChunkTask(task chunkTask) {
+	sim.mu.Lock()
+	sim.chunkTasks = append(sim.chunkTasks, task)
+	sim.mu.Unlock()
+}
+
+// Apply at most max tasks, every task if max is negative.
+func (sim *Simulation) applyChunkTasks(max int) {
+	sim.mu.Lock()
+	count := len(sim.chunkTasks)
+	if max >= 0 && count > max {
+		count = max
+	}
+	tasks := sim.chunkTasks[:count:count]
+	sim.chunkTasks = sim.chunkTasks[count:]
+	sim.mu.Unlock()
+
+	for _, task := range tasks {
+		task.chunk.Destroy()
+		coord := task.chunk.WorldCoord()
+		if task.collision == nil {
+			if sim.chunks[coord] == task.chunk {
+				delete(sim.chunks, coord)
+				sim.notifyTileEntities(coord, task.chunk, TileEntity.OnUnloaded)
+			}
+			continue
+		}
+
+		if changes, ok := task.chunk.ChangesSince(task.revision); !ok {
+			task.collision.bake(task.chunk)
+		} else {
+			for _, coord := range changes {
+				task.collision.update(task.chunk, coord)
+			}
+		}
+		task.collision.bakeBodies()
+		task.chunk.collision = task.collision
+
+		sim.chunks[coord] = task.chunk
+		sim.notifyTileEntities(coord, task.chunk, TileEntity.OnLoaded)
+	}
+}
+
+// Tick tile entities of every chunk in the world, chunks in order of coords.
+func (sim *Simulation) tickTileEntities(dt time.Duration) {
+	for _, coord := range sim.sortedChunks() {
+		sim.notifyTileEntities(coord, sim.chunks[coord], func(tile TileEntity, ctx TileContext) {
+			ctx.DeltaTime = dt
+			tile.OnTick(ctx)
+		})
+	}
+}
+
+// Call hook of every tile entity of the chunk at coord.
+func (sim *Simulation) notifyTileEntities(coord WorldChunkCoord, chunk *Chunk, hook func(TileEntity, TileContext)) {
+	sectorCoord, chunkCoord := coord.Parse()
+	chunk.ForEachTileEntity(func(blockCoord BlockCoord, tile TileEntity) {
+		hook(tile, TileContext{
+			Terrain:    sim.terrain,
+			Simulation: sim,
+			Coord:      CombineWorldBlockCoord(sectorCoord, chunkCoord, blockCoord),
+		})
+	})
+}
+
+// Coords of chunks in the world, row by row from the bottom,
+// so that ticks are the same every run.
+func (sim *Simulation) sortedChunks() []WorldChunkCoord {
+	coords := make([]WorldChunkCoord, 0, len(sim.chunks))
+	for coord := range sim.chunks {
+		coords = append(coords, coord)
+	}
+	sort.Slice(coords, func(i, j int) bool {
+		if coords[i].Y != coords[j].Y {
+			return coords[i].Y < coords[j].Y
+		}
+		return coords[i].X < coords[j].X
+	})
+
+	return coords
+}
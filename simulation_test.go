@@ -0,0 +1,118 @@
+package lostinspace_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+const testStep = time.Second / 60
+
+// Block types without textures, which need no opengl.
+func testBlockTypeDic() *lostinspace.BlockTypeDictionary {
+	square := []lostinspace.Vec2{{X: -0.5, Y: 0.5}, {X: -0.5, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: 0.5, Y: 0.5}}
+
+	return lostinspace.NewBlockTypeDictionary([]*lostinspace.BlockTypeDescriptor{
+		{BlockType: "stone", Density: 0.5, Friction: 0.2, Restitution: 0.01, CollisionVertices: square, Fixed: true},
+		{BlockType: "test1", Density: 1, Friction: 0.2, Restitution: 0.01, CollisionVertices: square, Fixed: true},
+		{BlockType: "test2", Density: 1, Friction: 0.2, Restitution: 0.01, CollisionVertices: square, Fixed: true},
+		{BlockType: "door0", Density: 1, Friction: 0.2, Restitution: 0.01, CollisionVertices: square},
+	})
+}
+
+func newTestSimulation(t *testing.T, path string) *lostinspace.Simulation {
+	universe, err := lostinspace.OpenUniverse(path)
+	if err != nil {
+		universe, err = lostinspace.CreateUniverse(path, "test", 11)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := lostinspace.DefaultSimulationConfig()
+	config.SyncStreaming = true
+
+	return lostinspace.NewSimulation(universe, testBlockTypeDic(), config)
+}
+
+func pressKey(key lostinspace.Key, action lostinspace.Action) {
+	lostinspace.PushEvent(lostinspace.KeyboardEvent{Key: key, Action: action})
+}
+
+func TestSimulationDeterministic(t *testing.T) {
+	run := func() (float64, float64) {
+		sim := newTestSimulation(t, t.TempDir())
+		defer sim.Close()
+
+		pressKey(lostinspace.KEY_D, lostinspace.ACTION_PRESS)
+		pressKey(lostinspace.KEY_W, lostinspace.ACTION_PRESS)
+		for i := 0; i < 120; i++ {
+			sim.Step(testStep)
+		}
+		pressKey(lostinspace.KEY_D, lostinspace.ACTION_RELEASE)
+		pressKey(lostinspace.KEY_W, lostinspace.ACTION_RELEASE)
+		for i := 0; i < 60; i++ {
+			sim.Step(testStep)
+		}
+
+		if sim.Tick() != 180 {
+			t.Errorf("Tick: %d\n", sim.Tick())
+		}
+		return sim.Player().GetPosition()
+	}
+
+	x1, y1 := run()
+	x2, y2 := run()
+	if x1 != x2 || y1 != y2 {
+		t.Errorf("(%v, %v) != (%v, %v)\n", x1, y1, x2, y2)
+	}
+	if x1 == 0 && y1 == 0 {
+		t.Errorf("Player didn't move\n")
+	}
+}
+
+func TestSimulationEdit(t *testing.T) {
+	path := t.TempDir()
+	sim := newTestSimulation(t, path)
+	sim.Step(testStep)
+
+	// Empty room with a wall on the right.
+	for y := int64(-3); y <= 3; y++ {
+		for x := int64(-3); x <= 8; x++ {
+			blockType := lostinspace.BLOCK_TYPE_VOID
+			if x == 5 {
+				blockType = "stone"
+			}
+			if !sim.SetBlock(lostinspace.WorldBlockCoord{X: x, Y: y}, lostinspace.NewBlock(lostinspace.BlockCoord{}, blockType, 0)) {
+				t.Fatalf("(%d, %d) is not loaded\n", x, y)
+			}
+		}
+	}
+
+	pressKey(lostinspace.KEY_D, lostinspace.ACTION_PRESS)
+	for i := 0; i < 180; i++ {
+		sim.Step(testStep)
+	}
+	pressKey(lostinspace.KEY_D, lostinspace.ACTION_RELEASE)
+	sim.Step(testStep)
+
+	x, _ := sim.Player().GetPosition()
+	if x < 1 || x > 4.5 {
+		t.Errorf("Player is at %v, expected to be stopped by the wall\n", x)
+	}
+
+	sim.Close()
+
+	reopened := newTestSimulation(t, path)
+	defer reopened.Close()
+
+	if reopenedX, _ := reopened.Player().GetPosition(); reopenedX != x {
+		t.Errorf("Player is at %v after reopened, expected %v\n", reopenedX, x)
+	}
+	reopened.Step(testStep)
+	block := reopened.Terrain().GetBlock(lostinspace.WorldBlockCoord{X: 5, Y: 0})
+	if block == nil || block.BlockType != "stone" {
+		t.Errorf("Saved block: %v\n", block)
+	}
+}
@@ -0,0 +1,388 @@
+package lostinspace
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// SoftwareBackend draws into an image by cpu, so that the world can be drawn without gpu,
+// for screenshots and golden image tests.
+//
+// Shader sources are ignored. Every program runs as the shaders of Game do:
+// vertices are moved by projection*camera*translate*rotate,
+// or texture coordinates are if translateMode is 1,
+// and the color is taken from tex2D at unit 0, or tex2DArray at unit 1 if texMode is 1.
+// Textures are sampled by nearest texel and blended by alpha.
+type SoftwareBackend struct {
+	frame *image.RGBA
+
+	lastID       uint32
+	buffers      map[BufferID]interface{}
+	vertexArrays map[VertexArrayID]*softwareVertexArray
+	textures     map[TextureID]*softwareTexture
+	programs     map[ProgramID]*softwareProgram
+
+	program ProgramID
+	units   map[uint32]TextureID
+}
+
+type softwareVertexArray struct {
+	attribs     [3]BufferID
+	indexBuffer BufferID
+}
+
+type softwareTexture struct {
+	width  int
+	height int
+	// One layer for 2d texture. Nil layer is transparent.
+	layers []*image.RGBA
+}
+
+type softwareProgram struct {
+	ints map[string]int32
+	mats map[string]mgl32.Mat4
+}
+
+// Color the frame is cleared to.
+var softwareClearColor = color.RGBA{0, 0, 25, 255}
+
+func NewSoftwareBackend(width, height int) *SoftwareBackend {
+	backend := &SoftwareBackend{
+		buffers:      make(map[BufferID]interface{}),
+		vertexArrays: make(map[VertexArrayID]*softwareVertexArray),
+		textures:     make(map[TextureID]*softwareTexture),
+		programs:     make(map[ProgramID]*softwareProgram),
+		units:        make(map[uint32]TextureID),
+	}
+	backend.Resize(width, height)
+
+	return backend
+}
+
+// The frame being drawn, or the last one after EndFrame.
+func (backend *SoftwareBackend) Image() *image.RGBA {
+	return backend.frame
+}
+
+func (backend *SoftwareBackend) newID() uint32 {
+	backend.lastID++
+	return backend.lastID
+}
+
+func (backend *SoftwareBackend) CreateBuffer() BufferID {
+	buffer := BufferID(backend.newID())
+	backend.buffers[buffer] = nil
+	return buffer
+}
+
+// Data is copied, like opengl does.
+func (backend *SoftwareBackend) BufferData(buffer BufferID, data interface{}) {
+	switch data := data.(type) {
+	case []float32:
+		backend.buffers[buffer] = append([]float32(nil), data...)
+	case []uint16:
+		indices := make([]uint32, len(data))
+		for i, index := range data {
+			indices[i] = uint32(index)
+		}
+		backend.buffers[buffer] = indices
+	case []uint32:
+		backend.buffers[buffer] = append([]uint32(nil), data...)
+	default:
+		panic(fmt.Errorf("Unsupported buffer data %T.", data))
+	}
+}
+
+func (backend *SoftwareBackend) BufferSubData(buffer BufferID, offset int, data interface{}) {
+	switch data := data.(type) {
+	case []float32:
+		if contents, ok := backend.buffers[buffer].([]float32); ok && offset+len(data) <= len(contents) {
+			copy(contents[offset:], data)
+		}
+	case []uint16:
+		if contents, ok := backend.buffers[buffer].([]uint32); ok && offset+len(data) <= len(contents) {
+			for i, index := range data {
+				contents[offset+i] = uint32(index)
+			}
+		}
+	case []uint32:
+		if contents, ok := backend.buffers[buffer].([]uint32); ok && offset+len(data) <= len(contents) {
+			copy(contents[offset:], data)
+		}
+	default:
+		panic(fmt.Errorf("Unsupported buffer data %T.", data))
+	}
+}
+
+func (backend *SoftwareBackend) DeleteBuffer(buffer BufferID) {
+	delete(backend.buffers, buffer)
+}
+
+func (backend *SoftwareBackend) CreateVertexArray() VertexArrayID {
+	vertexArray := VertexArrayID(backend.newID())
+	backend.vertexArrays[vertexArray] = new(softwareVertexArray)
+	return vertexArray
+}
+
+func (backend *SoftwareBackend) VertexAttrib(vertexArray VertexArrayID, attrib uint32, buffer BufferID) {
+	backend.vertexArrays[vertexArray].attribs[attrib] = buffer
+}
+
+func (backend *SoftwareBackend) IndexBuffer(vertexArray VertexArrayID, buffer BufferID) {
+	backend.vertexArrays[vertexArray].indexBuffer = buffer
+}
+
+func (backend *SoftwareBackend) DeleteVertexArray(vertexArray VertexArrayID) {
+	delete(backend.vertexArrays, vertexArray)
+}
+
+func (backend *SoftwareBackend) CreateTexture2D(img *image.RGBA) TextureID {
+	texture := TextureID(backend.newID())
+	size := img.Rect.Size()
+	backend.textures[texture] = &softwareTexture{
+		width:  size.X,
+		height: size.Y,
+		layers: []*image.RGBA{img},
+	}
+	return texture
+}
+
+func (backend *SoftwareBackend) CreateTexture2DArray(width, height int32, layers []*image.RGBA) TextureID {
+	texture := TextureID(backend.newID())
+	backend.textures[texture] = &softwareTexture{
+		width:  int(width),
+		height: int(height),
+		layers: append([]*image.RGBA(nil), layers...),
+	}
+	return texture
+}
+
+func (backend *SoftwareBackend) BindTexture(unit uint32, texture TextureID) {
+	backend.units[unit] = texture
+}
+
+func (backend *SoftwareBackend) DeleteTexture(texture TextureID) {
+	delete(backend.textures, texture)
+}
+
+func (backend *SoftwareBackend) CreateProgram(vertexSource, fragmentSource string) (ProgramID, error) {
+	program := ProgramID(backend.newID())
+	backend.programs[program] = &softwareProgram{
+		ints: make(map[string]int32),
+		mats: make(map[string]mgl32.Mat4),
+	}
+	return program, nil
+}
+
+func (backend *SoftwareBackend) UseProgram(program ProgramID) {
+	backend.program = program
+}
+
+func (backend *SoftwareBackend) UniformInt(program ProgramID, name string, value int32) {
+	backend.programs[program].ints[name] = value
+}
+
+func (backend *SoftwareBackend) UniformMat4(program ProgramID, name string, value mgl32.Mat4) {
+	backend.programs[program].mats[name] = value
+}
+
+func (backend *SoftwareBackend) DeleteProgram(program ProgramID) {
+	delete(backend.programs, program)
+}
+
+func (backend *SoftwareBackend) Resize(width, height int) {
+	backend.frame = image.NewRGBA(image.Rect(0, 0, width, height))
+}
+
+func (backend *SoftwareBackend) BeginFrame() {
+	pix := backend.frame.Pix
+	for i := 0; i < len(pix); i += 4 {
+		pix[i+0] = softwareClearColor.R
+		pix[i+1] = softwareClearColor.G
+		pix[i+2] = softwareClearColor.B
+		pix[i+3] = softwareClearColor.A
+	}
+}
+
+func (backend *SoftwareBackend) EndFrame() {
+}
+
+// A vertex after the vertex shader, in window coordinates which are pixels from bottom left.
+type softwareVertex struct {
+	x, y     float32
+	texCoord mgl32.Vec3
+}
+
+func (backend *SoftwareBackend) DrawElements(vertexArray VertexArrayID, count int32) {
+	program := backend.programs[backend.program]
+	vao := backend.vertexArrays[vertexArray]
+	if program == nil || vao == nil {
+		return
+	}
+	indices, _ := backend.buffers[vao.indexBuffer].([]uint32)
+	positions, _ := backend.buffers[vao.attribs[ATTRIB_POSITION]].([]float32)
+	texCoords, _ := backend.buffers[vao.attribs[ATTRIB_TEX_COORD]].([]float32)
+	if vao.attribs[ATTRIB_TEX_COORD] == 0 {
+		texCoords = nil
+	}
+	if int(count) > len(indices) {
+		count = int32(len(indices))
+	}
+
+	m := program.mats["projection"].Mul4(program.mats["camera"]).Mul4(program.mats["translate"]).Mul4(program.mats["rotate"])
+	translateMode := program.ints["translateMode"]
+
+	var texture *softwareTexture
+	if program.ints["texMode"] == 1 {
+		texture = backend.textures[backend.units[1]]
+	} else {
+		texture = backend.textures[backend.units[0]]
+	}
+
+	size := backend.frame.Rect.Size()
+	vertex := func(index uint32) softwareVertex {
+		position := attribAt(positions, index)
+		texCoord := attribAt(texCoords, index)
+
+		var clip mgl32.Vec4
+		if translateMode == 1 {
+			texCoord = m.Mul4x1(texCoord.Vec4(1)).Vec3()
+			clip = position.Vec4(1)
+		} else {
+			clip = m.Mul4x1(position.Vec4(1))
+		}
+
+		return softwareVertex{
+			x:        (clip.X()/clip.W() + 1) / 2 * float32(size.X),
+			y:        (clip.Y()/clip.W() + 1) / 2 * float32(size.Y),
+			texCoord: texCoord,
+		}
+	}
+
+	for i := int32(0); i+2 < count; i += 3 {
+		backend.drawTriangle(
+			vertex(indices[i]), vertex(indices[i+1]), vertex(indices[i+2]),
+			texture,
+		)
+	}
+}
+
+// Attribute of 3 floats of vertex at index, zero if there is no attribute.
+func attribAt(data []float32, index uint32) mgl32.Vec3 {
+	i := int(index) * 3
+	if i+3 > len(data) {
+		return mgl32.Vec3{}
+	}
+	return mgl32.Vec3{data[i], data[i+1], data[i+2]}
+}
+
+// Fill pixels whose centers are in the triangle.
+// Pixels on an edge are filled only if it's a top or left edge,
+// so that pixels on edges shared by two triangles are drawn once.
+func (backend *SoftwareBackend) drawTriangle(v0, v1, v2 softwareVertex, texture *softwareTexture) {
+	area := edgeFunction(v0, v1, v2.x, v2.y)
+	if area == 0 {
+		return
+	}
+	if area < 0 { // make it counter clockwise
+		v1, v2 = v2, v1
+		area = -area
+	}
+
+	size := backend.frame.Rect.Size()
+	minX := int(math.Max(0, math.Floor(float64(min3(v0.x, v1.x, v2.x)))))
+	maxX := int(math.Min(float64(size.X-1), math.Ceil(float64(max3(v0.x, v1.x, v2.x)))))
+	minY := int(math.Max(0, math.Floor(float64(min3(v0.y, v1.y, v2.y)))))
+	maxY := int(math.Min(float64(size.Y-1), math.Ceil(float64(max3(v0.y, v1.y, v2.y)))))
+
+	topLeft0, topLeft1, topLeft2 := isTopLeft(v1, v2), isTopLeft(v2, v0), isTopLeft(v0, v1)
+	for y := minY; y <= maxY; y++ {
+		py := float32(y) + 0.5
+		for x := minX; x <= maxX; x++ {
+			px := float32(x) + 0.5
+
+			w0 := edgeFunction(v1, v2, px, py)
+			w1 := edgeFunction(v2, v0, px, py)
+			w2 := edgeFunction(v0, v1, px, py)
+			if w0 < 0 || w1 < 0 || w2 < 0 ||
+				(w0 == 0 && !topLeft0) || (w1 == 0 && !topLeft1) || (w2 == 0 && !topLeft2) {
+				continue
+			}
+
+			texCoord := v0.texCoord.Mul(w0 / area).
+				Add(v1.texCoord.Mul(w1 / area)).
+				Add(v2.texCoord.Mul(w2 / area))
+			// Window y goes up, image y goes down.
+			backend.blend(x, size.Y-1-y, texture.sample(texCoord))
+		}
+	}
+}
+
+// Twice the signed area of triangle (a, b, p), positive if counter clockwise.
+func edgeFunction(a, b softwareVertex, px, py float32) float32 {
+	return (b.x-a.x)*(py-a.y) - (b.y-a.y)*(px-a.x)
+}
+
+// Edge of a counter clockwise triangle in window coordinates,
+// which is top if it's horizontal and goes left, or left if it goes down.
+func isTopLeft(a, b softwareVertex) bool {
+	return (a.y == b.y && b.x < a.x) || b.y < a.y
+}
+
+func min3(a, b, c float32) float32 {
+	return float32(math.Min(float64(a), math.Min(float64(b), float64(c))))
+}
+
+func max3(a, b, c float32) float32 {
+	return float32(math.Max(float64(a), math.Max(float64(b), float64(c))))
+}
+
+// Nearest texel at (u, v, layer), repeating out of [0, 1]. V is 0 at the first row of the image.
+func (texture *softwareTexture) sample(texCoord mgl32.Vec3) color.RGBA {
+	if texture == nil {
+		return color.RGBA{}
+	}
+
+	layer := int(math.Floor(float64(texCoord.Z()) + 0.5))
+	if layer < 0 || layer >= len(texture.layers) || texture.layers[layer] == nil {
+		return color.RGBA{}
+	}
+	img := texture.layers[layer]
+
+	x := int(math.Floor(float64(texCoord.X()) * float64(texture.width)))
+	y := int(math.Floor(float64(texCoord.Y()) * float64(texture.height)))
+	x = ((x % texture.width) + texture.width) % texture.width
+	y = ((y % texture.height) + texture.height) % texture.height
+
+	if !(image.Point{x, y}.In(img.Rect)) {
+		return color.RGBA{}
+	}
+	return img.RGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+}
+
+// Blend src over the pixel by alpha of src.
+func (backend *SoftwareBackend) blend(x, y int, src color.RGBA) {
+	if src.A == 0 {
+		return
+	}
+	if src.A == 255 {
+		backend.frame.SetRGBA(x, y, src)
+		return
+	}
+
+	dst := backend.frame.RGBAAt(x, y)
+	alpha := float64(src.A) / 255
+	mix := func(s, d uint8) uint8 {
+		return uint8(math.Round(float64(s)*alpha + float64(d)*(1-alpha)))
+	}
+	backend.frame.SetRGBA(x, y, color.RGBA{
+		mix(src.R, dst.R),
+		mix(src.G, dst.G),
+		mix(src.B, dst.B),
+		mix(src.A, dst.A),
+	})
+}
@@ -0,0 +1,175 @@
+package lostinspace_test
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/rlj1202/LostInSpace"
+)
+
+var update = flag.Bool("update", false, "write golden images instead of comparing with them")
+
+func TestSoftwareBackendQuad(t *testing.T) {
+	backend := lostinspace.NewSoftwareBackend(4, 4)
+
+	// Red, green on the first row, blue and half transparent white on the second.
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	img.SetRGBA(1, 0, color.RGBA{0, 255, 0, 255})
+	img.SetRGBA(0, 1, color.RGBA{0, 0, 255, 255})
+	img.SetRGBA(1, 1, color.RGBA{255, 255, 255, 128})
+	backend.BindTexture(0, backend.CreateTexture2D(img))
+
+	shader := lostinspace.NewShaderProgram(backend, "", "")
+	for _, name := range []string{"projection", "camera", "translate", "rotate"} {
+		shader.UniformMat4(name, mgl32.Ident4())
+	}
+	shader.Bind()
+
+	// Covers the whole frame, first row of the texture at the top.
+	quad := lostinspace.NewMesh(
+		[]float32{-1, 1, 0, -1, -1, 0, 1, -1, 0, 1, 1, 0},
+		nil,
+		[]float32{0, 0, 0, 0, 1, 0, 1, 1, 0, 1, 0, 0},
+		[]uint32{0, 1, 2, 0, 2, 3},
+	)
+	quad.Bake(backend)
+
+	backend.BeginFrame()
+	quad.Draw()
+	backend.EndFrame()
+
+	frame := backend.Image()
+	expected := map[image.Point]color.RGBA{
+		{0, 0}: {255, 0, 0, 255},
+		{3, 0}: {0, 255, 0, 255},
+		{0, 3}: {0, 0, 255, 255},
+		// Blended once even on the diagonal shared by both triangles.
+		{2, 2}: {128, 128, 140, 191},
+		{3, 3}: {128, 128, 140, 191},
+	}
+	for point, c := range expected {
+		if actual := frame.RGBAAt(point.X, point.Y); actual != c {
+			t.Errorf("%v: %v, expected %v\n", point, actual, c)
+		}
+	}
+}
+
+// Vertices beyond 16 bit indices are drawn.
+func TestSoftwareBackendIndices32(t *testing.T) {
+	backend := lostinspace.NewSoftwareBackend(4, 4)
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	backend.BindTexture(0, backend.CreateTexture2D(img))
+
+	shader := lostinspace.NewShaderProgram(backend, "", "")
+	for _, name := range []string{"projection", "camera", "translate", "rotate"} {
+		shader.UniformMat4(name, mgl32.Ident4())
+	}
+	shader.Bind()
+
+	// Only the last triangle, which covers the whole frame, is not degenerate.
+	const first = 1 << 16
+	positions := make([]float32, (first+3)*3)
+	copy(positions[first*3:], []float32{-1, -1, 0, 3, -1, 0, -1, 3, 0})
+	mesh := lostinspace.NewMesh(positions, nil, nil, []uint32{0, 0, 0, first, first + 1, first + 2})
+	mesh.Bake(backend)
+
+	backend.BeginFrame()
+	mesh.Draw()
+	backend.EndFrame()
+
+	if c := backend.Image().RGBAAt(3, 3); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("%v, expected the triangle to be drawn\n", c)
+	}
+}
+
+// Block types of the game, loaded from the working directory.
+func assetBlockTypeDic(t *testing.T) *lostinspace.BlockTypeDictionary {
+	dic, err := lostinspace.LoadBlockTypes("blocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dic
+}
+
+func TestSoftwareBackendGolden(t *testing.T) {
+	golden := filepath.Join(chdirAssets(t), "testdata", "game.png")
+
+	universe, err := lostinspace.CreateUniverse(t.TempDir(), "test", 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := lostinspace.DefaultSimulationConfig()
+	config.SyncStreaming = true
+	sim := lostinspace.NewSimulation(universe, assetBlockTypeDic(t), config)
+
+	backend := lostinspace.NewSoftwareBackend(150, 112)
+	game := lostinspace.NewGame(sim, backend, 150, 112)
+	defer game.Destroy()
+
+	game.Update(testStep)
+	for x := int64(-6); x <= 6; x++ {
+		sim.SetBlock(lostinspace.WorldBlockCoord{X: x, Y: -3}, lostinspace.NewBlock(lostinspace.BlockCoord{}, "stone", 0))
+	}
+	sim.SetBlock(lostinspace.WorldBlockCoord{X: -4, Y: 2}, lostinspace.NewBlock(lostinspace.BlockCoord{}, "test1", 0))
+	sim.SetBlock(lostinspace.WorldBlockCoord{X: -3, Y: 2}, lostinspace.NewBlock(lostinspace.BlockCoord{}, "test2", 0))
+	sim.SetBlock(lostinspace.WorldBlockCoord{X: 4, Y: -2}, lostinspace.NewBlock(lostinspace.BlockCoord{}, "door0", 1))
+	for i := 0; i < 30; i++ {
+		game.Update(testStep)
+	}
+	// Enough frames to make meshes of every chunk to draw.
+	for i := 0; i < 64; i++ {
+		game.Render(0)
+	}
+	frame := backend.Image()
+
+	if *update {
+		writePNG(t, golden, frame)
+		return
+	}
+
+	file, err := os.Open(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	expected, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A few pixels on edges may differ by rounding of other machines.
+	differ := 0
+	bounds := frame.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if color.RGBAModel.Convert(expected.At(x, y)) != frame.RGBAAt(x, y) {
+				differ++
+			}
+		}
+	}
+	if differ > bounds.Dx()*bounds.Dy()/200 {
+		writePNG(t, filepath.Join(os.TempDir(), "game.png"), frame)
+		t.Errorf("%d pixels differ from %s, run with -update if it's expected\n", differ, golden)
+	}
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Wrote %s\n", path)
+}
@@ -0,0 +1,351 @@
+package lostinspace
+
+import (
+	"errors"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Distances are in blocks.
+type StreamingConfig struct {
+	// Chunks closer than LoadRadius to the player are loaded.
+	LoadRadius float64
+	// Loaded chunks are unloaded once they are farther than UnloadRadius,
+	// sectors are saved and dropped once all of their chunks are.
+	// It's larger than LoadRadius so that chunks at the border
+	// are not loaded and unloaded over and over. Smaller value means LoadRadius.
+	UnloadRadius float64
+	// Loaded chunks closer than DrawRadius to the player are drawn.
+	DrawRadius float64
+	// Chunks are loaded in order of distance from the player,
+	// but chunks the player is heading to look closer by up to DirectionWeight of their distance
+	// and chunks behind look farther as much. Between 0 and 1.
+	DirectionWeight float64
+}
+
+func DefaultStreamingConfig() StreamingConfig {
+	return StreamingConfig{
+		LoadRadius:      192,
+		UnloadRadius:    320,
+		DrawRadius:      144,
+		DirectionWeight: 0.5,
+	}
+}
+
+// Number of chunks loaded between updates of the draw list.
+const streamBatchSize = 8
+
+// StreamListener is told about chunks the streamer loads and unloads.
+// It's called from the streaming goroutine.
+type StreamListener interface {
+	// Called before the chunk is drawn or returned by LoadedChunk.
+	OnChunkLoaded(WorldChunkCoord, *Chunk)
+	// Called after the chunk is not drawn nor returned by LoadedChunk anymore.
+	OnChunkUnloaded(WorldChunkCoord, *Chunk)
+}
+
+// Streamer loads chunks around the player one by one and unloads chunks the player left behind,
+// in background. It also keeps the list of chunks to draw.
+//
+// Sectors are only the unit of files. A sector is read into the terrain
+// when its first chunk is loaded, and saved when its last chunk is unloaded.
+// Loading a chunk which isn't in the file generates it.
+// Unloaded chunks stay in the terrain until their sector is dropped,
+// so that changes in them are saved.
+//
+// The streaming goroutine sleeps until the player moves to another chunk.
+type Streamer struct {
+	config   StreamingConfig
+	terrain  *Terrain
+	universe *Universe
+	listener StreamListener
+
+	mu           sync.Mutex
+	position     Vec2
+	velocity     Vec2
+	focusChunk   WorldChunkCoord
+	focused      bool
+	loaded       map[WorldChunkCoord]*Chunk
+	chunksToDraw map[WorldChunkCoord]*Chunk
+
+	notify chan struct{}
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+func NewStreamer(config StreamingConfig, terrain *Terrain, universe *Universe, listener StreamListener) *Streamer {
+	if config.UnloadRadius < config.LoadRadius {
+		config.UnloadRadius = config.LoadRadius
+	}
+
+	streamer := &Streamer{
+		config:   config,
+		terrain:  terrain,
+		universe: universe,
+		listener: listener,
+
+		loaded:       make(map[WorldChunkCoord]*Chunk),
+		chunksToDraw: make(map[WorldChunkCoord]*Chunk),
+
+		notify: make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	return streamer
+}
+
+// Start streaming goroutine.
+func (streamer *Streamer) Start() {
+	go streamer.run()
+}
+
+// Stop streaming goroutine and wait for it.
+// Loaded sectors stay in the terrain.
+func (streamer *Streamer) Close() {
+	close(streamer.quit)
+	<-streamer.done
+}
+
+// Tell where the player is and how fast it's moving.
+// It's cheap enough to call every update,
+// the streaming goroutine is woken up only when the player enters another chunk.
+func (streamer *Streamer) SetFocus(position, velocity Vec2) {
+	chunk := worldChunkCoordAt(position)
+
+	streamer.mu.Lock()
+	streamer.position = position
+	streamer.velocity = velocity
+	changed := !streamer.focused || chunk != streamer.focusChunk
+	streamer.focusChunk = chunk
+	streamer.focused = true
+	streamer.mu.Unlock()
+
+	if changed {
+		select {
+		case streamer.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Chunks to draw, keyed by where they are.
+// The map must not be changed, a new one is made whenever it changes.
+func (streamer *Streamer) ChunksToDraw() map[WorldChunkCoord]*Chunk {
+	streamer.mu.Lock()
+	defer streamer.mu.Unlock()
+
+	return streamer.chunksToDraw
+}
+
+// Loaded chunk at coord, or nil.
+// Chunks in the terrain are not always loaded, see Streamer.
+func (streamer *Streamer) LoadedChunk(coord WorldChunkCoord) *Chunk {
+	streamer.mu.Lock()
+	defer streamer.mu.Unlock()
+
+	return streamer.loaded[coord]
+}
+
+func (streamer *Streamer) run() {
+	defer close(streamer.done)
+
+	for {
+		select {
+		case <-streamer.quit:
+			return
+		case <-streamer.notify:
+		}
+
+		for streamer.step() {
+			select {
+			case <-streamer.quit:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// Unload chunks and sectors out of range, load the most urgent chunks and update the draw list.
+// The loaded chunks map is only written here, under the lock.
+// Return whether there may be more chunks to load.
+func (streamer *Streamer) step() bool {
+	streamer.mu.Lock()
+	position, velocity := streamer.position, streamer.velocity
+	unloaded := make(map[WorldChunkCoord]*Chunk)
+	for coord, chunk := range streamer.loaded {
+		if chunkDistance(coord, position) > streamer.config.UnloadRadius {
+			delete(streamer.loaded, coord)
+			unloaded[coord] = chunk
+		}
+	}
+	streamer.mu.Unlock()
+
+	if len(unloaded) > 0 {
+		// Nothing draws them from now on, so they can be destroyed.
+		streamer.updateChunksToDraw(position)
+	}
+	for coord, chunk := range unloaded {
+		streamer.listener.OnChunkUnloaded(coord, chunk)
+	}
+
+	// Chunks are never farther than their sector,
+	// so every chunk of these sectors is unloaded already.
+	for _, sector := range streamer.terrain.Sectors() {
+		if sectorDistance(sector.coord, position) > streamer.config.UnloadRadius {
+			streamer.unloadSector(sector)
+		}
+	}
+
+	toLoad := streamer.chunksToLoad(position, velocity)
+	count := len(toLoad)
+	if count > streamBatchSize {
+		count = streamBatchSize
+	}
+	for _, coord := range toLoad[:count] {
+		streamer.loadChunk(coord)
+	}
+
+	streamer.updateChunksToDraw(position)
+
+	return len(toLoad) > count
+}
+
+// Chunks in load radius which are not loaded yet, the most urgent first.
+func (streamer *Streamer) chunksToLoad(position, velocity Vec2) []WorldChunkCoord {
+	radius := streamer.config.LoadRadius
+	min := worldChunkCoordAt(Vec2{position.X - radius, position.Y - radius})
+	max := worldChunkCoordAt(Vec2{position.X + radius, position.Y + radius})
+
+	coords := make([]WorldChunkCoord, 0)
+	for y := min.Y; y <= max.Y; y++ {
+		for x := min.X; x <= max.X; x++ {
+			coord := WorldChunkCoord{x, y}
+			if chunkDistance(coord, position) > radius {
+				continue
+			}
+			if _, exist := streamer.loaded[coord]; exist {
+				continue
+			}
+			coords = append(coords, coord)
+		}
+	}
+
+	speed := math.Hypot(velocity.X, velocity.Y)
+	priority := func(coord WorldChunkCoord) float64 {
+		distance := chunkDistance(coord, position)
+		if distance == 0 || speed == 0 {
+			return distance
+		}
+
+		dx := float64(coord.X*CHUNK_WIDTH) + CHUNK_WIDTH/2 - position.X
+		dy := float64(coord.Y*CHUNK_HEIGHT) + CHUNK_HEIGHT/2 - position.Y
+		cos := (dx*velocity.X + dy*velocity.Y) / (math.Hypot(dx, dy) * speed)
+
+		return distance * (1 - streamer.config.DirectionWeight*cos)
+	}
+	sort.SliceStable(coords, func(i, j int) bool {
+		return priority(coords[i]) < priority(coords[j])
+	})
+
+	return coords
+}
+
+// Get chunk from its sector, reading the sector or generating the chunk if needed.
+func (streamer *Streamer) loadChunk(coord WorldChunkCoord) {
+	sectorCoord, chunkCoord := coord.Parse()
+
+	sector := streamer.terrain.GetSector(sectorCoord)
+	if sector == nil {
+		sector = streamer.loadSector(sectorCoord)
+		streamer.terrain.SetSector(sector)
+	}
+
+	chunk := sector.At(chunkCoord)
+	if chunk == nil {
+		chunk = GenerateChunk(streamer.terrain.Seed, coord)
+		sector.Set(chunk)
+	}
+
+	streamer.listener.OnChunkLoaded(coord, chunk)
+
+	streamer.mu.Lock()
+	streamer.loaded[coord] = chunk
+	streamer.mu.Unlock()
+}
+
+// Read sector from the universe.
+// Sector which was never saved has no chunks.
+func (streamer *Streamer) loadSector(coord WorldSectorCoord) *Sector {
+	sector, err := streamer.universe.LoadSector(coord)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to load sector: %v\n", err)
+		}
+		sector = NewSector(coord)
+	}
+
+	log.Printf("Load %v\n", coord)
+
+	return sector
+}
+
+// Drop sector from the terrain and save it if it's modified.
+func (streamer *Streamer) unloadSector(sector *Sector) {
+	streamer.terrain.DeleteSector(sector.coord)
+
+	if sector.Modified() {
+		if err := streamer.universe.SaveSector(sector); err != nil {
+			log.Printf("Failed to save sector: %v\n", err)
+		}
+	}
+
+	log.Printf("Unload %v\n", sector.coord)
+}
+
+func (streamer *Streamer) updateChunksToDraw(position Vec2) {
+	chunks := make(map[WorldChunkCoord]*Chunk)
+	for coord, chunk := range streamer.loaded {
+		if chunkDistance(coord, position) <= streamer.config.DrawRadius {
+			chunks[coord] = chunk
+		}
+	}
+
+	streamer.mu.Lock()
+	streamer.chunksToDraw = chunks
+	streamer.mu.Unlock()
+}
+
+func worldChunkCoordAt(position Vec2) WorldChunkCoord {
+	return WorldChunkCoord{
+		int64(math.Floor(position.X / CHUNK_WIDTH)),
+		int64(math.Floor(position.Y / CHUNK_HEIGHT)),
+	}
+}
+
+// Distance from position to the nearest point of the chunk.
+func chunkDistance(coord WorldChunkCoord, position Vec2) float64 {
+	return rectDistance(
+		float64(coord.X*CHUNK_WIDTH), float64(coord.Y*CHUNK_HEIGHT),
+		CHUNK_WIDTH, CHUNK_HEIGHT,
+		position,
+	)
+}
+
+// Distance from position to the nearest point of the sector.
+func sectorDistance(coord WorldSectorCoord, position Vec2) float64 {
+	const width, height = SECTOR_WIDTH * CHUNK_WIDTH, SECTOR_HEIGHT * CHUNK_HEIGHT
+
+	return rectDistance(float64(coord.X*width), float64(coord.Y*height), width, height, position)
+}
+
+func rectDistance(minX, minY, width, height float64, position Vec2) float64 {
+	dx := math.Max(math.Max(minX-position.X, position.X-(minX+width)), 0)
+	dy := math.Max(math.Max(minY-position.Y, position.Y-(minY+height)), 0)
+
+	return math.Hypot(dx, dy)
+}
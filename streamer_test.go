@@ -0,0 +1,149 @@
+package lostinspace
+
+import (
+	"testing"
+)
+
+// Remember chunks in the order they are loaded and unloaded.
+type streamRecorder struct {
+	loaded   []WorldChunkCoord
+	unloaded []WorldChunkCoord
+}
+
+func (recorder *streamRecorder) OnChunkLoaded(coord WorldChunkCoord, chunk *Chunk) {
+	recorder.loaded = append(recorder.loaded, coord)
+}
+
+func (recorder *streamRecorder) OnChunkUnloaded(coord WorldChunkCoord, chunk *Chunk) {
+	recorder.unloaded = append(recorder.unloaded, coord)
+}
+
+func newTestStreamer(t *testing.T) (*Streamer, *streamRecorder) {
+	universe, err := CreateUniverse(t.TempDir(), "test", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { universe.Close() })
+
+	terrain := NewTerrain()
+	terrain.Seed = universe.Seed()
+	recorder := new(streamRecorder)
+
+	return NewStreamer(DefaultStreamingConfig(), terrain, universe, recorder), recorder
+}
+
+func TestStreamerPriority(t *testing.T) {
+	streamer, recorder := newTestStreamer(t)
+
+	// Middle of the sector at the origin, heading right.
+	streamer.SetFocus(Vec2{136, 136}, Vec2{200, 0})
+	if !streamer.step() {
+		t.Fatalf("Everything is loaded at once\n")
+	}
+	if len(recorder.loaded) != streamBatchSize || recorder.loaded[0] != (WorldChunkCoord{8, 8}) {
+		t.Errorf("Loaded %v, expected the current chunk first\n", recorder.loaded)
+	}
+	for streamer.step() {
+	}
+
+	order := make(map[WorldChunkCoord]int)
+	for i, coord := range recorder.loaded {
+		if _, exist := order[coord]; exist {
+			t.Errorf("%v is loaded twice\n", coord)
+		}
+		order[coord] = i
+	}
+	ahead, behind := order[WorldChunkCoord{8 + 10, 8}], order[WorldChunkCoord{8 - 10, 8}]
+	if ahead == 0 || behind == 0 || ahead > behind {
+		t.Errorf("%v is loaded at %d, %v at %d\n", WorldChunkCoord{8 + 10, 8}, ahead, WorldChunkCoord{8 - 10, 8}, behind)
+	}
+
+	// Loading only a few chunks of a sector doesn't generate the others.
+	sector := streamer.terrain.GetSector(WorldSectorCoord{-1, 0})
+	if sector == nil || sector.At(ChunkCoord{0, 8}) != nil || sector.At(ChunkCoord{15, 8}) == nil {
+		t.Errorf("Sector on the left: %v\n", sector)
+	}
+
+	chunks := streamer.ChunksToDraw()
+	if chunks[WorldChunkCoord{8, 8}] == nil || chunks[WorldChunkCoord{8 + 11, 8}] != nil {
+		t.Errorf("Drawing %d chunks\n", len(chunks))
+	}
+	if streamer.LoadedChunk(WorldChunkCoord{8 + 11, 8}) == nil {
+		t.Errorf("Chunk in load radius is not loaded\n")
+	}
+}
+
+func TestStreamerHysteresis(t *testing.T) {
+	streamer, recorder := newTestStreamer(t)
+
+	streamer.SetFocus(Vec2{128, 128}, Vec2{})
+	for streamer.step() {
+	}
+	loaded := len(recorder.loaded)
+
+	// Chunks on the left get out of load radius, but not out of unload radius.
+	streamer.SetFocus(Vec2{228, 128}, Vec2{})
+	for streamer.step() {
+	}
+	if len(recorder.unloaded) != 0 {
+		t.Errorf("Unloaded %d chunks\n", len(recorder.unloaded))
+	}
+	if len(recorder.loaded) == loaded {
+		t.Errorf("Nothing is loaded on the right\n")
+	}
+
+	// Change a chunk which is going to be unloaded.
+	coord := WorldChunkCoord{-2, 8}
+	chunk := streamer.LoadedChunk(coord)
+	if chunk == nil {
+		t.Fatalf("%v is not loaded\n", coord)
+	}
+	chunk.Set(NewBlock(BlockCoord{1, 2}, "door0", 3))
+
+	streamer.SetFocus(Vec2{400, 128}, Vec2{})
+	for streamer.step() {
+	}
+	for _, coord := range recorder.unloaded {
+		if chunkDistance(coord, Vec2{400, 128}) <= streamer.config.UnloadRadius {
+			t.Errorf("Unloaded %v\n", coord)
+		}
+	}
+	if streamer.LoadedChunk(coord) != nil {
+		t.Errorf("%v is not unloaded\n", coord)
+	}
+
+	// Its sector is dropped and saved.
+	if streamer.terrain.GetSector(WorldSectorCoord{-1, 0}) != nil {
+		t.Errorf("Sector on the left is not unloaded\n")
+	}
+	if err := streamer.universe.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	sector, err := streamer.universe.LoadSector(WorldSectorCoord{-1, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block := sector.At(ChunkCoord{14, 8}).At(BlockCoord{1, 2}); block.BlockType != "door0" {
+		t.Errorf("Saved %v\n", block)
+	}
+}
+
+func TestStreamerNotify(t *testing.T) {
+	streamer, _ := newTestStreamer(t)
+
+	streamer.SetFocus(Vec2{1, 1}, Vec2{})
+	if len(streamer.notify) != 1 {
+		t.Errorf("Not notified at first\n")
+	}
+	<-streamer.notify
+
+	streamer.SetFocus(Vec2{15, 15}, Vec2{3, 3})
+	if len(streamer.notify) != 0 {
+		t.Errorf("Notified in the same chunk\n")
+	}
+
+	streamer.SetFocus(Vec2{16, 15}, Vec2{3, 3})
+	if len(streamer.notify) != 1 {
+		t.Errorf("Not notified in another chunk\n")
+	}
+}
@@ -1,49 +1,122 @@
 package lostinspace
 
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrChunkNotLoaded   = errors.New("chunk is not loaded")
+	ErrBlockOccupied    = errors.New("block is already there")
+	ErrNoBlock          = errors.New("there is no block")
+	ErrUnknownBlockType = errors.New("unknown block type")
+	ErrInvalidFrontFace = errors.New("front face must be from 0 to 3")
+	ErrInvalidState     = errors.New("invalid block state")
+)
+
 // Terrian is set of chunks.
+// Sectors are loaded and unloaded in background while the main loop uses them,
+// so every method is safe to call from multiple goroutines.
 type Terrain struct {
-	Sectors map[WorldSectorCoord]*Sector
+	mu      sync.RWMutex
+	sectors map[WorldSectorCoord]*Sector
 	*Seed
+
+	// Block types PlaceBlock accepts and states SetBlockState accepts, any if nil.
+	// Placed blocks get tile entities only if it's set.
+	dic *BlockTypeDictionary
+
+	listenersMu sync.Mutex
+	listeners   []BlockListener
+}
+
+// BlockListener is told about every change of blocks of a terrain,
+// right after it's made, on the goroutine which made it.
+// It must not change blocks itself.
+type BlockListener interface {
+	OnBlockChanged(event BlockChangedEvent)
 }
 
 func NewTerrain() *Terrain {
 	terrain := &Terrain{
-		Sectors: make(map[WorldSectorCoord]*Sector),
+		sectors: make(map[WorldSectorCoord]*Sector),
 	}
 
 	return terrain
 }
 
+func (terrain *Terrain) AddBlockListener(listener BlockListener) {
+	terrain.listenersMu.Lock()
+	terrain.listeners = append(terrain.listeners, listener)
+	terrain.listenersMu.Unlock()
+}
+
+func (terrain *Terrain) RemoveBlockListener(listener BlockListener) {
+	terrain.listenersMu.Lock()
+	defer terrain.listenersMu.Unlock()
+
+	// Copied, updateBlock may be going through the old slice.
+	terrain.listeners = append([]BlockListener(nil), terrain.listeners...)
+	for i, added := range terrain.listeners {
+		if added == listener {
+			terrain.listeners = append(terrain.listeners[:i], terrain.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
 func (terrain *Terrain) SetSector(sector *Sector) {
-	terrain.Sectors[sector.coord] = sector
+	terrain.mu.Lock()
+	terrain.sectors[sector.coord] = sector
+	terrain.mu.Unlock()
 }
 
 func (terrain *Terrain) GetSector(coord WorldSectorCoord) *Sector {
-	return terrain.Sectors[coord]
+	terrain.mu.RLock()
+	defer terrain.mu.RUnlock()
+
+	return terrain.sectors[coord]
 }
 
 func (terrain *Terrain) DeleteSector(coord WorldSectorCoord) {
-	delete(terrain.Sectors, coord)
+	terrain.mu.Lock()
+	delete(terrain.sectors, coord)
+	terrain.mu.Unlock()
+}
+
+// Snapshot of loaded sectors in no particular order.
+// Sectors loaded or unloaded afterwards don't affect it.
+func (terrain *Terrain) Sectors() []*Sector {
+	terrain.mu.RLock()
+	defer terrain.mu.RUnlock()
+
+	sectors := make([]*Sector, 0, len(terrain.sectors))
+	for _, sector := range terrain.sectors {
+		sectors = append(sectors, sector)
+	}
+
+	return sectors
 }
 
 func (terrain *Terrain) SetChunk(coord WorldChunkCoord, chunk *Chunk) {
 	sectorCoord, chunkCoord := coord.Parse()
 
-	sector, exist := terrain.Sectors[sectorCoord]
-	if !exist {
+	sector := terrain.GetSector(sectorCoord)
+	if sector == nil {
 		// do something
 		return
 	}
 
 	chunk.coord = chunkCoord
+	chunk.markModified()
 	sector.Set(chunk)
 }
 
 func (terrain *Terrain) GetChunk(coord WorldChunkCoord) *Chunk {
 	sectorCoord, chunkCoord := coord.Parse()
 
-	sector, exist := terrain.Sectors[sectorCoord]
-	if !exist {
+	sector := terrain.GetSector(sectorCoord)
+	if sector == nil {
 		return nil
 	}
 
@@ -52,19 +125,147 @@ func (terrain *Terrain) GetChunk(coord WorldChunkCoord) *Chunk {
 
 // Set block to given world coord.
 // Block coord which a block has will be ignored by world coord.
+// Blocks of BLOCK_TYPE_MISSING are ignored, the type only stands in for unknown ones.
+// Block listeners are told if the block is changed.
 func (terrain *Terrain) SetBlock(coord WorldBlockCoord, block *Block) {
+	if block.BlockType == BLOCK_TYPE_MISSING {
+		return
+	}
+	terrain.swapBlock(coord, block, nil)
+}
+
+// Put a block of blockType facing frontFace, where there is no block.
+// Block listeners are told if it's placed.
+func (terrain *Terrain) PlaceBlock(coord WorldBlockCoord, blockType BlockType, frontFace int) error {
+	if blockType == BLOCK_TYPE_VOID || blockType == BLOCK_TYPE_MISSING {
+		return ErrUnknownBlockType
+	}
+	if terrain.dic != nil {
+		if _, known := terrain.dic.ID(blockType); !known {
+			return ErrUnknownBlockType
+		}
+	}
+	if frontFace < 0 || frontFace > 3 {
+		return ErrInvalidFrontFace
+	}
+
+	return terrain.swapBlock(coord, NewBlock(BlockCoord{}, blockType, frontFace), func(old *Block) error {
+		if old.BlockType != BLOCK_TYPE_VOID {
+			return ErrBlockOccupied
+		}
+		return nil
+	})
+}
+
+// Remove the block at coord, leaving void.
+// Block listeners are told if it's removed.
+func (terrain *Terrain) BreakBlock(coord WorldBlockCoord) error {
+	return terrain.swapBlock(coord, NewBlock(BlockCoord{}, BLOCK_TYPE_VOID, 0), func(old *Block) error {
+		if old.BlockType == BLOCK_TYPE_VOID {
+			return ErrNoBlock
+		}
+		return nil
+	})
+}
+
+// Change state of the block at coord, keeping its type and front face.
+// Block listeners are told if the state differs.
+func (terrain *Terrain) SetBlockState(coord WorldBlockCoord, state BlockState) error {
+	return terrain.updateBlock(coord, func(old *Block) (*Block, error) {
+		if old.BlockType == BLOCK_TYPE_VOID {
+			return nil, ErrNoBlock
+		}
+		if terrain.dic != nil && !terrain.dic.Get(old.BlockType).ValidState(state) {
+			return nil, ErrInvalidState
+		}
+		block := *old
+		block.State = state
+		return &block, nil
+	})
+}
+
+// Set block if check, which may be nil, accepts the block being there.
+// Check must not change anything, see updateBlock for that.
+func (terrain *Terrain) swapBlock(coord WorldBlockCoord, block *Block, check func(old *Block) error) error {
+	return terrain.updateBlock(coord, func(old *Block) (*Block, error) {
+		if check != nil {
+			if err := check(old); err != nil {
+				return nil, err
+			}
+		}
+		return block, nil
+	})
+}
+
+// See Chunk.update.
+func (terrain *Terrain) updateBlock(coord WorldBlockCoord, update func(old *Block) (*Block, error)) error {
 	sectorCoord, chunkCoord, blockCoord := coord.Parse()
+	chunk := terrain.GetChunk(CombineWorldChunkCoord(sectorCoord, chunkCoord))
+	if chunk == nil {
+		return ErrChunkNotLoaded
+	}
 
-	sector, exist := terrain.Sectors[sectorCoord]
-	if !exist {
-		// do something
-		return
+	swap, err := chunk.update(blockCoord, update, terrain.newTileEntry)
+	if err != nil {
+		return err
+	}
+	if !swap.changed {
+		return nil
 	}
 
-	chunk := sector.At(chunkCoord)
+	ctx := TileContext{Terrain: terrain, Coord: coord}
+	if swap.removed != nil {
+		swap.removed.tile.OnRemoved(ctx)
+	}
+	if swap.placed != nil {
+		swap.placed.tile.OnPlaced(ctx)
+	}
+
+	terrain.listenersMu.Lock()
+	listeners := terrain.listeners
+	terrain.listenersMu.Unlock()
+
+	event := BlockChangedEvent{coord, *swap.old, *swap.new}
+	for _, listener := range listeners {
+		listener.OnBlockChanged(event)
+	}
 
-	block.coord = blockCoord
-	chunk.Set(block)
+	return nil
+}
+
+// Tile entity for a new block of blockType, nil if the type has none.
+func (terrain *Terrain) newTileEntry(blockType BlockType) *tileEntry {
+	if terrain.dic == nil || blockType == BLOCK_TYPE_VOID {
+		return nil
+	}
+
+	kind := terrain.dic.Get(blockType).TileEntity
+	tile := newTileEntity(kind)
+	if tile == nil {
+		return nil
+	}
+	return &tileEntry{kind, tile}
+}
+
+// Tile entity of the block at coord, nil if it has none or the chunk isn't loaded.
+func (terrain *Terrain) TileEntity(coord WorldBlockCoord) TileEntity {
+	sectorCoord, chunkCoord, blockCoord := coord.Parse()
+	chunk := terrain.GetChunk(CombineWorldChunkCoord(sectorCoord, chunkCoord))
+	if chunk == nil {
+		return nil
+	}
+
+	return chunk.TileEntity(blockCoord)
+}
+
+// Mark the chunk as modified by the tile entity at coord, so that its data is saved.
+// Blocks don't count as changed, and block listeners aren't told.
+func (terrain *Terrain) MarkTileEntityModified(coord WorldBlockCoord) {
+	sectorCoord, chunkCoord, blockCoord := coord.Parse()
+	chunk := terrain.GetChunk(CombineWorldChunkCoord(sectorCoord, chunkCoord))
+	if chunk != nil {
+		chunk.markTileEntityModified(blockCoord)
+	}
 }
 
 // Get block at given world coord.
@@ -72,12 +273,15 @@ func (terrain *Terrain) SetBlock(coord WorldBlockCoord, block *Block) {
 func (terrain *Terrain) GetBlock(coord WorldBlockCoord) *Block {
 	sectorCoord, chunkCoord, blockCoord := coord.Parse()
 
-	sector, exist := terrain.Sectors[sectorCoord]
-	if !exist {
+	sector := terrain.GetSector(sectorCoord)
+	if sector == nil {
 		return nil
 	}
 
 	chunk := sector.At(chunkCoord)
+	if chunk == nil {
+		return nil
+	}
 
 	return chunk.At(blockCoord)
 }
@@ -0,0 +1,218 @@
+package lostinspace_test
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+// Load, unload, save, edit and read the terrain all at once,
+// like the sector manager, the chunk list generator and the main loop do.
+// Run with -race.
+func TestTerrainConcurrency(t *testing.T) {
+	universe, err := lostinspace.CreateUniverse(t.TempDir(), "test", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer universe.Close()
+
+	terrain := lostinspace.NewTerrain()
+	terrain.Seed = universe.Seed()
+
+	// Sector at the origin stays loaded, the others are loaded and unloaded over and over.
+	origin := lostinspace.WorldSectorCoord{0, 0}
+	coords := []lostinspace.WorldSectorCoord{{-1, 0}, {0, -1}, {-1, -1}}
+	loadSector := func(coord lostinspace.WorldSectorCoord) *lostinspace.Sector {
+		sector, err := universe.LoadSector(coord)
+		if errors.Is(err, os.ErrNotExist) {
+			return lostinspace.GenerateSector(terrain.Seed, coord)
+		}
+		if err != nil {
+			t.Error(err)
+			return lostinspace.NewSector(coord)
+		}
+		return sector
+	}
+
+	terrain.SetSector(loadSector(origin))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	// Sector manager
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+
+		for i := 0; i < 24; i++ {
+			coord := coords[i%len(coords)]
+			sector := terrain.GetSector(coord)
+			if sector == nil {
+				terrain.SetSector(loadSector(coord))
+				continue
+			}
+
+			terrain.DeleteSector(coord)
+			if sector.Modified() {
+				if err := universe.SaveSector(sector); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	}()
+
+	// Autosave, twice so that the same sector is saved at once.
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, sector := range terrain.Sectors() {
+					if sector.Modified() {
+						if err := universe.SaveSector(sector); err != nil {
+							t.Error(err)
+						}
+					}
+				}
+			}
+		}()
+	}
+
+	// Players
+	types := []lostinspace.BlockType{lostinspace.BLOCK_TYPE_VOID, "stone", "door0"}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+
+			r := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				coord := lostinspace.WorldBlockCoord{X: r.Int63n(512) - 256, Y: r.Int63n(512) - 256}
+				terrain.SetBlock(coord, lostinspace.NewBlock(lostinspace.BlockCoord{}, types[r.Intn(len(types))], r.Intn(4)))
+				terrain.GetBlock(coord)
+			}
+		}(int64(i))
+	}
+
+	// Chunk list generator
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for _, sector := range terrain.Sectors() {
+				sector.ForEach(func(chunk *lostinspace.Chunk) {
+					chunk.ForEach(func(block *lostinspace.Block) {})
+				})
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	// No edit is lost between encoding a sector and marking it as saved,
+	// otherwise the sector isn't saved again here.
+	sector := terrain.GetSector(origin)
+	if sector.Modified() {
+		if err := universe.SaveSector(sector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := universe.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	saved := loadSector(origin)
+	sector.ForEach(func(chunk *lostinspace.Chunk) {
+		savedChunk := saved.At(chunk.Coord())
+		chunk.ForEach(func(block *lostinspace.Block) {
+			savedBlock := savedChunk.At(block.Coord())
+			if *block != *savedBlock {
+				t.Fatalf("%v %v: %v != saved %v\n", chunk.Coord(), block.Coord(), block, savedBlock)
+			}
+		})
+	})
+}
+
+type blockCollector struct {
+	events []lostinspace.BlockChangedEvent
+}
+
+func (collector *blockCollector) OnBlockChanged(event lostinspace.BlockChangedEvent) {
+	collector.events = append(collector.events, event)
+}
+
+func TestTerrainPlaceBlock(t *testing.T) {
+	terrain := lostinspace.NewTerrain()
+	terrain.SetSector(lostinspace.NewSector(lostinspace.WorldSectorCoord{X: 0, Y: 0}))
+	terrain.SetChunk(lostinspace.WorldChunkCoord{X: 0, Y: 0}, lostinspace.NewChunk(lostinspace.ChunkCoord{}))
+
+	collector := new(blockCollector)
+	terrain.AddBlockListener(collector)
+
+	coord := lostinspace.WorldBlockCoord{X: 3, Y: 4}
+	far := lostinspace.WorldBlockCoord{X: -1, Y: 4}
+	steps := []struct {
+		name     string
+		edit     func() error
+		expected error
+	}{
+		{"place", func() error { return terrain.PlaceBlock(coord, "stone", 1) }, nil},
+		{"place again", func() error { return terrain.PlaceBlock(coord, "stone", 2) }, lostinspace.ErrBlockOccupied},
+		{"place void", func() error { return terrain.PlaceBlock(far, lostinspace.BLOCK_TYPE_VOID, 0) }, lostinspace.ErrUnknownBlockType},
+		{"place missing", func() error { return terrain.PlaceBlock(far, lostinspace.BLOCK_TYPE_MISSING, 0) }, lostinspace.ErrUnknownBlockType},
+		{"place facing 4", func() error { return terrain.PlaceBlock(coord, "stone", 4) }, lostinspace.ErrInvalidFrontFace},
+		{"place out of sectors", func() error { return terrain.PlaceBlock(far, "stone", 0) }, lostinspace.ErrChunkNotLoaded},
+		{"set state", func() error { return terrain.SetBlockState(coord, 5) }, nil},
+		{"set state of void", func() error { return terrain.SetBlockState(lostinspace.WorldBlockCoord{X: 5, Y: 4}, 5) }, lostinspace.ErrNoBlock},
+		{"break", func() error { return terrain.BreakBlock(coord) }, nil},
+		{"break again", func() error { return terrain.BreakBlock(coord) }, lostinspace.ErrNoBlock},
+	}
+	for _, step := range steps {
+		if err := step.edit(); err != step.expected {
+			t.Errorf("%s: %v, expected %v\n", step.name, err, step.expected)
+		}
+	}
+	missing := lostinspace.WorldBlockCoord{X: 6, Y: 4}
+	terrain.SetBlock(missing, lostinspace.NewBlock(lostinspace.BlockCoord{}, lostinspace.BLOCK_TYPE_MISSING, 0))
+	if block := terrain.GetBlock(missing); block == nil || block.BlockType != lostinspace.BLOCK_TYPE_VOID {
+		t.Errorf("Missing block is set: %v\n", block)
+	}
+
+	if len(collector.events) != 3 {
+		t.Fatalf("Events: %v\n", collector.events)
+	}
+	placed := collector.events[0]
+	if placed.Coord != coord || placed.Old.BlockType != lostinspace.BLOCK_TYPE_VOID ||
+		placed.New.BlockType != "stone" || placed.New.FrontFace != 1 {
+		t.Errorf("Placed: %+v\n", collector.events[0])
+	}
+	changed := collector.events[1]
+	if changed.New.BlockType != "stone" || changed.New.FrontFace != 1 || changed.New.State != 5 {
+		t.Errorf("State changed: %+v\n", collector.events[1])
+	}
+	broken := collector.events[2]
+	if broken.Old != changed.New || broken.New.BlockType != lostinspace.BLOCK_TYPE_VOID {
+		t.Errorf("Broken: %+v\n", collector.events[2])
+	}
+}
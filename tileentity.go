@@ -0,0 +1,152 @@
+package lostinspace
+
+import (
+	"encoding"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TileEntity is data and logic attached to a single block, like items of a container.
+// A block gets a new one of the kind its type names by BlockTypeDescriptor.TileEntity
+// when it's placed by Terrain, and loses it when the block type changes.
+//
+// Hooks are called from the goroutine which changes the block or steps the simulation.
+// MarshalBinary is called when the sector is saved, which may be in the streaming goroutine
+// while hooks run, so data it reads has to be guarded.
+// Embed TileEntityHooks to leave out hooks which aren't needed.
+type TileEntity interface {
+	// Called after the block is placed.
+	OnPlaced(ctx TileContext)
+	// Called after the block is removed or changed to another type.
+	OnRemoved(ctx TileContext)
+	// Called when the chunk enters the simulation, after it's placed or loaded.
+	OnLoaded(ctx TileContext)
+	// Called when the chunk leaves the simulation.
+	// Changes made here may not be saved, as the sector may be saved already.
+	OnUnloaded(ctx TileContext)
+	// Called every simulation step while the chunk is in the simulation.
+	OnTick(ctx TileContext)
+
+	// Data saved along with the sector.
+	// A new tile entity of the same kind reads it back by UnmarshalBinary.
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// What hooks of a tile entity are called with.
+type TileContext struct {
+	Terrain *Terrain
+	// Simulation the chunk is in, nil for hooks called by Terrain.
+	Simulation *Simulation
+	// Where the block of the tile entity is.
+	Coord WorldBlockCoord
+	// Time step of OnTick, zero for other hooks.
+	DeltaTime time.Duration
+}
+
+// Mark the chunk of the tile entity as modified, so that its data is saved.
+func (ctx TileContext) MarkModified() {
+	ctx.Terrain.MarkTileEntityModified(ctx.Coord)
+}
+
+// Hooks which do nothing, to be embedded into tile entities.
+type TileEntityHooks struct{}
+
+func (TileEntityHooks) OnPlaced(ctx TileContext)   {}
+func (TileEntityHooks) OnRemoved(ctx TileContext)  {}
+func (TileEntityHooks) OnLoaded(ctx TileContext)   {}
+func (TileEntityHooks) OnUnloaded(ctx TileContext) {}
+func (TileEntityHooks) OnTick(ctx TileContext)     {}
+
+// Tile entity factories keyed by kind.
+var tileEntityKinds = make(map[string]func() TileEntity)
+
+// Let block types name kind by their TileEntity field.
+// It's meant to be called from init, before any block type file is loaded.
+func RegisterTileEntity(kind string, factory func() TileEntity) {
+	if _, exist := tileEntityKinds[kind]; exist {
+		panic(fmt.Sprintf("tile entity %q is already registered", kind))
+	}
+	tileEntityKinds[kind] = factory
+}
+
+// New tile entity of kind, nil if it isn't registered.
+func newTileEntity(kind string) TileEntity {
+	factory, exist := tileEntityKinds[kind]
+	if !exist {
+		return nil
+	}
+	return factory()
+}
+
+// Tile entity of a kind which isn't registered, kept as it's read so that it's saved again.
+type unknownTileEntity struct {
+	TileEntityHooks
+	data []byte
+}
+
+func (tile *unknownTileEntity) MarshalBinary() ([]byte, error) {
+	return tile.data, nil
+}
+
+func (tile *unknownTileEntity) UnmarshalBinary(data []byte) error {
+	tile.data = append([]byte(nil), data...)
+	return nil
+}
+
+// Tile entity in a chunk with the kind it's made of.
+type tileEntry struct {
+	kind string
+	tile TileEntity
+}
+
+// Tile entity of the block at coord, nil if it has none.
+func (chunk *Chunk) TileEntity(coord BlockCoord) TileEntity {
+	chunk.mu.RLock()
+	defer chunk.mu.RUnlock()
+
+	return chunk.tileEntities[coord].tile
+}
+
+// Call f for every tile entity in row major order of the blocks.
+// Tile entities are taken all at once before f is called, so f may change the chunk.
+func (chunk *Chunk) ForEachTileEntity(f func(coord BlockCoord, tile TileEntity)) {
+	chunk.mu.RLock()
+	entries := chunk.sortedTileEntities()
+	chunk.mu.RUnlock()
+
+	for _, entry := range entries {
+		f(entry.coord, entry.tile)
+	}
+}
+
+type placedTileEntry struct {
+	tileEntry
+	coord BlockCoord
+}
+
+// Tile entities in row major order of the blocks.
+// The chunk has to be locked.
+func (chunk *Chunk) sortedTileEntities() []placedTileEntry {
+	entries := make([]placedTileEntry, 0, len(chunk.tileEntities))
+	for coord, entry := range chunk.tileEntities {
+		entries = append(entries, placedTileEntry{entry, coord})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return blockIndex(entries[i].coord) < blockIndex(entries[j].coord)
+	})
+
+	return entries
+}
+
+// Mark the chunk as modified by the tile entity at coord, so that it's saved.
+// Blocks stay the same, so Revision and ChangesSince don't change.
+func (chunk *Chunk) markTileEntityModified(coord BlockCoord) {
+	chunk.mu.Lock()
+	defer chunk.mu.Unlock()
+
+	if _, exist := chunk.tileEntities[coord]; exist {
+		chunk.dataRevision++
+	}
+}
@@ -0,0 +1,126 @@
+package lostinspace_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+// Tile entity which counts ticks and remembers hooks called.
+type counterTile struct {
+	mu    sync.Mutex
+	ticks int
+	hooks []string
+}
+
+func init() {
+	lostinspace.RegisterTileEntity("test_counter", func() lostinspace.TileEntity { return new(counterTile) })
+}
+
+func (tile *counterTile) hook(name string) {
+	tile.mu.Lock()
+	tile.hooks = append(tile.hooks, name)
+	tile.mu.Unlock()
+}
+
+func (tile *counterTile) OnPlaced(ctx lostinspace.TileContext)   { tile.hook("placed") }
+func (tile *counterTile) OnRemoved(ctx lostinspace.TileContext)  { tile.hook("removed") }
+func (tile *counterTile) OnLoaded(ctx lostinspace.TileContext)   { tile.hook("loaded") }
+func (tile *counterTile) OnUnloaded(ctx lostinspace.TileContext) { tile.hook("unloaded") }
+
+func (tile *counterTile) OnTick(ctx lostinspace.TileContext) {
+	tile.mu.Lock()
+	tile.ticks++
+	tile.mu.Unlock()
+	ctx.MarkModified()
+}
+
+func (tile *counterTile) MarshalBinary() ([]byte, error) {
+	tile.mu.Lock()
+	defer tile.mu.Unlock()
+
+	return []byte(strconv.Itoa(tile.ticks)), nil
+}
+
+func (tile *counterTile) UnmarshalBinary(data []byte) error {
+	ticks, err := strconv.Atoi(string(data))
+	tile.ticks = ticks
+	return err
+}
+
+func TestTileEntity(t *testing.T) {
+	path := t.TempDir()
+	square := []lostinspace.Vec2{{X: -0.5, Y: 0.5}, {X: -0.5, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: 0.5, Y: 0.5}}
+	newDic := func() *lostinspace.BlockTypeDictionary {
+		return lostinspace.NewBlockTypeDictionary([]*lostinspace.BlockTypeDescriptor{
+			{BlockType: "stone", Density: 1, CollisionVertices: square, Fixed: true},
+			{BlockType: "chest", Density: 1, CollisionVertices: square, Fixed: true, TileEntity: "test_counter",
+				Properties: []lostinspace.BlockProperty{{Name: "open", Kind: lostinspace.BLOCK_PROPERTY_BOOL}}},
+		})
+	}
+	config := lostinspace.DefaultSimulationConfig()
+	config.SyncStreaming = true
+
+	universe, err := lostinspace.CreateUniverse(path, "test", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := lostinspace.NewSimulation(universe, newDic(), config)
+	sim.Step(testStep)
+
+	coord := lostinspace.WorldBlockCoord{X: 2, Y: 3}
+	sim.Terrain().BreakBlock(coord)
+	if err := sim.Terrain().PlaceBlock(coord, "chest", 0); err != nil {
+		t.Fatal(err)
+	}
+	tile, ok := sim.Terrain().TileEntity(coord).(*counterTile)
+	if !ok {
+		t.Fatalf("Tile entity: %v\n", sim.Terrain().TileEntity(coord))
+	}
+	chunk := sim.Terrain().GetChunk(lostinspace.WorldChunkCoord{X: 0, Y: 0})
+	revision := chunk.Revision()
+	for i := 0; i < 3; i++ {
+		sim.Step(testStep)
+	}
+	// Ticks change data of the tile entity, not the blocks.
+	if chunk.Revision() != revision || !chunk.Modified() {
+		t.Errorf("Revision %d, expected %d, modified %v\n", chunk.Revision(), revision, chunk.Modified())
+	}
+	// Changing state keeps the tile entity.
+	if err := sim.Terrain().SetBlockState(coord, 1); err != nil {
+		t.Fatal(err)
+	}
+	if block := sim.Terrain().GetBlock(coord); block == nil || block.State != 1 {
+		t.Errorf("Block after state change: %v\n", block)
+	}
+	if sim.Terrain().TileEntity(coord) != tile {
+		t.Errorf("Tile entity is replaced by state change\n")
+	}
+	sim.Close()
+
+	if tile.ticks != 3 || len(tile.hooks) != 2 || tile.hooks[0] != "placed" || tile.hooks[1] != "unloaded" {
+		t.Errorf("Ticks %d, hooks %v\n", tile.ticks, tile.hooks)
+	}
+
+	// Tile entity is saved with the sector and loaded again.
+	universe, err = lostinspace.OpenUniverse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim = lostinspace.NewSimulation(universe, newDic(), config)
+	sim.Step(testStep)
+	loaded, ok := sim.Terrain().TileEntity(coord).(*counterTile)
+	if !ok || loaded.ticks != 4 || len(loaded.hooks) != 1 || loaded.hooks[0] != "loaded" {
+		t.Fatalf("Loaded tile entity: %+v\n", sim.Terrain().TileEntity(coord))
+	}
+
+	if err := sim.Terrain().BreakBlock(coord); err != nil {
+		t.Fatal(err)
+	}
+	if sim.Terrain().TileEntity(coord) != nil || loaded.hooks[len(loaded.hooks)-1] != "removed" {
+		t.Errorf("Tile entity after the block is broken: %v, hooks %v\n", sim.Terrain().TileEntity(coord), loaded.hooks)
+	}
+	sim.Close()
+}
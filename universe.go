@@ -1,86 +1,301 @@
 package lostinspace
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
 // Yes, universe. This is UNIVERSE.
 // Contains whole things of the world.
 //
-//  universe/              # A directory which contains all informations about an universe.
-//      sectors/           # Each sector is saved into one file.
-//          sector_x_y.gob
-//          sector_x_y.gob
-//          ...
+//	universe/                      # A directory which contains all informations about an universe.
+//	    universe.json              # Manifest, see UniverseManifest.
+//	    blocks.json                # Block type of each block id, see BlockMapping.
+//	    regions/                   # Sectors are grouped by regions of REGION_WIDTH*REGION_HEIGHT sectors.
+//	        region_x_y/
+//	            sector_x_y.sector  # Each sector is saved into one file. See sectorfile.go.
+//	            sector_x_y.sector
+//	            ...
+//	        ...
 //
 // data structure
 //
-//  Universe: {
-//      Terrain: {
-//          Sectors: map[WorldSectorCoord]Sector{
-//              {
-//                  Chunks: [8*8]Chunk{
-//                      {
-//                          Blocks: [16*16]Block{
-//                              {
-//                                  BlockType: string,
-//                              },
-//                              ...
-//                          },
-//                      },
-//                      ...
-//                  },
-//              },
-//              ...
-//          },
-//      },
-//      Entities: []Entity{
-//          {
-//              Blocks: []Block{},
-//          },
-//      }
-//      Player: {
-//          Inventory: {
-//              Items: []Item{},
-//          },
-//      },
-//  }
+//	Universe: {
+//	    Terrain: {
+//	        Sectors: map[WorldSectorCoord]Sector{
+//	            {
+//	                Chunks: [16*16]Chunk{
+//	                    {
+//	                        Palette: []{BlockType, FrontFace, State},
+//	                        Blocks: [16*16]PaletteIndex,
+//	                    },
+//	                    ...
+//	                },
+//	            },
+//	            ...
+//	        },
+//	    },
+//	    Entities: []Entity{
+//	        {
+//	            Blocks: []Block{},
+//	        },
+//	    }
+//	    Player: {
+//	        Inventory: {
+//	            Items: []Item{},
+//	        },
+//	    },
+//	}
 type Universe struct {
-	*Terrain
+	Manifest UniverseManifest
 
-	dir *os.File
+	path   string
+	seed   *Seed
+	writer *sectorWriter
+	blocks *BlockMapping
+	// Held while the block mapping is being saved.
+	blocksMu sync.Mutex
+}
+
+// Informations about an universe itself.
+type UniverseManifest struct {
+	Name      string    `json:"name"`
+	Seed      int64     `json:"seed"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Version of the directory layout.
+	FormatVersion int `json:"formatVersion"`
+	// Where the player was when the universe was saved last time.
+	PlayerPosition Vec2 `json:"playerPosition"`
+}
+
+const (
+	UNIVERSE_FORMAT_VERSION = 1
+
+	REGION_WIDTH  = 32
+	REGION_HEIGHT = 32
+
+	universeManifestName = "universe.json"
+	universeBlocksName   = "blocks.json"
+	universeRegionsDir   = "regions"
+)
+
+var ErrUniverseExists = errors.New("universe already exists")
+
+// Create a new universe at the directory path.
+// The directory is created if it doesn't exist, but it must not contain an universe.
+func CreateUniverse(path, name string, seed int64) (*Universe, error) {
+	if _, err := os.Stat(filepath.Join(path, universeManifestName)); err == nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrUniverseExists)
+	}
+
+	if err := os.MkdirAll(filepath.Join(path, universeRegionsDir), 0755); err != nil {
+		return nil, err
+	}
 
-	world    *World
-	player   *Player
-	entities []*BlockEntity
+	universe := &Universe{
+		Manifest: UniverseManifest{
+			Name:          name,
+			Seed:          seed,
+			CreatedAt:     time.Now(),
+			FormatVersion: UNIVERSE_FORMAT_VERSION,
+		},
+		path:   path,
+		seed:   NewSeed(seed),
+		blocks: NewBlockMapping(nil),
+	}
+	if err := universe.SaveManifest(); err != nil {
+		return nil, err
+	}
+	universe.writer = newSectorWriter()
+
+	return universe, nil
+}
+
+// Open an existing universe at the directory path.
+func OpenUniverse(path string) (*Universe, error) {
+	raw, err := os.ReadFile(filepath.Join(path, universeManifestName))
+	if err != nil {
+		return nil, err
+	}
+
+	universe := &Universe{path: path}
+	if err := json.Unmarshal(raw, &universe.Manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", universeManifestName, err)
+	}
+	if universe.Manifest.FormatVersion > UNIVERSE_FORMAT_VERSION {
+		return nil, fmt.Errorf("%s: format version %d is newer than %d",
+			path, universe.Manifest.FormatVersion, UNIVERSE_FORMAT_VERSION)
+	}
+	universe.seed = NewSeed(universe.Manifest.Seed)
+
+	// Universes saved before block ids existed have no mapping, nor sectors which need it.
+	universe.blocks = NewBlockMapping(nil)
+	raw, err = os.ReadFile(filepath.Join(path, universeBlocksName))
+	if err == nil {
+		var types []BlockType
+		if err := json.Unmarshal(raw, &types); err != nil {
+			return nil, fmt.Errorf("%s: %w", universeBlocksName, err)
+		}
+		universe.blocks = NewBlockMapping(types)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	universe.writer = newSectorWriter()
+
+	return universe, nil
 }
 
-func NewUniverse(dir *os.File, playerTexFile *os.File) *Universe {
-	playerTex := NewTexture2D(playerTexFile)
+func (universe *Universe) Path() string {
+	return universe.path
+}
 
-	universe := new(Universe)
-	universe.dir = dir
-	universe.world = NewWorld()
-	universe.player = NewPlayer(universe.world, playerTex)
-	universe.Terrain = NewTerrain()
-	universe.entities = make([]*BlockEntity, 0)
+func (universe *Universe) Seed() *Seed {
+	return universe.seed
+}
 
-	return universe
+// Block ids used by sector files of the universe.
+func (universe *Universe) Blocks() *BlockMapping {
+	return universe.blocks
 }
 
-func (universe *Universe) CreateBlockEntity() *BlockEntity {
-	entity := NewBlockEntity(universe.world)
+// Write the manifest into the universe directory.
+func (universe *Universe) SaveManifest() error {
+	raw, err := json.MarshalIndent(&universe.Manifest, "", "\t")
+	if err != nil {
+		return err
+	}
 
-	return entity
+	return writeFileAtomic(filepath.Join(universe.path, universeManifestName), raw)
 }
 
-func (universe *Universe) Update(dt time.Duration) {
-	universe.world.Update(dt)
+// Wait until every saved sector is written to the disk.
+// Return the first error occurred since last flush.
+func (universe *Universe) Flush() error {
+	return universe.writer.Flush()
 }
 
-func (universe *Universe) SaveSector(worldSectorCoord WorldSectorCoord) {
+// Flush and stop writing.
+// Sectors can't be saved after the universe is closed.
+func (universe *Universe) Close() error {
+	return universe.writer.Close()
+}
+
+// Copy the universe directory src into dst, which must not exist.
+// Sectors of a universe open at src have to be flushed first.
+func CopyUniverse(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if entry.IsDir() {
+			return os.Mkdir(target, 0755)
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, raw, 0644)
+	})
+}
+
+// Load sector from the universe directory.
+// The error satisfies errors.Is(err, os.ErrNotExist) if the sector was never saved.
+func (universe *Universe) LoadSector(coord WorldSectorCoord) (*Sector, error) {
+	if data, exist := universe.writer.Pending(coord); exist {
+		sector, _, err := DecodeSector(bytes.NewReader(data), coord, universe.blocks)
+		return sector, err
+	}
+
+	file, err := os.Open(universe.sectorPath(coord))
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = os.Open(universe.legacySectorPath(coord))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sector, _, err := DecodeSector(file, coord, universe.blocks)
+	if err != nil {
+		return nil, fmt.Errorf("load %v from %s: %w", coord, file.Name(), err)
+	}
+
+	return sector, nil
+}
+
+// Save sector into the universe directory.
+// The sector is encoded right away and written in background,
+// call Flush to wait for it.
+// It's marked as unmodified once it's written, and stays modified if writing fails.
+// Chunks changed while the sector is being encoded stay modified.
+func (universe *Universe) SaveSector(sector *Sector) error {
+	sector.saveMu.Lock()
+	defer sector.saveMu.Unlock()
+
+	var buf bytes.Buffer
+	revisions, err := encodeSector(&buf, sector, universe.seed.Number, universe.blocks)
+	if err != nil {
+		return fmt.Errorf("save %v: %w", sector.coord, err)
+	}
+	// Ids the sector refers to must be on the disk before the sector is.
+	if err := universe.saveBlocks(); err != nil {
+		return fmt.Errorf("save %v: %w", sector.coord, err)
+	}
+
+	return universe.writer.Write(sector.coord, universe.sectorPath(sector.coord), buf.Bytes(), func() {
+		sector.markSaved(revisions)
+	})
+}
+
+// Write the block mapping if new ids are given since it was saved.
+func (universe *Universe) saveBlocks() error {
+	universe.blocksMu.Lock()
+	defer universe.blocksMu.Unlock()
+
+	types := universe.blocks.takeModified()
+	if types == nil {
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(types, "", "\t")
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(universe.path, universeBlocksName), raw)
+	}
+	if err != nil {
+		universe.blocks.markModified()
+	}
+
+	return err
+}
+
+func (universe *Universe) sectorPath(coord WorldSectorCoord) string {
+	regionX := int64(math.Floor(float64(coord.X) / REGION_WIDTH))
+	regionY := int64(math.Floor(float64(coord.Y) / REGION_HEIGHT))
+
+	return filepath.Join(
+		universe.path,
+		universeRegionsDir,
+		fmt.Sprintf("region_%d_%d", regionX, regionY),
+		fmt.Sprintf("sector_%d_%d.sector", coord.X, coord.Y),
+	)
 }
 
-func (universe *Universe) LoadSector(worldSectorCoord WorldSectorCoord) {
+// Sectors saved before universes existed were gob files in the working directory.
+// They are picked up if they are moved into the universe directory.
+func (universe *Universe) legacySectorPath(coord WorldSectorCoord) string {
+	return filepath.Join(universe.path, fmt.Sprintf("sector_%d_%d.gob", coord.X, coord.Y))
 }
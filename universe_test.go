@@ -0,0 +1,135 @@
+package lostinspace_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rlj1202/LostInSpace"
+)
+
+func TestUniverse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "universe")
+
+	universe, err := lostinspace.CreateUniverse(path, "test", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lostinspace.CreateUniverse(path, "test", 7); !errors.Is(err, lostinspace.ErrUniverseExists) {
+		t.Errorf("Created universe twice: %v\n", err)
+	}
+
+	coord := lostinspace.WorldSectorCoord{-40, 3}
+	if _, err := universe.LoadSector(coord); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Loaded unsaved sector: %v\n", err)
+	}
+
+	sector := lostinspace.GenerateSector(universe.Seed(), coord)
+	chunk := sector.At(lostinspace.ChunkCoord{2, 3})
+	chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{4, 5}, "door0", 2))
+	if err := universe.SaveSector(sector); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := universe.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	universe.Manifest.PlayerPosition = lostinspace.Vec2{X: 1.5, Y: -2}
+	if err := universe.SaveManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := lostinspace.OpenUniverse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.Manifest.Name != "test" || reopened.Manifest.Seed != 7 || reopened.Manifest.PlayerPosition.X != 1.5 {
+		t.Errorf("Manifest: %+v\n", reopened.Manifest)
+	}
+
+	loaded, err := reopened.LoadSector(coord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := loaded.At(lostinspace.ChunkCoord{2, 3}).At(lostinspace.BlockCoord{4, 5})
+	if block.BlockType != "door0" || block.FrontFace != 2 {
+		t.Errorf("Loaded block: %v\n", block)
+	}
+
+	// Ids stay the same, and types the dictionary lacks are shown as missing.
+	doorID := universe.Blocks().ID("door0")
+	if id := reopened.Blocks().ID("door0"); id != doorID {
+		t.Errorf("Door id %d, expected %d\n", id, doorID)
+	}
+	dic := lostinspace.NewBlockTypeDictionary([]*lostinspace.BlockTypeDescriptor{{BlockType: "stone"}})
+	if id := reopened.Blocks().Remap(dic)[doorID]; id != lostinspace.BLOCK_ID_MISSING {
+		t.Errorf("Door is remapped to %d\n", id)
+	}
+	if des := dic.Get(block.BlockType); des == nil || des.BlockType != lostinspace.BLOCK_TYPE_MISSING {
+		t.Errorf("Door descriptor: %v\n", des)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(path, "regions", "region_-2_0", "sector_-40_3.sector"))
+	if len(matches) != 1 {
+		t.Errorf("Sector file is not in its region directory\n")
+	}
+}
+
+func TestUniverseSaveQueue(t *testing.T) {
+	path := t.TempDir()
+
+	universe, err := lostinspace.CreateUniverse(path, "test", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coord := lostinspace.WorldSectorCoord{0, 0}
+	sector := lostinspace.NewSector(coord)
+	chunk := lostinspace.NewChunk(lostinspace.ChunkCoord{0, 0})
+	sector.Set(chunk)
+
+	// Saves are coalesced but loading always sees the latest one.
+	for i := 0; i < 100; i++ {
+		chunk.Set(lostinspace.NewBlock(lostinspace.BlockCoord{0, 0}, "stone", i%4))
+		if err := universe.SaveSector(sector); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := universe.LoadSector(coord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block := loaded.At(lostinspace.ChunkCoord{0, 0}).At(lostinspace.BlockCoord{0, 0}); block.FrontFace != i%4 {
+			t.Fatalf("Save %d: loaded %v\n", i, block)
+		}
+	}
+
+	if err := universe.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := universe.SaveSector(sector); !errors.Is(err, lostinspace.ErrUniverseClosed) {
+		t.Errorf("Saved into closed universe: %v\n", err)
+	}
+
+	tmps, _ := filepath.Glob(filepath.Join(path, "regions", "*", "*.tmp"))
+	if len(tmps) != 0 {
+		t.Errorf("Temporary files are left: %v\n", tmps)
+	}
+
+	reopened, err := lostinspace.OpenUniverse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	loaded, err := reopened.LoadSector(coord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block := loaded.At(lostinspace.ChunkCoord{0, 0}).At(lostinspace.BlockCoord{0, 0}); block.FrontFace != 99%4 {
+		t.Errorf("Loaded %v\n", block)
+	}
+}